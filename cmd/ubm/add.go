@@ -42,7 +42,7 @@ func addCmd() *cobra.Command {
 			}
 
 			// Load existing data for category selection
-			data, categoryTree, err := helpers.LoadDataAndBuildTree(store)
+			_, categoryTree, err := helpers.LoadDataAndBuildTree(store)
 			if err != nil {
 				return err
 			}
@@ -53,13 +53,10 @@ func addCmd() *cobra.Command {
 				return helpers.HandleCancelError(err)
 			}
 
-			// Create new category if needed
-			helpers.EnsureCategoryExists(data, selectedCategory)
-
 			// Create bookmark
 			b := bookmark.New(title, url, selectedCategory)
 
-			// Save bookmark
+			// Save bookmark (a new category is registered in the same write)
 			if err := store.AddBookmark(b); err != nil {
 				return fmt.Errorf("failed to save bookmark: %w", err)
 			}
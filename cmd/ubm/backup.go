@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/tom-023/ubm/internal/cmd/helpers"
+	"github.com/tom-023/ubm/internal/config"
+	"github.com/tom-023/ubm/internal/ui"
+)
+
+func backupCmd(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Manage automatic backups",
+		Long: `List, restore, and prune the backup generations that are written on every save.
+The number of generations is controlled by max_backups in config.yaml.`,
+	}
+
+	cmd.AddCommand(
+		backupListCmd(),
+		backupRestoreCmd(),
+		backupPruneCmd(cfg),
+	)
+
+	return cmd
+}
+
+func backupListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "list",
+		Short:   "List backup generations, newest first",
+		Aliases: []string{"ls"},
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fs, err := fileStore()
+			if err != nil {
+				return err
+			}
+
+			backups, err := fs.ListBackups()
+			if err != nil {
+				return err
+			}
+
+			if len(backups) == 0 {
+				fmt.Println("No backups found.")
+				return nil
+			}
+
+			fmt.Println("📦 Backups (newest first):")
+			for i, backup := range backups {
+				data, err := fs.LoadBackup(i + 1)
+				if err != nil {
+					fmt.Printf("  %2d  %s  (unreadable: %v)\n", i+1, backup.CreatedAt.Local().Format("2006-01-02 15:04:05"), err)
+					continue
+				}
+				fmt.Printf("  %2d  %s  (%d bookmarks, %d categories)\n",
+					i+1, backup.CreatedAt.Local().Format("2006-01-02 15:04:05"), len(data.Bookmarks), len(data.Categories))
+			}
+
+			return nil
+		},
+	}
+}
+
+func backupRestoreCmd() *cobra.Command {
+	var skipConfirm bool
+
+	cmd := &cobra.Command{
+		Use:   "restore <n>",
+		Short: "Restore backup generation n (see 'ubm backup list')",
+		Long: `Replace the current library with backup generation n, where 1 is the newest.
+The current library is backed up first, so a restore can be undone the same way.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fs, err := fileStore()
+			if err != nil {
+				return err
+			}
+
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid backup number: %s", args[0])
+			}
+
+			data, err := fs.LoadBackup(n)
+			if err != nil {
+				return err
+			}
+
+			if !skipConfirm {
+				confirmMsg := fmt.Sprintf("Restore backup %d (%d bookmarks)? The current library will be backed up first.", n, len(data.Bookmarks))
+				confirm, err := ui.Confirm(confirmMsg)
+				if err != nil {
+					return helpers.HandleCancelError(err)
+				}
+				if !confirm {
+					fmt.Println("Restore cancelled.")
+					return nil
+				}
+			}
+
+			if err := fs.RestoreBackup(n); err != nil {
+				return fmt.Errorf("failed to restore backup: %w", err)
+			}
+
+			fmt.Printf("✅ Backup %d restored successfully!\n", n)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&skipConfirm, "confirm", "y", false, "Skip confirmation prompt")
+
+	return cmd
+}
+
+func backupPruneCmd(cfg *config.Config) *cobra.Command {
+	var keep int
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete old backup generations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fs, err := fileStore()
+			if err != nil {
+				return err
+			}
+
+			if !cmd.Flags().Changed("keep") {
+				keep = cfg.MaxBackups
+			}
+
+			removed, err := fs.PruneBackups(keep)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("✅ Removed %d backup(s), keeping at most %d.\n", removed, keep)
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&keep, "keep", 0, "Number of generations to keep (default: max_backups from config)")
+
+	return cmd
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
 	"github.com/tom-023/ubm/internal/category"
+	"github.com/tom-023/ubm/internal/storage"
 	"github.com/tom-023/ubm/internal/ui"
 )
 
@@ -72,20 +73,17 @@ func categoryCreateCmd() *cobra.Command {
 				}
 			}
 
-			// Check if category already exists
-			for _, cat := range data.Categories {
-				if cat == newCategoryPath {
+			// Add to categories, checking against the current state of the library
+			err = store.Update(func(current *storage.Data) error {
+				if current.HasCategory(newCategoryPath) {
 					return fmt.Errorf("category '%s' already exists", newCategoryPath)
 				}
-			}
-
-			// Add to categories
-			data.Categories = append(data.Categories, newCategoryPath)
-			sort.Strings(data.Categories)
-
-			// Save data
-			if err := store.Save(data); err != nil {
-				return fmt.Errorf("failed to save data: %w", err)
+				current.Categories = append(current.Categories, newCategoryPath)
+				sort.Strings(current.Categories)
+				return nil
+			})
+			if err != nil {
+				return err
 			}
 
 			fmt.Printf("✅ Category '%s' created successfully!\n", newCategoryPath)
@@ -194,18 +192,25 @@ func categoryDeleteCmd() *cobra.Command {
 				return nil
 			}
 
-			// Remove category
-			newCategories := []string{}
-			for _, cat := range data.Categories {
-				if cat != selectedCategory {
-					newCategories = append(newCategories, cat)
+			// Remove category, unless it gained bookmarks in the meantime
+			err = store.Update(func(current *storage.Data) error {
+				for _, b := range current.Bookmarks {
+					if b.Category == selectedCategory {
+						return fmt.Errorf("category '%s' is no longer empty", selectedCategory)
+					}
 				}
-			}
-			data.Categories = newCategories
 
-			// Save data
-			if err := store.Save(data); err != nil {
-				return fmt.Errorf("failed to save data: %w", err)
+				newCategories := []string{}
+				for _, cat := range current.Categories {
+					if cat != selectedCategory {
+						newCategories = append(newCategories, cat)
+					}
+				}
+				current.Categories = newCategories
+				return nil
+			})
+			if err != nil {
+				return err
 			}
 
 			fmt.Printf("✅ Category '%s' deleted successfully!\n", selectedCategory)
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/internal/storage"
+	"github.com/tom-023/ubm/internal/ui"
+)
+
+// bookmarkField is a field an interactive command can change
+type bookmarkField struct {
+	name string
+	get  func(b *bookmark.Bookmark) string
+	set  func(b *bookmark.Bookmark, v string)
+}
+
+var bookmarkFields = []bookmarkField{
+	{"title", func(b *bookmark.Bookmark) string { return b.Title }, (*bookmark.Bookmark).SetTitle},
+	{"URL", func(b *bookmark.Bookmark) string { return b.URL }, (*bookmark.Bookmark).SetURL},
+	{"category", func(b *bookmark.Bookmark) string { return b.Category }, (*bookmark.Bookmark).SetCategory},
+	{"description", func(b *bookmark.Bookmark) string { return b.Description }, (*bookmark.Bookmark).SetDescription},
+}
+
+// bookmarkEdit is a change to one bookmark, kept as the new values of the
+// fields the user changed so it can be reapplied to a newer version
+type bookmarkEdit struct {
+	id string
+	// seen is the version of the bookmark the user last saw
+	seen    *bookmark.Bookmark
+	changes map[string]string
+}
+
+func newBookmarkEdit(original, edited *bookmark.Bookmark) *bookmarkEdit {
+	e := &bookmarkEdit{id: original.ID, seen: original, changes: map[string]string{}}
+	for _, f := range bookmarkFields {
+		if f.get(original) != f.get(edited) {
+			e.changes[f.name] = f.get(edited)
+		}
+	}
+	return e
+}
+
+func (e *bookmarkEdit) apply(data *storage.Data) (*bookmark.Bookmark, error) {
+	b := data.FindBookmark(e.id)
+	if b == nil {
+		return nil, fmt.Errorf("bookmark with ID %s not found", e.id)
+	}
+	e.set(b)
+	data.AddCategory(b.Category)
+	return b, nil
+}
+
+func (e *bookmarkEdit) set(b *bookmark.Bookmark) {
+	for _, f := range bookmarkFields {
+		if v, ok := e.changes[f.name]; ok {
+			f.set(b, v)
+		}
+	}
+}
+
+// unchanged reports whether b still has the values the user saw in every
+// field the edit changes
+func (e *bookmarkEdit) unchanged(b *bookmark.Bookmark) bool {
+	for _, f := range bookmarkFields {
+		if _, ok := e.changes[f.name]; ok && f.get(b) != f.get(e.seen) {
+			return false
+		}
+	}
+	return true
+}
+
+// merge asks which value to keep for every field both sides changed
+// differently, and drops the changes where the other value wins
+func (e *bookmarkEdit) merge(theirs *bookmark.Bookmark) error {
+	for _, f := range bookmarkFields {
+		mine, ok := e.changes[f.name]
+		if !ok {
+			continue
+		}
+		other := f.get(theirs)
+		if other == f.get(e.seen) || other == mine {
+			continue
+		}
+
+		fmt.Printf("\nThe %s was changed on both sides.\n", f.name)
+		i, err := ui.Choose(fmt.Sprintf("Which %s do you want to keep?", f.name), []string{
+			"Mine:   " + displayValue(mine),
+			"Theirs: " + displayValue(other),
+		})
+		if err != nil {
+			return err
+		}
+		if i == 1 {
+			delete(e.changes, f.name)
+		}
+	}
+	return nil
+}
+
+// errBookmarkChanged makes the index-based save fall back to the whole
+// library, where the other changes can be shown
+var errBookmarkChanged = errors.New("bookmark was changed")
+
+// commitBookmarkEdit saves a change the user prepared against base. If
+// someone else saved in the meantime, their changes are shown and the user
+// can reapply the edit on top of them, merge field by field, or abort.
+// Backends with an index save the bookmark alone as long as nobody changed
+// the fields being edited; changes to other bookmarks do not get in the way.
+func commitBookmarkEdit(base *storage.Data, edit *bookmarkEdit) (*bookmark.Bookmark, error) {
+	if editor, ok := store.(storage.BookmarkEditor); ok {
+		var saved *bookmark.Bookmark
+		err := editor.EditBookmark(edit.id, func(b *bookmark.Bookmark) error {
+			if b == nil || !edit.unchanged(b) {
+				return errBookmarkChanged
+			}
+			edit.set(b)
+			saved = b
+			return nil
+		})
+		if !errors.Is(err, errBookmarkChanged) {
+			return saved, err
+		}
+	}
+
+	for {
+		var saved *bookmark.Bookmark
+		err := storage.UpdateFrom(store, base, func(current *storage.Data) error {
+			var err error
+			saved, err = edit.apply(current)
+			return err
+		})
+
+		var conflict *storage.ConflictError
+		if !errors.As(err, &conflict) {
+			return saved, err
+		}
+
+		theirs := conflict.Current.FindBookmark(edit.id)
+		if theirs == nil {
+			printConflict(conflict)
+			return nil, fmt.Errorf("the bookmark was deleted in the meantime; nothing was saved")
+		}
+
+		action, err := resolveConflict(conflict, true)
+		if err != nil {
+			return nil, err
+		}
+		if action == conflictMerge {
+			if err := edit.merge(theirs); err != nil {
+				return nil, err
+			}
+		}
+		edit.seen = theirs
+		base = conflict.Current
+	}
+}
+
+// commitDelete moves target to the trash. Like commitBookmarkEdit it goes
+// through the backend's index if there is one and the bookmark is still as
+// the user saw it. Otherwise the change is made against base, which is nil
+// if the caller only looked the bookmark up through the index.
+func commitDelete(base *storage.Data, target *bookmark.Bookmark) error {
+	if editor, ok := store.(storage.BookmarkEditor); ok {
+		err := editor.DeleteBookmarkIf(target.ID, func(b *bookmark.Bookmark) error {
+			if b == nil || !sameFields(b, target) {
+				return errBookmarkChanged
+			}
+			return nil
+		})
+		if !errors.Is(err, errBookmarkChanged) {
+			return err
+		}
+		if base == nil {
+			return fmt.Errorf("the bookmark was changed or deleted in the meantime; nothing was deleted")
+		}
+	}
+
+	return commitChange(base, func(current *storage.Data) error {
+		return current.TrashBookmark(target.ID, time.Now())
+	})
+}
+
+// sameFields reports whether a and b agree in every field a command can change
+func sameFields(a, b *bookmark.Bookmark) bool {
+	for _, f := range bookmarkFields {
+		if f.get(a) != f.get(b) {
+			return false
+		}
+	}
+	return true
+}
+
+// commitChange is commitBookmarkEdit for changes that cannot be merged, such
+// as a delete: the user can only reapply fn to the newer library or abort.
+func commitChange(base *storage.Data, fn func(*storage.Data) error) error {
+	for {
+		err := storage.UpdateFrom(store, base, fn)
+
+		var conflict *storage.ConflictError
+		if !errors.As(err, &conflict) {
+			return err
+		}
+
+		if _, err := resolveConflict(conflict, false); err != nil {
+			return err
+		}
+		base = conflict.Current
+	}
+}
+
+type conflictAction int
+
+const (
+	conflictReapply conflictAction = iota
+	conflictMerge
+)
+
+// resolveConflict shows what changed and asks how to go on. Aborting returns
+// ui.ErrCancelled.
+func resolveConflict(conflict *storage.ConflictError, canMerge bool) (conflictAction, error) {
+	printConflict(conflict)
+
+	items := []string{"Reapply my change on top of theirs"}
+	actions := []conflictAction{conflictReapply}
+	if canMerge {
+		items = append(items, "Merge, choosing where both changed the same field")
+		actions = append(actions, conflictMerge)
+	}
+	items = append(items, "Abort without saving")
+
+	i, err := ui.Choose("How do you want to continue?", items)
+	if err != nil {
+		return 0, err
+	}
+	if i == len(items)-1 {
+		return 0, ui.ErrCancelled
+	}
+	return actions[i], nil
+}
+
+func printConflict(conflict *storage.ConflictError) {
+	fmt.Printf("\n⚠️  The library was changed by someone else while you were editing (%s):\n",
+		conflict.Current.UpdatedAt.Local().Format("2006-01-02 15:04:05"))
+	if len(conflict.Changes) == 0 {
+		fmt.Println("(the individual changes are not known)")
+	}
+	for _, e := range conflict.Changes {
+		printJournalEntry(e)
+	}
+	fmt.Println()
+}
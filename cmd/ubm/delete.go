@@ -4,7 +4,10 @@ import (
 	"fmt"
 
 	"github.com/spf13/cobra"
+	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/internal/category"
 	"github.com/tom-023/ubm/internal/cmd/helpers"
+	"github.com/tom-023/ubm/internal/storage"
 	"github.com/tom-023/ubm/internal/ui"
 )
 
@@ -14,42 +17,51 @@ func deleteCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "delete [ID]",
 		Short: "Delete bookmark",
-		Long:  `Delete a bookmark by ID or select interactively.`,
+		Long: `Delete a bookmark by ID or select interactively.
+Deleted bookmarks are moved to the trash and can be brought back with 'ubm trash restore'.`,
 		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			var bookmarkID string
+			var (
+				base   *storage.Data
+				target *bookmark.Bookmark
+				err    error
+			)
 
 			if len(args) > 0 {
-				bookmarkID = args[0]
+				bookmarkID := args[0]
+				// Backends with an index find the bookmark without loading
+				// the library; changes made meanwhile are caught on save
+				if _, indexed := store.(storage.BookmarkEditor); indexed {
+					target, err = store.GetBookmark(bookmarkID)
+				} else if base, err = store.Load(); err == nil {
+					target, err = base.GetBookmark(bookmarkID)
+				}
+				if err != nil {
+					return readOnlyError(bookmarkID, fmt.Errorf("bookmark not found: %w", err))
+				}
 			} else {
-				// Interactive selection
-				data, categoryTree, err := helpers.LoadDataAndBuildTree(store)
+				var categoryTree *category.Node
+				base, categoryTree, err = helpers.LoadDataAndBuildTree(store)
 				if err != nil {
 					return err
 				}
 
-				if len(data.Bookmarks) == 0 {
+				// Interactive selection
+				if len(base.Bookmarks) == 0 {
 					fmt.Println("No bookmarks found.")
 					return nil
 				}
 
 				// Navigate and select bookmark
-				bookmark, err := ui.NavigateAndSelectBookmark(categoryTree, data.Bookmarks, "Select bookmark to delete")
+				target, err = ui.NavigateAndSelectBookmark(categoryTree, base.Bookmarks, "Select bookmark to delete")
 				if err != nil {
 					return helpers.HandleCancelError(err)
 				}
-				bookmarkID = bookmark.ID
-			}
-
-			// Get bookmark details for confirmation
-			bookmark, err := store.GetBookmark(bookmarkID)
-			if err != nil {
-				return fmt.Errorf("bookmark not found: %w", err)
 			}
 
 			// Confirm deletion
 			if !skipConfirm {
-				confirmMsg := fmt.Sprintf("Delete bookmark '%s' (%s)?", bookmark.Title, bookmark.URL)
+				confirmMsg := fmt.Sprintf("Move bookmark '%s' (%s) to the trash?", target.Title, target.URL)
 				confirm, err := ui.Confirm(confirmMsg)
 				if err != nil {
 					return helpers.HandleCancelError(err)
@@ -61,11 +73,11 @@ func deleteCmd() *cobra.Command {
 			}
 
 			// Delete bookmark
-			if err := store.DeleteBookmark(bookmarkID); err != nil {
-				return fmt.Errorf("failed to delete bookmark: %w", err)
+			if err := commitDelete(base, target); err != nil {
+				return helpers.HandleCancelError(fmt.Errorf("failed to delete bookmark: %w", err))
 			}
 
-			fmt.Printf("✅ Bookmark '%s' deleted successfully!\n", bookmark.Title)
+			fmt.Printf("🗑️  Bookmark '%s' moved to the trash.\n", target.Title)
 			return nil
 		},
 	}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/tom-023/ubm/internal/doctor"
+	"github.com/tom-023/ubm/internal/storage"
+)
+
+func doctorCmd() *cobra.Command {
+	var fix bool
+
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the library for inconsistencies",
+		Long: `Check the whole library for problems such as duplicate IDs, invalid URLs,
+malformed or missing categories, and impossible timestamps.
+With --fix, problems that can be repaired safely are fixed; the rest are left
+for manual review with 'ubm edit'.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := store.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load bookmarks: %w", err)
+			}
+
+			problems := doctor.Check(data)
+			if len(problems) == 0 {
+				fmt.Println("✅ No problems found.")
+				return nil
+			}
+
+			fixable := 0
+			for _, p := range problems {
+				if p.Fixable {
+					fixable++
+				}
+			}
+
+			if fix && fixable > 0 {
+				err := store.Update(func(data *storage.Data) error {
+					problems = doctor.Fix(data)
+					return nil
+				})
+				if err != nil {
+					return fmt.Errorf("failed to repair library: %w", err)
+				}
+			}
+
+			counts := map[doctor.Severity]int{}
+			fixed := 0
+			for _, p := range problems {
+				counts[p.Severity]++
+				if p.Fixed {
+					fixed++
+				}
+				printProblem(p)
+			}
+
+			fmt.Printf("\n%d error(s), %d warning(s), %d info.", counts[doctor.Error], counts[doctor.Warning], counts[doctor.Info])
+			switch {
+			case fixed > 0:
+				fmt.Printf(" Fixed %d, %d left for manual review.\n", fixed, len(problems)-fixed)
+			case fixable > 0:
+				fmt.Printf(" Run 'ubm doctor --fix' to repair %d of them.\n", fixable)
+			default:
+				fmt.Println(" None can be repaired automatically.")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&fix, "fix", false, "Repair problems that can be fixed safely")
+
+	return cmd
+}
+
+func printProblem(p doctor.Problem) {
+	icon := map[doctor.Severity]string{
+		doctor.Error:   "❌",
+		doctor.Warning: "⚠️ ",
+		doctor.Info:    "ℹ️ ",
+	}[p.Severity]
+
+	status := ""
+	switch {
+	case p.Fixed:
+		status = " [fixed]"
+	case p.Fixable:
+		status = " [fixable]"
+	}
+
+	fmt.Printf("%s %-7s %s%s\n", icon, p.Severity, p.Message, status)
+}
@@ -41,13 +41,14 @@ func editCmd() *cobra.Command {
 			// Store original values for comparison
 			originalTitle := targetBookmark.Title
 			originalURL := targetBookmark.URL
-			originalCategory := targetBookmark.Category
+			newTitle := originalTitle
+			newURL := originalURL
 
 			switch field {
 			case "Title":
 				fmt.Printf("\nOld Title: %s\n", originalTitle)
 				fmt.Println("(Press Enter without typing to keep the current title)")
-				newTitle, err := ui.PromptString("New title", "")
+				newTitle, err = ui.PromptString("New title", "")
 				if err != nil {
 					return helpers.HandleCancelError(err)
 				}
@@ -55,12 +56,11 @@ func editCmd() *cobra.Command {
 				if newTitle == "" {
 					newTitle = originalTitle
 				}
-				targetBookmark.SetTitle(newTitle)
 
 			case "URL":
 				fmt.Printf("\nOld URL: %s\n", originalURL)
 				fmt.Println("(Press Enter without typing to keep the current URL)")
-				newURL, err := ui.PromptString("New URL", "")
+				newURL, err = ui.PromptString("New URL", "")
 				if err != nil {
 					return helpers.HandleCancelError(err)
 				}
@@ -73,19 +73,15 @@ func editCmd() *cobra.Command {
 				if err != nil {
 					return fmt.Errorf("invalid URL: %w", err)
 				}
-				targetBookmark.SetURL(newURL)
 			}
 
 			// Show changes summary
 			fmt.Println("\n--- Changes Summary ---")
-			if originalTitle != targetBookmark.Title {
-				fmt.Printf("Title: %s → %s\n", originalTitle, targetBookmark.Title)
+			if originalTitle != newTitle {
+				fmt.Printf("Title: %s → %s\n", originalTitle, newTitle)
 			}
-			if originalURL != targetBookmark.URL {
-				fmt.Printf("URL: %s → %s\n", originalURL, targetBookmark.URL)
-			}
-			if originalCategory != targetBookmark.Category {
-				fmt.Printf("Category: %s → %s\n", ui.FormatCategory(originalCategory), ui.FormatCategory(targetBookmark.Category))
+			if originalURL != newURL {
+				fmt.Printf("URL: %s → %s\n", originalURL, newURL)
 			}
 			fmt.Println("---------------------")
 
@@ -99,9 +95,13 @@ func editCmd() *cobra.Command {
 				return nil
 			}
 
-			// Update bookmark
-			if err := store.UpdateBookmark(targetBookmark); err != nil {
-				return fmt.Errorf("failed to update bookmark: %w", err)
+			// Save only the edited field, unless the library changed meanwhile
+			edited := *targetBookmark
+			edited.Title = newTitle
+			edited.URL = newURL
+			targetBookmark, err = commitBookmarkEdit(data, newBookmarkEdit(targetBookmark, &edited))
+			if err != nil {
+				return helpers.HandleCancelError(fmt.Errorf("failed to update bookmark: %w", err))
 			}
 
 			helpers.PrintBookmarkSuccess("updated", targetBookmark)
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/tom-023/ubm/internal/cmd/helpers"
+	"github.com/tom-023/ubm/internal/config"
+	"github.com/tom-023/ubm/internal/storage"
+	"github.com/tom-023/ubm/internal/ui"
+)
+
+const (
+	encryptionPassphrase = "passphrase"
+	encryptionKeyFile    = "keyfile"
+)
+
+// newEncryption builds the encryption configured in config.yaml. The
+// passphrase is read from UBM_PASSPHRASE or asked for when first needed.
+func newEncryption(mode, keyFile string) (*storage.Encryption, error) {
+	switch mode {
+	case "":
+		return nil, nil
+	case encryptionPassphrase:
+		return storage.NewPassphraseEncryption(func() ([]byte, error) {
+			if p := os.Getenv("UBM_PASSPHRASE"); p != "" {
+				return []byte(p), nil
+			}
+			p, err := ui.PromptPassword("Passphrase")
+			return []byte(p), err
+		}), nil
+	case encryptionKeyFile:
+		if keyFile == "" {
+			return nil, fmt.Errorf("encryption is set to keyfile, but key_file is empty")
+		}
+		return storage.NewKeyFileEncryption(keyFile)
+	default:
+		return nil, fmt.Errorf("unknown encryption %q (expected passphrase or keyfile)", mode)
+	}
+}
+
+// chooseEncryption sets up the key for encrypt and rekey: a key file, created
+// if it does not exist yet, or a new passphrase entered twice
+func chooseEncryption(keyFile string) (enc *storage.Encryption, mode, path string, err error) {
+	if keyFile != "" {
+		path, err = filepath.Abs(keyFile)
+		if err != nil {
+			return nil, "", "", err
+		}
+		if _, statErr := os.Stat(path); os.IsNotExist(statErr) {
+			if err := storage.GenerateKeyFile(path); err != nil {
+				return nil, "", "", err
+			}
+			fmt.Printf("🔑 Generated a new key in %s. Keep a copy somewhere safe.\n", path)
+		}
+		enc, err = storage.NewKeyFileEncryption(path)
+		return enc, encryptionKeyFile, path, err
+	}
+
+	passphrase := os.Getenv("UBM_NEW_PASSPHRASE")
+	if passphrase == "" {
+		passphrase, err = ui.PromptPassword("New passphrase")
+		if err != nil {
+			return nil, "", "", err
+		}
+		again, err := ui.PromptPassword("Repeat passphrase")
+		if err != nil {
+			return nil, "", "", err
+		}
+		if again != passphrase {
+			return nil, "", "", fmt.Errorf("passphrases do not match")
+		}
+	}
+
+	enc = storage.NewPassphraseEncryption(func() ([]byte, error) {
+		return []byte(passphrase), nil
+	})
+	return enc, encryptionPassphrase, "", nil
+}
+
+func storageEncryptCmd(cfg *config.Config) *cobra.Command {
+	var keyFile string
+
+	cmd := &cobra.Command{
+		Use:   "encrypt",
+		Short: "Encrypt the library, its backups, and the history",
+		Long: `Encrypt the bookmark library at rest with AES-256-GCM. The key is derived from a
+passphrase (asked for interactively or read from UBM_PASSPHRASE) or read from a
+key file, which is generated if it does not exist. UBM_NEW_PASSPHRASE sets the
+new passphrase without prompting.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fs, err := fileStore()
+			if err != nil {
+				return err
+			}
+			settings := cfg.ForLibrary(activeLibrary)
+			if settings.Encryption != "" {
+				return fmt.Errorf("library is already encrypted (use rekey to change the key)")
+			}
+
+			enc, mode, path, err := chooseEncryption(keyFile)
+			if err != nil {
+				return helpers.HandleCancelError(err)
+			}
+
+			if err := fs.Reencrypt(enc); err != nil {
+				return fmt.Errorf("failed to encrypt library: %w", err)
+			}
+
+			settings.Encryption = mode
+			settings.KeyFile = path
+			cfg.SetLibrary(activeLibrary, settings)
+			if err := config.Save(cfg); err != nil {
+				return err
+			}
+
+			fmt.Println("✅ Library encrypted.")
+			syncAfterReencrypt(fs)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&keyFile, "key-file", "", "Use a key file instead of a passphrase")
+
+	return cmd
+}
+
+func storageDecryptCmd(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "decrypt",
+		Short: "Store the library in plain text again",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fs, err := fileStore()
+			if err != nil {
+				return err
+			}
+			settings := cfg.ForLibrary(activeLibrary)
+			if settings.Encryption == "" {
+				return fmt.Errorf("library is not encrypted")
+			}
+
+			if err := fs.Reencrypt(nil); err != nil {
+				return fmt.Errorf("failed to decrypt library: %w", err)
+			}
+
+			keyFile := settings.KeyFile
+			settings.Encryption = ""
+			settings.KeyFile = ""
+			cfg.SetLibrary(activeLibrary, settings)
+			if err := config.Save(cfg); err != nil {
+				return err
+			}
+
+			fmt.Println("✅ Library decrypted.")
+			syncAfterReencrypt(fs)
+			if keyFile != "" {
+				fmt.Printf("The key file %s is no longer needed and was left in place.\n", keyFile)
+			}
+			return nil
+		},
+	}
+}
+
+func storageRekeyCmd(cfg *config.Config) *cobra.Command {
+	var keyFile string
+
+	cmd := &cobra.Command{
+		Use:   "rekey",
+		Short: "Encrypt the library with a new passphrase or key file",
+		Long: `Re-encrypt the library, its backups, and the history with a new key. The
+current key is needed to read them; the new one is set up as for encrypt.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fs, err := fileStore()
+			if err != nil {
+				return err
+			}
+			settings := cfg.ForLibrary(activeLibrary)
+			if settings.Encryption == "" {
+				return fmt.Errorf("library is not encrypted (use encrypt first)")
+			}
+
+			// Read the library with the current key before asking for the new one
+			if _, err := fs.Load(); err != nil {
+				return helpers.HandleCancelError(err)
+			}
+
+			enc, mode, path, err := chooseEncryption(keyFile)
+			if err != nil {
+				return helpers.HandleCancelError(err)
+			}
+
+			if err := fs.Reencrypt(enc); err != nil {
+				return fmt.Errorf("failed to re-encrypt library: %w", err)
+			}
+
+			settings.Encryption = mode
+			settings.KeyFile = path
+			cfg.SetLibrary(activeLibrary, settings)
+			if err := config.Save(cfg); err != nil {
+				return err
+			}
+
+			fmt.Println("✅ Library re-encrypted with the new key.")
+			syncAfterReencrypt(fs)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&keyFile, "key-file", "", "Switch to a key file instead of a passphrase")
+
+	return cmd
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tom-023/ubm/internal/exporter"
+	"github.com/tom-023/ubm/internal/storage"
+)
+
+func exportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export bookmarks for browsers and other tools",
+		Long:  `Export the library, or one category of it, in a format other tools can import.`,
+	}
+
+	cmd.AddCommand(
+		exportHTMLCmd(),
+		exportCSVCmd(),
+	)
+
+	return cmd
+}
+
+func exportHTMLCmd() *cobra.Command {
+	var categoryPath, output string
+
+	cmd := &cobra.Command{
+		Use:   "html",
+		Short: "Export bookmarks as a bookmark HTML file",
+		Long: `Export bookmarks as a Netscape bookmark file, the HTML format every browser
+imports. Categories become folders, with the creation and update times, tags and
+descriptions of every bookmark. The file is written to standard output unless
+--output is given.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return export(output, func(w io.Writer, data *storage.Data) (int, error) {
+				return exporter.WriteHTML(w, data, categoryPath)
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&categoryPath, "category", "", "Only export this category and its subcategories")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "File to write instead of standard output")
+
+	return cmd
+}
+
+func exportCSVCmd() *cobra.Command {
+	var categoryPath, output, delimiter string
+
+	cmd := &cobra.Command{
+		Use:   "csv",
+		Short: "Export bookmarks as a CSV or TSV file",
+		Long: `Export bookmarks as CSV, with a header row and the columns title, url,
+category, tags, description, keyword, created_at, updated_at and id. Tags share
+one cell, separated by commas, and times are RFC 3339. An --output file ending in
+.tsv is written as TSV. The file is written to standard output unless --output is
+given, and ubm import csv reads it back.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			comma, err := csvDelimiter(delimiter, output)
+			if err != nil {
+				return err
+			}
+
+			return export(output, func(w io.Writer, data *storage.Data) (int, error) {
+				return exporter.WriteCSV(w, data, categoryPath, comma)
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&categoryPath, "category", "", "Only export this category and its subcategories")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "File to write instead of standard output")
+	cmd.Flags().StringVar(&delimiter, "delimiter", "", "Column delimiter, such as ';' or tab (default: tab for .tsv files, otherwise ',')")
+
+	return cmd
+}
+
+// export writes the library with write to output, or to standard output if
+// it is empty
+func export(output string, write func(w io.Writer, data *storage.Data) (int, error)) error {
+	data, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+
+	if output == "" {
+		_, err := write(os.Stdout, data)
+		return err
+	}
+
+	// Export into memory first, so a failed export leaves no file behind
+	var buf bytes.Buffer
+	n, err := write(&buf, data)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(output, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+
+	fmt.Printf("✅ Exported %d bookmark(s) to %s.\n", n, output)
+	return nil
+}
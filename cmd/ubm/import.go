@@ -0,0 +1,286 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tom-023/ubm/internal/importer"
+	"github.com/tom-023/ubm/internal/storage"
+	"github.com/tom-023/ubm/internal/ui"
+)
+
+func importCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import bookmarks from browsers and other tools",
+		Long: `Import bookmarks into the library. A bookmark whose URL is already in its
+category is skipped and reported as a duplicate.`,
+	}
+
+	cmd.AddCommand(
+		importHTMLCmd(),
+		importChromeCmd(),
+		importFirefoxCmd(),
+		importCSVCmd(),
+	)
+
+	return cmd
+}
+
+func importHTMLCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "html <file>",
+		Short: "Import a bookmark HTML file exported by a browser",
+		Long: `Import a Netscape bookmark file, the HTML format every browser exports.
+Folders become categories, nested folders nested categories.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to open file: %w", err)
+			}
+			defer f.Close()
+
+			imp, err := importer.ParseHTML(f)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", args[0], err)
+			}
+			return importBookmarks(args[0], imp)
+		},
+	}
+}
+
+func importChromeCmd() *cobra.Command {
+	var profile string
+
+	cmd := &cobra.Command{
+		Use:   "chrome",
+		Short: "Import bookmarks from a Chrome, Edge, Brave or Vivaldi profile",
+		Long: `Import the Bookmarks file of a Chromium-based browser profile. The bookmarks
+bar, other bookmarks and mobile bookmarks become top-level categories, with their
+folders as subcategories.
+
+Without --profile, Google Chrome's default profile is used. Pass the profile
+directory (or its Bookmarks file) of another profile or browser with --profile.
+
+Importing again updates the bookmarks imported before, moving and renaming them
+as they were in the browser, instead of adding them twice.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := chromeBookmarksPath(profile)
+			if err != nil {
+				return err
+			}
+
+			f, err := os.Open(path)
+			if err != nil {
+				return fmt.Errorf("failed to open file: %w", err)
+			}
+			defer f.Close()
+
+			imp, err := importer.ParseChrome(f)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			return importBookmarks(path, imp)
+		},
+	}
+
+	cmd.Flags().StringVar(&profile, "profile", "", "Profile directory or Bookmarks file to import")
+
+	return cmd
+}
+
+func importFirefoxCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "firefox <places.sqlite>",
+		Short: "Import bookmarks from a Firefox profile",
+		Long: `Import the bookmarks in a Firefox places.sqlite database, given as the file
+or the profile directory holding it. The bookmarks toolbar, menu, other bookmarks
+and mobile bookmarks become top-level categories, with their folders as
+subcategories. Firefox tags become tags and keyword shortcuts keywords.
+
+Firefox can keep running: a snapshot of the database is read, never the file
+itself. Importing again updates the bookmarks imported before instead of adding
+them twice.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := args[0]
+			if info, err := os.Stat(path); err != nil {
+				return fmt.Errorf("failed to open file: %w", err)
+			} else if info.IsDir() {
+				path = filepath.Join(path, importer.FirefoxPlacesFile)
+			}
+
+			imp, err := importer.ReadFirefox(path)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			return importBookmarks(path, imp)
+		},
+	}
+}
+
+func importCSVCmd() *cobra.Command {
+	var columns, delimiter, header string
+
+	cmd := &cobra.Command{
+		Use:   "csv <file>",
+		Short: "Import bookmarks from a CSV or TSV file",
+		Long: `Import bookmarks from a CSV or TSV file, such as a spreadsheet, one per row.
+
+The columns are title, url, category, tags, description, keyword, created_at,
+updated_at and id. A header row names them, and common names such as Name, Link
+or Folder are understood; other columns are ignored. Without a header the columns
+are read in that order, or as given with --columns, where - skips a column:
+
+  ubm import csv links.csv --columns url,-,title,tags
+
+Tags share one cell, separated by commas, and categories are paths such as
+Work/Docs. Rows that cannot be imported, such as ones with an invalid URL, are
+listed with their line number while the rest are imported. Rows with an id
+update the bookmark they were exported from.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts := importer.CSVOptions{}
+
+			var err error
+			if opts.Comma, err = csvDelimiter(delimiter, args[0]); err != nil {
+				return err
+			}
+			if columns != "" {
+				opts.Columns = strings.Split(columns, ",")
+			}
+			switch header {
+			case "auto":
+				opts.Header = importer.DetectHeader
+			case "yes":
+				opts.Header = importer.WithHeader
+			case "no":
+				opts.Header = importer.WithoutHeader
+			default:
+				return fmt.Errorf("--header must be auto, yes or no")
+			}
+
+			f, err := os.Open(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to open file: %w", err)
+			}
+			defer f.Close()
+
+			imp, err := importer.ParseCSV(f, opts)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", args[0], err)
+			}
+			return importBookmarks(args[0], imp)
+		},
+	}
+
+	cmd.Flags().StringVar(&columns, "columns", "", "Comma-separated field of each column, - to skip one")
+	cmd.Flags().StringVar(&delimiter, "delimiter", "", "Column delimiter, such as ';' or tab (default: tab for .tsv files, otherwise ',')")
+	cmd.Flags().StringVar(&header, "header", "auto", "Whether the first row is a header: auto, yes or no")
+
+	return cmd
+}
+
+// csvDelimiter reads the --delimiter flag, guessing from the file name
+// when it is empty
+func csvDelimiter(flag, path string) (rune, error) {
+	switch flag {
+	case "":
+		if strings.EqualFold(filepath.Ext(path), ".tsv") {
+			return '\t', nil
+		}
+		return ',', nil
+	case "tab", `\t`:
+		return '\t', nil
+	}
+
+	runes := []rune(flag)
+	if len(runes) != 1 || runes[0] == '"' || runes[0] == '\r' || runes[0] == '\n' {
+		return 0, fmt.Errorf("invalid delimiter %q", flag)
+	}
+	return runes[0], nil
+}
+
+// chromeBookmarksPath finds the Bookmarks file of a profile directory,
+// Chrome's default profile if it is empty
+func chromeBookmarksPath(profile string) (string, error) {
+	if profile == "" {
+		dir, err := importer.DefaultChromeProfile()
+		if err != nil {
+			return "", fmt.Errorf("failed to find the Chrome profile: %w", err)
+		}
+		path := filepath.Join(dir, importer.ChromeBookmarksFile)
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("no Chrome bookmarks at %s, pass the profile directory with --profile", path)
+		}
+		return path, nil
+	}
+
+	info, err := os.Stat(profile)
+	if err != nil {
+		return "", fmt.Errorf("failed to open profile: %w", err)
+	}
+	if info.IsDir() {
+		return filepath.Join(profile, importer.ChromeBookmarksFile), nil
+	}
+	return profile, nil
+}
+
+// importBookmarks adds what was read from source in one write and reports
+// what was skipped
+func importBookmarks(source string, imp *importer.Import) error {
+	var result *importer.Result
+	err := store.Update(func(data *storage.Data) error {
+		var err error
+		result, err = importer.Apply(data, imp)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to import bookmarks: %w", err)
+	}
+
+	printImportResult(source, result)
+	return nil
+}
+
+func printImportResult(source string, result *importer.Result) {
+	fmt.Printf("✅ Imported %d bookmark(s) from %s.\n", len(result.Added), source)
+
+	if len(result.Updated) > 0 {
+		fmt.Printf("\nUpdated %d bookmark(s) changed since the last import:\n", len(result.Updated))
+		for _, b := range result.Updated {
+			fmt.Printf("  🔗 %s (%s) in %s\n", b.Title, b.URL, ui.FormatCategory(b.Category))
+		}
+	}
+	if len(result.Unchanged) > 0 {
+		fmt.Printf("%d bookmark(s) imported before are up to date.\n", len(result.Unchanged))
+	}
+	if len(result.Trashed) > 0 {
+		fmt.Printf("\nSkipped %d bookmark(s) in the trash, use 'ubm trash restore' to bring them back:\n", len(result.Trashed))
+		for _, b := range result.Trashed {
+			fmt.Printf("  🔗 %s (%s)\n", b.Title, b.URL)
+		}
+	}
+
+	if len(result.Duplicates) > 0 {
+		fmt.Printf("\nSkipped %d duplicate(s), already in their category:\n", len(result.Duplicates))
+		for _, b := range result.Duplicates {
+			fmt.Printf("  🔗 %s (%s) in %s\n", b.Title, b.URL, ui.FormatCategory(b.Category))
+		}
+	}
+	if len(result.Invalid) > 0 {
+		fmt.Printf("\nSkipped %d bookmark(s) that could not be imported:\n", len(result.Invalid))
+		for _, s := range result.Invalid {
+			if s.Bookmark.Title == "" {
+				fmt.Printf("  🔗 %v\n", s.Err)
+				continue
+			}
+			fmt.Printf("  🔗 %s: %v\n", s.Bookmark.Title, s.Err)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tom-023/ubm/internal/cmd/helpers"
+	"github.com/tom-023/ubm/internal/config"
+	"github.com/tom-023/ubm/internal/library"
+	"github.com/tom-023/ubm/internal/ui"
+)
+
+func libraryCmd(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "library",
+		Short: "Manage named bookmark libraries",
+		Long: `Keep separate bookmark libraries, e.g. for work and personal use. Each library has
+its own bookmarks, categories, backups, and history. Pick one for a single command
+with --library or UBM_LIBRARY, or change the one used by default with 'ubm library use'.`,
+		// Managing libraries must work even when the chosen one cannot be opened
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			dataDir, _ := cmd.Flags().GetString("data-dir")
+			if err := openDataDir(dataDir); err != nil {
+				return err
+			}
+			flag, _ := cmd.Flags().GetString("library")
+			activeLibrary = chooseLibrary(cfg, flag)
+			return nil
+		},
+	}
+
+	cmd.AddCommand(
+		libraryListCmd(cfg),
+		libraryCreateCmd(cfg),
+		libraryUseCmd(cfg),
+		libraryRenameCmd(cfg),
+		libraryDeleteCmd(cfg),
+	)
+
+	return cmd
+}
+
+func libraryListCmd(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:     "list",
+		Short:   "List libraries",
+		Aliases: []string{"ls"},
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			names, err := libraries.List()
+			if err != nil {
+				return err
+			}
+
+			fmt.Println("📚 Libraries:")
+			for _, name := range names {
+				marker := " "
+				if name == activeLibrary {
+					marker = "*"
+				}
+				note := ""
+				if name == cfg.Library {
+					note = " (default)"
+				}
+				fmt.Printf("  %s %s%s\n      %s\n", marker, name, note, libraries.Dir(name))
+			}
+			return nil
+		},
+	}
+}
+
+func libraryCreateCmd(cfg *config.Config) *cobra.Command {
+	var use bool
+
+	cmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create an empty library",
+		Long: `Create an empty library. It is stored in the same format and backend as the
+default library; use 'ubm --library <name> storage' to change that.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if err := libraries.Create(name); err != nil {
+				return err
+			}
+
+			def := cfg.ForLibrary(library.Default)
+			cfg.SetLibrary(name, config.LibraryConfig{
+				StorageFormat: def.StorageFormat,
+				Backend:       def.Backend,
+			})
+			if use {
+				cfg.Library = name
+			}
+			if err := config.Save(cfg); err != nil {
+				return err
+			}
+
+			fmt.Printf("✅ Library '%s' created.\n", name)
+			if use {
+				fmt.Println("It is now used by default.")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&use, "use", false, "Use the new library by default")
+
+	return cmd
+}
+
+func libraryUseCmd(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "use <name>",
+		Short: "Use a library by default",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if !libraries.Exists(name) {
+				return fmt.Errorf("library %q does not exist", name)
+			}
+
+			cfg.Library = name
+			if err := config.Save(cfg); err != nil {
+				return err
+			}
+
+			fmt.Printf("✅ Now using library '%s'.\n", name)
+			if env := os.Getenv("UBM_LIBRARY"); env != "" && env != name {
+				fmt.Printf("Note: UBM_LIBRARY is set and selects '%s' instead.\n", env)
+			}
+			return nil
+		},
+	}
+}
+
+func libraryRenameCmd(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rename <name> <new-name>",
+		Short: "Rename a library",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			from, to := args[0], args[1]
+			if err := libraries.Rename(from, to); err != nil {
+				return err
+			}
+
+			cfg.RenameLibrary(from, to)
+			if err := config.Save(cfg); err != nil {
+				return err
+			}
+
+			fmt.Printf("✅ Library '%s' renamed to '%s'.\n", from, to)
+			return nil
+		},
+	}
+}
+
+func libraryDeleteCmd(cfg *config.Config) *cobra.Command {
+	var skipConfirm bool
+
+	cmd := &cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete a library with its bookmarks, backups, and history",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if !libraries.Exists(name) {
+				return fmt.Errorf("library %q does not exist", name)
+			}
+			if name == activeLibrary {
+				return fmt.Errorf("library %q is in use; switch to another library first", name)
+			}
+
+			if !skipConfirm {
+				confirmMsg := fmt.Sprintf("Permanently delete library '%s' (%s)?", name, libraries.Dir(name))
+				confirm, err := ui.Confirm(confirmMsg)
+				if err != nil {
+					return helpers.HandleCancelError(err)
+				}
+				if !confirm {
+					fmt.Println("Deletion cancelled.")
+					return nil
+				}
+			}
+
+			if err := libraries.Delete(name, activeLibrary); err != nil {
+				return err
+			}
+
+			cfg.DeleteLibrary(name)
+			if err := config.Save(cfg); err != nil {
+				return err
+			}
+
+			fmt.Printf("🗑️  Library '%s' deleted.\n", name)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&skipConfirm, "confirm", "y", false, "Skip confirmation prompt")
+
+	return cmd
+}
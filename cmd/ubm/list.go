@@ -16,19 +16,19 @@ func listCmd() *cobra.Command {
 Use arrow keys to navigate, Enter to select, and q to quit.`,
 		Aliases: []string{"ls"},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			// Load bookmarks
-			data, categoryTree, err := helpers.LoadDataAndBuildTree(store)
+			// Load bookmarks, with the team library underneath
+			view, categoryTree, err := loadView()
 			if err != nil {
 				return err
 			}
 
-			if len(data.Bookmarks) == 0 {
+			if len(view.Data.Bookmarks) == 0 {
 				fmt.Println("No bookmarks found. Use 'ubm add' to add your first bookmark.")
 				return nil
 			}
 
 			// Start interactive navigation
-			if err := ui.NavigateBookmarks(categoryTree, data.Bookmarks); err != nil {
+			if err := ui.NavigateBookmarks(categoryTree, view.Data.Bookmarks, markTeam(view)); err != nil {
 				return helpers.HandleCancelError(err)
 			}
 
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/internal/storage"
+)
+
+func logCmd() *cobra.Command {
+	var (
+		since      string
+		bookmarkID string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "log",
+		Short: "Show the history of changes to the library",
+		Long: `Show every recorded change to the library, oldest first: added, edited,
+moved and deleted bookmarks as well as created and deleted categories.
+
+--since accepts a duration (90m, 24h, 7d), a date (2006-01-02) or an RFC 3339 timestamp.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			journaled, ok := store.(storage.Journaled)
+			if !ok {
+				return fmt.Errorf("this storage backend does not keep a journal")
+			}
+
+			filter := storage.JournalFilter{BookmarkID: bookmarkID}
+			if since != "" {
+				t, err := parseSince(since, time.Now())
+				if err != nil {
+					return err
+				}
+				filter.Since = t
+			}
+
+			entries, err := journaled.Journal().Entries(filter)
+			if err != nil {
+				return fmt.Errorf("failed to read journal: %w", err)
+			}
+
+			if len(entries) == 0 {
+				fmt.Println("No changes recorded.")
+				return nil
+			}
+
+			for _, e := range entries {
+				printJournalEntry(e)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&since, "since", "", "Only show changes after this time")
+	cmd.Flags().StringVar(&bookmarkID, "bookmark", "", "Only show changes to the bookmark with this ID")
+
+	return cmd
+}
+
+// parseSince accepts a relative duration with an optional day unit, a date,
+// or a full timestamp
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if strings.HasSuffix(value, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil {
+			return now.AddDate(0, 0, -days), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since value %q (use e.g. 24h, 7d, 2006-01-02)", value)
+}
+
+func printJournalEntry(e storage.JournalEntry) {
+	op := string(e.Op)
+	if e.Undoes != "" {
+		op += " (undo)"
+	} else if e.Redoes != "" {
+		op += " (redo)"
+	}
+	header := fmt.Sprintf("%s  %-8s  %-22s", e.Time.Local().Format("2006-01-02 15:04:05"), e.User, op)
+
+	switch {
+	case e.Op == storage.OpCategoryCreate || e.Op == storage.OpCategoryDelete:
+		fmt.Printf("%s  📁 %s\n", header, e.Category)
+	case e.Before == nil || e.After == nil:
+		// Bookmarks that appear or disappear are shown as a whole
+		b := e.After
+		if b == nil {
+			b = e.Before
+		}
+		fmt.Printf("%s  🔗 %s (%s)\n", header, b.Title, e.BookmarkID)
+		fmt.Printf("    %s\n", b.URL)
+	default:
+		fmt.Printf("%s  🔗 %s (%s)\n", header, e.After.Title, e.BookmarkID)
+		for _, change := range bookmarkChanges(e.Before, e.After) {
+			fmt.Printf("    %s\n", change)
+		}
+	}
+}
+
+// bookmarkChanges lists the user-visible fields that differ between two
+// versions of a bookmark
+func bookmarkChanges(before, after *bookmark.Bookmark) []string {
+	var changes []string
+	field := func(name, from, to string) {
+		if from != to {
+			changes = append(changes, fmt.Sprintf("%s: %s → %s", name, displayValue(from), displayValue(to)))
+		}
+	}
+
+	field("title", before.Title, after.Title)
+	field("url", before.URL, after.URL)
+	field("category", before.Category, after.Category)
+	field("tags", strings.Join(before.Tags, ", "), strings.Join(after.Tags, ", "))
+	field("description", before.Description, after.Description)
+	field("keyword", before.Keyword, after.Keyword)
+	return changes
+}
+
+func displayValue(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
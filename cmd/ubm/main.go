@@ -3,15 +3,29 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/tom-023/ubm/internal/config"
+	"github.com/tom-023/ubm/internal/gitsync"
+	"github.com/tom-023/ubm/internal/library"
 	"github.com/tom-023/ubm/internal/storage"
 )
 
 var (
 	version = "1.0.0"
-	store   *storage.Storage
+	store   storage.Backend
+
+	// libraries manages the libraries in the data directory
+	libraries *library.Manager
+	// activeLibrary is the library the command works on and libraryDir the
+	// directory that holds it
+	activeLibrary string
+	libraryDir    string
+	// teamLibrary is the read-only team library shown under it, if any
+	teamLibrary string
+	// libraryRemote is the WebDAV or HTTP location ubm sync uses, if any
+	libraryRemote config.RemoteConfig
 )
 
 func main() {
@@ -20,26 +34,50 @@ func main() {
 		os.Exit(1)
 	}
 
-	configDir, err := config.GetConfigDir()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error getting config directory: %v\n", err)
-		os.Exit(1)
-	}
-
-	store, err = storage.New(configDir)
+	cfg, err := config.Load()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error initializing storage: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
 		os.Exit(1)
 	}
 
+	var (
+		recoverLibrary bool
+		libraryFlag    string
+		dataDirFlag    string
+	)
 	rootCmd := &cobra.Command{
 		Use:   "ubm",
 		Short: "URL Bookmark Manager - Interactive command-line bookmark manager",
 		Long: `ubm is a command-line URL bookmark manager with interactive directory navigation.
 It allows you to organize your bookmarks in a tree-like structure and access them quickly.`,
 		Version: version,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := openDataDir(dataDirFlag); err != nil {
+				return err
+			}
+			if err := openLibrary(cfg, libraryFlag); err != nil {
+				return err
+			}
+
+			if err := checkLibrary(cmd, recoverLibrary); err != nil {
+				return err
+			}
+
+			// Bookmarks older than trash_retention_days are purged; 0 keeps them
+			if cfg.TrashDays > 0 {
+				maxAge := time.Duration(cfg.TrashDays) * 24 * time.Hour
+				if _, err := storage.PurgeExpiredTrash(store, maxAge); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: failed to purge expired trash: %v\n", err)
+				}
+			}
+			return nil
+		},
 	}
 
+	rootCmd.PersistentFlags().BoolVar(&recoverLibrary, "recover", false, "Restore a corrupt library from the newest valid backup without asking")
+	rootCmd.PersistentFlags().StringVar(&libraryFlag, "library", "", "Library to use instead of the default one (also UBM_LIBRARY)")
+	rootCmd.PersistentFlags().StringVar(&dataDirFlag, "data-dir", "", "Directory the libraries are kept in (also UBM_DATA_DIR)")
+
 	rootCmd.AddCommand(
 		addCmd(),
 		listCmd(),
@@ -48,12 +86,148 @@ It allows you to organize your bookmarks in a tree-like structure and access the
 		moveCmd(),
 		deleteCmd(),
 		editCmd(),
-		// importCmd(),
-		// exportCmd(),
+		backupCmd(cfg),
+		storageCmd(cfg),
+		logCmd(),
+		undoCmd(),
+		redoCmd(),
+		trashCmd(),
+		searchCmd(),
+		doctorCmd(),
+		libraryCmd(cfg),
+		teamCmd(),
+		syncCmd(),
+		mergeCmd(),
+		importCmd(),
+		exportCmd(),
 	)
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
-}
\ No newline at end of file
+}
+
+// chooseLibrary returns the library chosen with --library, UBM_LIBRARY or the
+// library setting, in that order
+func chooseLibrary(cfg *config.Config, flag string) string {
+	if flag != "" {
+		return flag
+	}
+	if name := os.Getenv("UBM_LIBRARY"); name != "" {
+		return name
+	}
+	return cfg.Library
+}
+
+// remoteFromEnv overrides the remote settings with UBM_REMOTE_URL,
+// UBM_REMOTE_USERNAME and UBM_REMOTE_PASSWORD, so credentials need not be
+// kept in config.yaml
+func remoteFromEnv(remote config.RemoteConfig) config.RemoteConfig {
+	if url := os.Getenv("UBM_REMOTE_URL"); url != "" {
+		remote.URL = url
+	}
+	if username := os.Getenv("UBM_REMOTE_USERNAME"); username != "" {
+		remote.Username = username
+	}
+	if password := os.Getenv("UBM_REMOTE_PASSWORD"); password != "" {
+		remote.Password = password
+	}
+	return remote
+}
+
+// openDataDir finds the directory the libraries are kept in, moving them
+// there from where older versions kept them
+func openDataDir(flag string) error {
+	dir, err := config.GetDataDir(flag)
+	if err != nil {
+		return err
+	}
+
+	from, err := config.MigrateData(dir)
+	if err != nil {
+		return fmt.Errorf("failed to move bookmarks to the data directory: %w", err)
+	}
+	if from != "" {
+		fmt.Fprintf(os.Stderr, "Moved bookmarks from %s to %s\n", from, dir)
+	}
+
+	libraries = library.NewManager(dir)
+	return nil
+}
+
+func openLibrary(cfg *config.Config, flag string) error {
+	name := chooseLibrary(cfg, flag)
+	if !libraries.Exists(name) {
+		return fmt.Errorf("library %q does not exist (create it with ubm library create %s)", name, name)
+	}
+
+	settings := cfg.ForLibrary(name)
+	s, err := newStore(libraries.Dir(name), cfg, settings)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	activeLibrary = name
+	libraryDir = libraries.Dir(name)
+	teamLibrary = settings.TeamLibrary
+	libraryRemote = remoteFromEnv(settings.Remote)
+	store = s
+	return nil
+}
+
+func newStore(dir string, cfg *config.Config, settings config.LibraryConfig) (storage.Backend, error) {
+	backend, err := storage.ParseBackend(settings.Backend)
+	if err != nil {
+		return nil, err
+	}
+	if backend == storage.BackendBolt {
+		if settings.Encryption != "" {
+			return nil, fmt.Errorf("encryption requires the file backend")
+		}
+		if settings.Git {
+			return nil, fmt.Errorf("git mode requires the file backend")
+		}
+		return storage.NewBolt(dir)
+	}
+
+	return newFileStore(dir, cfg, settings)
+}
+
+func newFileStore(dir string, cfg *config.Config, settings config.LibraryConfig) (*storage.Storage, error) {
+	format, err := storage.ParseFormat(settings.StorageFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	enc, err := newEncryption(settings.Encryption, settings.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := storage.Options{
+		AutoBackup: cfg.AutoBackup,
+		MaxBackups: cfg.MaxBackups,
+		Format:     format,
+		Encryption: enc,
+	}
+	if settings.Git {
+		repo, err := gitsync.Open(dir)
+		if err != nil {
+			return nil, fmt.Errorf("git mode is on: %w", err)
+		}
+		opts.Committer = repo
+	}
+
+	return storage.NewWithOptions(dir, opts)
+}
+
+// fileStore returns the file backend for commands that manage the
+// storage files directly, such as backups
+func fileStore() (*storage.Storage, error) {
+	s, ok := store.(*storage.Storage)
+	if !ok {
+		return nil, fmt.Errorf("this command requires the file storage backend")
+	}
+	return s, nil
+}
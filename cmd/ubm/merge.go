@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tom-023/ubm/internal/storage"
+	"github.com/tom-023/ubm/internal/ui"
+	"golang.org/x/term"
+)
+
+func mergeCmd() *cobra.Command {
+	var (
+		basePath string
+		auto     bool
+		dryRun   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "merge <other.json>",
+		Short: "Merge another library file into this one",
+		Long: `Merge another copy of the library, such as one from a different machine or a
+backup, into this one. Bookmarks are matched by ID, or by URL when the IDs
+differ, and changes to different fields are combined automatically.
+
+With --base, the common ancestor of both copies, deletions and edits on each
+side are told apart. Without it every difference between the copies is a
+conflict and nothing is deleted.
+
+Conflicts are resolved interactively; --auto keeps the newer value instead,
+and an edit over a deletion.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			theirs, err := readLibrary(args[0])
+			if err != nil {
+				return err
+			}
+			base := &storage.Data{}
+			if basePath != "" {
+				if base, err = readLibrary(basePath); err != nil {
+					return err
+				}
+			}
+
+			ours, err := store.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load bookmarks: %w", err)
+			}
+
+			var resolve storage.ConflictResolver
+			if !auto && !dryRun {
+				if !term.IsTerminal(int(os.Stdin.Fd())) {
+					resolve = func(*storage.MergeConflict) error {
+						return fmt.Errorf("both sides changed the same bookmark; run in a terminal to choose, or use --auto")
+					}
+				} else {
+					resolve = resolveMergeConflict
+				}
+			}
+
+			result, err := storage.MergeWith(base, ours, theirs, resolve)
+			if err != nil {
+				return err
+			}
+
+			changes := storage.Diff(ours, result.Data)
+			if len(changes) == 0 {
+				fmt.Println("Already up to date.")
+				return nil
+			}
+
+			if dryRun {
+				for _, c := range result.Conflicts {
+					printMergeConflict(c.Ours, c.Theirs, c.Resolved)
+				}
+				fmt.Printf("Merging %s would make %d change(s):\n", args[0], len(changes))
+				for _, e := range changes {
+					printMergeChange(e)
+				}
+				return nil
+			}
+
+			err = storage.UpdateFrom(store, ours, func(data *storage.Data) error {
+				data.Bookmarks = result.Data.Bookmarks
+				data.Categories = result.Data.Categories
+				data.Trash = result.Data.Trash
+				return nil
+			})
+			if errors.Is(err, storage.ErrConflict) {
+				return fmt.Errorf("the library was changed while merging; nothing was saved, run ubm merge again")
+			}
+			if err != nil {
+				return fmt.Errorf("failed to save merged bookmarks: %w", err)
+			}
+
+			fmt.Printf("✅ Merged %s: %d change(s)", args[0], len(changes))
+			if len(result.Conflicts) > 0 {
+				fmt.Printf(", %d conflict(s) resolved", len(result.Conflicts))
+			}
+			fmt.Println(".")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&basePath, "base", "", "Library file both copies started from")
+	cmd.Flags().BoolVar(&auto, "auto", false, "Resolve conflicts without asking")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would change without saving")
+
+	return cmd
+}
+
+// readLibrary reads a library file to merge. Files of this library, such as
+// backups, may be encrypted with its key.
+func readLibrary(path string) (*storage.Data, error) {
+	fs, ok := store.(*storage.Storage)
+	if !ok {
+		return storage.ReadFile(path)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	data, err := fs.Decode(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return data, nil
+}
+
+// resolveMergeConflict asks which side to keep for every field both sides
+// changed, or whether to keep a bookmark one side deleted
+func resolveMergeConflict(c *storage.MergeConflict) error {
+	edited := c.Resolved
+	fmt.Printf("\n⚠️  '%s' (%s) was changed on both sides.\n", edited.Title, edited.URL)
+
+	if len(c.Fields) == 0 {
+		keep, remove := "Keep my changed version", "Delete it, as they did"
+		if c.Ours == nil {
+			keep, remove = "Keep their changed version", "Delete it, as I did"
+		}
+		if c.Base != nil {
+			for _, change := range bookmarkChanges(c.Base, edited) {
+				fmt.Printf("    %s\n", change)
+			}
+		}
+		i, err := ui.Choose("It was deleted on one side. What do you want to do?", []string{keep, remove})
+		if err != nil {
+			return err
+		}
+		if i == 1 {
+			c.Resolved = nil
+		}
+		return nil
+	}
+
+	for _, field := range c.Fields {
+		i, err := ui.Choose(fmt.Sprintf("Which %s do you want to keep?", field), []string{
+			"Mine:   " + displayValue(storage.FieldValue(c.Ours, field)),
+			"Theirs: " + displayValue(storage.FieldValue(c.Theirs, field)),
+		})
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			c.Take(field, c.Ours)
+		} else {
+			c.Take(field, c.Theirs)
+		}
+	}
+	return nil
+}
+
+// printMergeChange shows one change a merge would make
+func printMergeChange(e storage.JournalEntry) {
+	switch {
+	case e.Op == storage.OpCategoryCreate || e.Op == storage.OpCategoryDelete:
+		fmt.Printf("  %-16s 📁 %s\n", e.Op, e.Category)
+	case e.Before == nil || e.After == nil:
+		b := e.After
+		if b == nil {
+			b = e.Before
+		}
+		fmt.Printf("  %-16s 🔗 %s (%s)\n", e.Op, b.Title, b.URL)
+	default:
+		fmt.Printf("  %-16s 🔗 %s\n", e.Op, e.After.Title)
+		for _, change := range bookmarkChanges(e.Before, e.After) {
+			fmt.Printf("      %s\n", change)
+		}
+	}
+}
@@ -50,20 +50,12 @@ func moveCmd() *cobra.Command {
 				return nil
 			}
 
-			// Update bookmark category
-			targetBookmark.SetCategory(newCategory)
-
-			// Add new category if it doesn't exist
-			helpers.EnsureCategoryExists(data, newCategory)
-
-			// Update bookmark
-			if err := store.UpdateBookmark(targetBookmark); err != nil {
-				return fmt.Errorf("failed to update bookmark: %w", err)
-			}
-
-			// Save categories if new one was added
-			if err := store.Save(data); err != nil {
-				return fmt.Errorf("failed to save data: %w", err)
+			// Move the bookmark and register the category in a single write,
+			// unless the library changed meanwhile
+			moved := *targetBookmark
+			moved.Category = newCategory
+			if _, err := commitBookmarkEdit(data, newBookmarkEdit(targetBookmark, &moved)); err != nil {
+				return helpers.HandleCancelError(fmt.Errorf("failed to move bookmark: %w", err))
 			}
 
 			fmt.Printf("\n✅ Bookmark moved successfully!\n")
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/tom-023/ubm/internal/storage"
+	"github.com/tom-023/ubm/internal/ui"
+	"golang.org/x/term"
+)
+
+// checkLibrary runs before every command but the backup ones. If the library
+// file is corrupt it offers to restore the newest readable backup, or does so
+// without asking when autoRecover is set. Declining leaves the file alone so
+// backup commands can still be used to inspect it.
+func checkLibrary(cmd *cobra.Command, autoRecover bool) error {
+	fs, ok := store.(*storage.Storage)
+	if !ok {
+		return nil
+	}
+	// Backup commands are how a corrupt library is dealt with by hand, so
+	// they must work without a terminal too
+	if !autoRecover && isBackupCommand(cmd) {
+		return nil
+	}
+
+	err := fs.Check()
+	var corrupt *storage.CorruptError
+	if !errors.As(err, &corrupt) {
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "⚠️  %s is corrupt: %v\n", filepath.Base(corrupt.Path), corrupt.Err)
+
+	if !autoRecover {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return fmt.Errorf("library is corrupt; run again with --recover to restore the newest valid backup")
+		}
+		confirm, err := ui.Confirm("Restore from the newest valid backup?")
+		if err != nil || !confirm {
+			return nil
+		}
+	}
+
+	result, err := fs.Recover()
+	if err != nil {
+		return fmt.Errorf("failed to recover library: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "✅ Restored the backup from %s", result.Backup.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+	if result.Skipped > 0 {
+		fmt.Fprintf(os.Stderr, " (%d newer backup(s) were unreadable)", result.Skipped)
+	}
+	fmt.Fprintf(os.Stderr, ".\n   The corrupt file was kept as %s\n", result.Quarantined)
+	return nil
+}
+
+func isBackupCommand(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c.Name() == "backup" {
+			return true
+		}
+	}
+	return false
+}
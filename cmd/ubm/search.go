@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/internal/ui"
+)
+
+func searchCmd() *cobra.Command {
+	var includeTrash bool
+
+	cmd := &cobra.Command{
+		Use:   "search <query>",
+		Short: "Search bookmarks by title, URL, description, or keyword",
+		Long: `Search bookmarks by title, URL, description, or keyword, ignoring case.
+Trashed bookmarks are left out unless --trash is given.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query := args[0]
+
+			// Team bookmarks that have not been forked are searched as well
+			var (
+				results []*bookmark.Bookmark
+				mark    ui.BookmarkMarker
+			)
+			if teamLibrary != "" {
+				view, _, err := loadView()
+				if err != nil {
+					return err
+				}
+				results = view.Data.Search(query)
+				mark = markTeam(view)
+			} else {
+				var err error
+				results, err = store.SearchBookmarks(query)
+				if err != nil {
+					return fmt.Errorf("failed to search bookmarks: %w", err)
+				}
+			}
+
+			found := len(results)
+			for _, b := range results {
+				fmt.Printf("%s (%s)\n", bookmarkLine(b, mark), b.ID)
+				fmt.Printf("    %s  in %s\n", b.URL, ui.FormatCategory(b.Category))
+			}
+
+			if includeTrash {
+				data, err := store.Load()
+				if err != nil {
+					return fmt.Errorf("failed to load bookmarks: %w", err)
+				}
+				for _, t := range data.SearchTrash(query) {
+					found++
+					fmt.Printf("🗑️  %s (%s)\n", t.Bookmark.Title, t.Bookmark.ID)
+					fmt.Printf("    %s  in %s, deleted %s\n", t.Bookmark.URL, ui.FormatCategory(t.Bookmark.Category), t.DeletedAt.Local().Format("2006-01-02 15:04"))
+				}
+			}
+
+			if found == 0 {
+				fmt.Printf("No bookmarks matching %q.\n", query)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&includeTrash, "trash", false, "Also search bookmarks in the trash")
+
+	return cmd
+}
@@ -16,29 +16,26 @@ func showCmd() *cobra.Command {
 		Short: "Display all bookmarks in tree format",
 		Long:  `Display all bookmarks organized by their categories in a tree structure.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			// Load bookmarks
-			data, err := store.Load()
+			// Load bookmarks, with the team library underneath
+			view, tree, err := loadView()
 			if err != nil {
-				return fmt.Errorf("failed to load bookmarks: %w", err)
+				return err
 			}
 
-			if len(data.Bookmarks) == 0 {
+			if len(view.Data.Bookmarks) == 0 {
 				fmt.Println("No bookmarks found. Use 'ubm add' to add your first bookmark.")
 				return nil
 			}
 
 			// Display tree structure
-			displayTree(data)
+			displayTree(view.Data, tree, markTeam(view))
 
 			return nil
 		},
 	}
 }
 
-func displayTree(data *storage.Data) {
-	// Build category tree
-	tree := ui.BuildCategoryTree(data)
-
+func displayTree(data *storage.Data, tree *category.Node, mark ui.BookmarkMarker) {
 	// Group bookmarks by category
 	bookmarksByCategory := make(map[string][]*bookmark.Bookmark)
 	for _, b := range data.Bookmarks {
@@ -46,10 +43,20 @@ func displayTree(data *storage.Data) {
 	}
 
 	fmt.Println("📚 Bookmarks:")
-	printNode(tree, "", true, bookmarksByCategory)
+	printNode(tree, "", true, bookmarksByCategory, mark)
+}
+
+// bookmarkLine formats a bookmark for the tree and search results. mark may be nil.
+func bookmarkLine(b *bookmark.Bookmark, mark ui.BookmarkMarker) string {
+	if mark != nil {
+		if note := mark(b); note != "" {
+			return fmt.Sprintf("🔗 %s %s", b.Title, note)
+		}
+	}
+	return fmt.Sprintf("🔗 %s", b.Title)
 }
 
-func printNode(node *category.Node, prefix string, isLast bool, bookmarksByCategory map[string][]*bookmark.Bookmark) {
+func printNode(node *category.Node, prefix string, isLast bool, bookmarksByCategory map[string][]*bookmark.Bookmark, mark ui.BookmarkMarker) {
 	if !node.IsRoot {
 		connector := "├── "
 		if isLast {
@@ -74,7 +81,7 @@ func printNode(node *category.Node, prefix string, isLast bool, bookmarksByCateg
 				if i == len(bookmarks)-1 && len(node.Children) == 0 {
 					bookmarkConnector = "└── "
 				}
-				fmt.Printf("%s%s🔗 %s\n", childPrefix, bookmarkConnector, b.Title)
+				fmt.Printf("%s%s%s\n", childPrefix, bookmarkConnector, bookmarkLine(b, mark))
 			}
 		}
 	}
@@ -92,7 +99,7 @@ func printNode(node *category.Node, prefix string, isLast bool, bookmarksByCateg
 	// Print children
 	for i, child := range node.Children {
 		isLastChild := i == len(node.Children)-1
-		printNode(child, childPrefix, isLastChild, bookmarksByCategory)
+		printNode(child, childPrefix, isLastChild, bookmarksByCategory, mark)
 	}
 
 	// Print uncategorized bookmarks at root level
@@ -104,7 +111,7 @@ func printNode(node *category.Node, prefix string, isLast bool, bookmarksByCateg
 				if i == len(bookmarks)-1 {
 					bookmarkConnector = "└── "
 				}
-				fmt.Printf("%s    %s🔗 %s\n", prefix, bookmarkConnector, b.Title)
+				fmt.Printf("%s    %s%s\n", prefix, bookmarkConnector, bookmarkLine(b, mark))
 			}
 		}
 	}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/tom-023/ubm/internal/config"
+	"github.com/tom-023/ubm/internal/storage"
+)
+
+func storageCmd(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "storage",
+		Short: "Manage how the bookmark library is stored",
+		Long:  `Manage the on-disk representation of the bookmark library.`,
+	}
+
+	cmd.AddCommand(
+		storageConvertCmd(cfg),
+		storageMigrateCmd(cfg),
+		storageEncryptCmd(cfg),
+		storageDecryptCmd(cfg),
+		storageRekeyCmd(cfg),
+	)
+
+	return cmd
+}
+
+func storageConvertCmd(cfg *config.Config) *cobra.Command {
+	var to string
+
+	cmd := &cobra.Command{
+		Use:   "convert",
+		Short: "Convert the library between JSON and YAML",
+		Long: `Rewrite the bookmark library in another format without changing its content.
+YAML (bookmarks.yaml) is easier to diff and edit by hand, e.g. in a dotfiles repository.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fs, err := fileStore()
+			if err != nil {
+				return err
+			}
+
+			format, err := storage.ParseFormat(to)
+			if err != nil {
+				return err
+			}
+
+			from := fs.Format()
+			if err := fs.Convert(format); err != nil {
+				return fmt.Errorf("failed to convert library: %w", err)
+			}
+
+			// Remember the choice for this library
+			settings := cfg.ForLibrary(activeLibrary)
+			settings.StorageFormat = string(format)
+			cfg.SetLibrary(activeLibrary, settings)
+			if err := config.Save(cfg); err != nil {
+				return err
+			}
+
+			fmt.Printf("✅ Library converted from %s to %s.\n", from, format)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&to, "to", "", "Target format: json or yaml")
+	cmd.MarkFlagRequired("to")
+
+	return cmd
+}
+
+func storageMigrateCmd(cfg *config.Config) *cobra.Command {
+	var (
+		to    string
+		force bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Move the library to another storage backend",
+		Long: `Copy the bookmark library to another storage backend and switch to it.
+The bolt backend keeps bookmarks in an indexed database (bookmarks.db) so
+lookups stay fast for large libraries. The source is left in place.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			target, err := storage.ParseBackend(to)
+			if err != nil {
+				return err
+			}
+
+			settings := cfg.ForLibrary(activeLibrary)
+			current, err := storage.ParseBackend(settings.Backend)
+			if err != nil {
+				return err
+			}
+			if target == current {
+				return fmt.Errorf("library already uses the %s backend", target)
+			}
+			if target == storage.BackendBolt && settings.Encryption != "" {
+				return fmt.Errorf("encryption requires the file backend; run ubm storage decrypt first")
+			}
+			if target == storage.BackendBolt && settings.Git {
+				return fmt.Errorf("git mode requires the file backend; turn it off in config.yaml first")
+			}
+
+			var dst storage.Backend
+			if target == storage.BackendBolt {
+				dst, err = storage.NewBolt(libraryDir)
+				if err != nil {
+					return err
+				}
+			} else {
+				dst, err = newFileStore(libraryDir, cfg, settings)
+				if err != nil {
+					return err
+				}
+			}
+
+			// Never silently overwrite a library left over from an earlier migration
+			existing, err := dst.Load()
+			if err != nil {
+				return err
+			}
+			if len(existing.Bookmarks) > 0 && !force {
+				return fmt.Errorf("the %s backend already contains %d bookmarks (use --force to overwrite)", target, len(existing.Bookmarks))
+			}
+
+			if err := storage.Copy(dst, store); err != nil {
+				return err
+			}
+
+			settings.Backend = target
+			cfg.SetLibrary(activeLibrary, settings)
+			if err := config.Save(cfg); err != nil {
+				return err
+			}
+
+			data, err := dst.Load()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("✅ Migrated %d bookmarks from %s to %s.\n", len(data.Bookmarks), current, target)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&to, "to", "", "Target backend: file or bolt")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite a non-empty target library")
+	cmd.MarkFlagRequired("to")
+
+	return cmd
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/internal/gitsync"
+	"github.com/tom-023/ubm/internal/httpsync"
+	"github.com/tom-023/ubm/internal/storage"
+	"github.com/tom-023/ubm/internal/ui"
+)
+
+func syncCmd() *cobra.Command {
+	var remote string
+
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Pull, merge, and push the library with git or a WebDAV server",
+		Long: `Synchronize a library with a copy on a WebDAV or HTTP server, if remote.url is
+set in config.yaml or UBM_REMOTE_URL, and otherwise with the remote of the git
+repository it is kept in. Changes on both sides are merged bookmark by
+bookmark, so bookmarks added on different machines never conflict. Set
+git: true in config.yaml to commit every change as it is made.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fs, err := fileStore()
+			if err != nil {
+				return err
+			}
+
+			if libraryRemote.URL != "" {
+				return syncHTTP(fs)
+			}
+
+			repo, err := gitsync.Open(libraryDir)
+			if err != nil {
+				return err
+			}
+
+			result, err := gitsync.Sync(repo, fs, remote)
+			if err != nil {
+				return fmt.Errorf("failed to sync: %w", err)
+			}
+
+			for _, c := range result.Conflicts {
+				printMergeConflict(c.Ours, c.Theirs, c.Resolved)
+			}
+
+			switch {
+			case result.Merged:
+				fmt.Printf("✅ Merged remote changes and pushed to %s.\n", remote)
+			case result.Pulled:
+				fmt.Printf("✅ Pulled changes from %s.\n", remote)
+			case result.Pushed:
+				fmt.Printf("✅ Pushed to %s.\n", remote)
+			default:
+				fmt.Println("Already up to date.")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&remote, "remote", "origin", "git remote to sync with")
+
+	return cmd
+}
+
+func syncHTTP(fs *storage.Storage) error {
+	client := httpsync.NewClient(libraryRemote.URL, libraryRemote.Username, libraryRemote.Password)
+	result, err := httpsync.Sync(client, fs)
+	if err != nil {
+		return fmt.Errorf("failed to sync: %w", err)
+	}
+
+	if result.BaseLost {
+		fmt.Println("⚠️  The library as of the last sync could not be read, so bookmarks deleted since then may have come back.")
+	}
+	for _, c := range result.Conflicts {
+		printMergeConflict(c.Ours, c.Theirs, c.Resolved)
+	}
+
+	switch {
+	case result.Merged && result.Pushed:
+		fmt.Println("✅ Merged remote changes and uploaded the result.")
+	case result.Pulled:
+		fmt.Println("✅ Downloaded remote changes.")
+	case result.Pushed:
+		fmt.Println("✅ Uploaded the library.")
+	default:
+		fmt.Println("Already up to date.")
+	}
+	return nil
+}
+
+// syncAfterReencrypt replaces the copy on the sync server, which is still
+// stored the old way, after the library was encrypted, decrypted or rekeyed
+func syncAfterReencrypt(fs *storage.Storage) {
+	if libraryRemote.URL == "" {
+		return
+	}
+	if err := syncHTTP(fs); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  %v; run 'ubm sync' to replace the copy on the server\n", err)
+	}
+}
+
+// printMergeConflict explains which version of a bookmark changed on both
+// sides was kept
+func printMergeConflict(ours, theirs, kept *bookmark.Bookmark) {
+	name := func(b *bookmark.Bookmark) string {
+		if b == nil {
+			return "deleted"
+		}
+		return fmt.Sprintf("'%s' (%s) in %s", b.Title, b.URL, ui.FormatCategory(b.Category))
+	}
+
+	fmt.Println("⚠️  Changed on both sides:")
+	fmt.Printf("    local:  %s\n", name(ours))
+	fmt.Printf("    remote: %s\n", name(theirs))
+	fmt.Printf("    kept:   %s\n", name(kept))
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/internal/category"
+	"github.com/tom-023/ubm/internal/cmd/helpers"
+	"github.com/tom-023/ubm/internal/storage"
+	"github.com/tom-023/ubm/internal/team"
+	"github.com/tom-023/ubm/internal/ui"
+)
+
+// teamMarker is shown after the title of bookmarks from the team library
+const teamMarker = "[team]"
+
+// loadView loads the library with the team library, if one is set, layered
+// under it. An unreadable team library, e.g. on an unmounted share, only
+// costs the team bookmarks.
+func loadView() (*team.View, *category.Node, error) {
+	data, err := store.Load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load data: %w", err)
+	}
+
+	var teamData *storage.Data
+	if teamLibrary != "" {
+		teamData, err = team.Load(teamLibrary)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	view := team.Merge(data, teamData)
+	return view, ui.BuildCategoryTree(view.Data), nil
+}
+
+// markTeam returns a ui.BookmarkMarker for the team bookmarks in view
+func markTeam(view *team.View) ui.BookmarkMarker {
+	return func(b *bookmark.Bookmark) string {
+		if view.IsTeam(b) {
+			return teamMarker
+		}
+		return ""
+	}
+}
+
+// readOnlyError explains why a bookmark that is not in the library cannot be
+// changed if it comes from the team library, and returns err otherwise
+func readOnlyError(id string, err error) error {
+	if teamLibrary == "" {
+		return err
+	}
+	teamData, loadErr := team.Load(teamLibrary)
+	if loadErr != nil || teamData.FindBookmark(id) == nil {
+		return err
+	}
+	return fmt.Errorf("bookmark %s belongs to the read-only team library; fork it first with 'ubm team fork %s'", id, id)
+}
+
+func teamCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "team",
+		Short: "Work with the shared team library",
+		Long: `A team library is a bookmark file shared by a team, e.g. checked into a repository.
+Set team_library in config.yaml to show its bookmarks, marked ` + teamMarker + `, in list, show,
+and search. Team bookmarks are read-only; fork one to change it in your own library.`,
+	}
+
+	cmd.AddCommand(teamForkCmd())
+
+	return cmd
+}
+
+func teamForkCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "fork [ID]",
+		Short: "Copy a team bookmark into your library",
+		Long: `Copy a team bookmark into your own library by ID or select it interactively.
+The copy takes the place of the team bookmark and can be edited, moved, or deleted.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if teamLibrary == "" {
+				return fmt.Errorf("no team library is configured (set team_library in config.yaml)")
+			}
+
+			view, _, err := loadView()
+			if err != nil {
+				return err
+			}
+			if view.Team == nil {
+				return fmt.Errorf("the team library could not be loaded")
+			}
+
+			var bookmarkID string
+			if len(args) > 0 {
+				bookmarkID = args[0]
+			} else {
+				// Only team bookmarks that have not been forked yet can be chosen
+				teamOnly := &storage.Data{Categories: view.Team.Categories}
+				for _, b := range view.Data.Bookmarks {
+					if view.IsTeam(b) {
+						teamOnly.Bookmarks = append(teamOnly.Bookmarks, b)
+					}
+				}
+				if len(teamOnly.Bookmarks) == 0 {
+					fmt.Println("No team bookmarks left to fork.")
+					return nil
+				}
+
+				b, err := ui.NavigateAndSelectBookmark(ui.BuildCategoryTree(teamOnly), teamOnly.Bookmarks, "Select team bookmark to fork")
+				if err != nil {
+					return helpers.HandleCancelError(err)
+				}
+				bookmarkID = b.ID
+			}
+
+			var fork *bookmark.Bookmark
+			err = store.Update(func(data *storage.Data) error {
+				var err error
+				fork, err = team.Fork(data, view.Team, bookmarkID, time.Now())
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to fork bookmark: %w", err)
+			}
+
+			helpers.PrintBookmarkSuccess("forked", fork)
+			return nil
+		},
+	}
+}
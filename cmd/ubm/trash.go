@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/internal/cmd/helpers"
+	"github.com/tom-023/ubm/internal/storage"
+	"github.com/tom-023/ubm/internal/ui"
+)
+
+func trashCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "trash",
+		Short: "Manage deleted bookmarks",
+		Long: `List, restore, and permanently remove bookmarks that were deleted.
+Set trash_retention_days in config.yaml to purge old entries automatically.`,
+	}
+
+	cmd.AddCommand(
+		trashListCmd(),
+		trashRestoreCmd(),
+		trashEmptyCmd(),
+	)
+
+	return cmd
+}
+
+func trashListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "list",
+		Short:   "List bookmarks in the trash, newest first",
+		Aliases: []string{"ls"},
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := store.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load bookmarks: %w", err)
+			}
+
+			if len(data.Trash) == 0 {
+				fmt.Println("The trash is empty.")
+				return nil
+			}
+
+			fmt.Println("🗑️  Trash:")
+			for i := len(data.Trash) - 1; i >= 0; i-- {
+				printTrashed(data.Trash[i])
+			}
+			return nil
+		},
+	}
+}
+
+func printTrashed(t *storage.TrashedBookmark) {
+	fmt.Printf("  %s  🔗 %s (%s)\n", t.DeletedAt.Local().Format("2006-01-02 15:04"), t.Bookmark.Title, t.Bookmark.ID)
+	fmt.Printf("      %s  in %s\n", t.Bookmark.URL, ui.FormatCategory(t.Bookmark.Category))
+}
+
+func trashRestoreCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "restore [ID]",
+		Short: "Move a bookmark out of the trash",
+		Long: `Restore a trashed bookmark by ID or select it interactively. The bookmark goes
+back into its original category, which is recreated if necessary.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var bookmarkID string
+
+			if len(args) > 0 {
+				bookmarkID = args[0]
+			} else {
+				data, err := store.Load()
+				if err != nil {
+					return fmt.Errorf("failed to load bookmarks: %w", err)
+				}
+
+				if len(data.Trash) == 0 {
+					fmt.Println("The trash is empty.")
+					return nil
+				}
+
+				trashed := make([]*bookmark.Bookmark, 0, len(data.Trash))
+				for i := len(data.Trash) - 1; i >= 0; i-- {
+					trashed = append(trashed, data.Trash[i].Bookmark)
+				}
+
+				selected, err := ui.SelectBookmark(trashed, "Select bookmark to restore")
+				if err != nil {
+					return helpers.HandleCancelError(err)
+				}
+				bookmarkID = selected.ID
+			}
+
+			var restored *bookmark.Bookmark
+			err := store.Update(func(data *storage.Data) error {
+				var err error
+				restored, err = data.RestoreBookmark(bookmarkID)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to restore bookmark: %w", err)
+			}
+
+			helpers.PrintBookmarkSuccess("restored", restored)
+			return nil
+		},
+	}
+}
+
+func trashEmptyCmd() *cobra.Command {
+	var skipConfirm bool
+
+	cmd := &cobra.Command{
+		Use:   "empty",
+		Short: "Permanently remove everything in the trash",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := store.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load bookmarks: %w", err)
+			}
+
+			if len(data.Trash) == 0 {
+				fmt.Println("The trash is empty.")
+				return nil
+			}
+
+			if !skipConfirm {
+				confirm, err := ui.Confirm(fmt.Sprintf("Permanently remove %d bookmark(s) from the trash?", len(data.Trash)))
+				if err != nil {
+					return helpers.HandleCancelError(err)
+				}
+				if !confirm {
+					fmt.Println("Cancelled.")
+					return nil
+				}
+			}
+
+			var removed int
+			err = store.Update(func(data *storage.Data) error {
+				removed = data.PurgeTrash(time.Time{})
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("failed to empty trash: %w", err)
+			}
+
+			fmt.Printf("✅ Removed %d bookmark(s) from the trash.\n", removed)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&skipConfirm, "confirm", "y", false, "Skip confirmation prompt")
+
+	return cmd
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/tom-023/ubm/internal/cmd/helpers"
+	"github.com/tom-023/ubm/internal/storage"
+	"github.com/tom-023/ubm/internal/ui"
+)
+
+func undoCmd() *cobra.Command {
+	return historyCmd(false)
+}
+
+func redoCmd() *cobra.Command {
+	return historyCmd(true)
+}
+
+// historyCmd builds ubm undo and ubm redo, which only differ in the stack
+// they take steps from
+func historyCmd(redo bool) *cobra.Command {
+	var (
+		steps       int
+		skipConfirm bool
+	)
+
+	use, short, long := "undo", "Undo the last changes", `Revert the most recent changes to the library, one command at a time.
+Add, edit, move, delete and category changes can be undone, including ones made
+by earlier ubm invocations. A preview is shown before anything is reverted.`
+	action, title, done := "revert", "Undo", "Undone"
+	if redo {
+		use, short, long = "redo", "Redo changes that were undone", `Re-apply changes that were reverted with 'ubm undo'. Making any other change
+clears what can be redone.`
+		action, title, done = "re-apply", "Redo", "Redone"
+	}
+
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		Long:  long,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if steps < 1 {
+				return fmt.Errorf("--steps must be at least 1")
+			}
+
+			history, err := storage.LoadHistory(store)
+			if err != nil {
+				return err
+			}
+
+			stack := history.Undo
+			if redo {
+				stack = history.Redo
+			}
+			if len(stack) == 0 {
+				fmt.Printf("Nothing to %s.\n", use)
+				return nil
+			}
+			if steps > len(stack) {
+				steps = len(stack)
+			}
+
+			// Newest first, in the order they will be applied
+			pending := make([]storage.Step, 0, steps)
+			for i := len(stack) - 1; i >= len(stack)-steps; i-- {
+				pending = append(pending, stack[i])
+			}
+
+			fmt.Printf("This will %s:\n", action)
+			for _, step := range pending {
+				fmt.Println()
+				for _, e := range step.Entries {
+					printJournalEntry(e)
+				}
+			}
+			fmt.Println()
+
+			if !skipConfirm {
+				confirm, err := ui.Confirm(fmt.Sprintf("%s %d change(s)?", title, len(pending)))
+				if err != nil {
+					return helpers.HandleCancelError(err)
+				}
+				if !confirm {
+					fmt.Printf("%s cancelled.\n", title)
+					return nil
+				}
+			}
+
+			apply := storage.Undo
+			if redo {
+				apply = storage.Redo
+			}
+			for _, step := range pending {
+				// Passing the previewed transaction makes this fail instead of
+				// reverting something else if the library changed meanwhile
+				if _, err := apply(store, step.Tx); err != nil {
+					return fmt.Errorf("failed to %s: %w", use, err)
+				}
+			}
+
+			fmt.Printf("✅ %s %d change(s).\n", done, len(pending))
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVarP(&steps, "steps", "n", 1, fmt.Sprintf("Number of changes to %s", use))
+	cmd.Flags().BoolVarP(&skipConfirm, "confirm", "y", false, "Skip confirmation prompt")
+
+	return cmd
+}
@@ -15,6 +15,7 @@ type Bookmark struct {
 	UpdatedAt   time.Time `json:"updated_at" yaml:"updated_at"`
 	Tags        []string  `json:"tags,omitempty" yaml:"tags,omitempty"`
 	Description string    `json:"description,omitempty" yaml:"description,omitempty"`
+	Keyword     string    `json:"keyword,omitempty" yaml:"keyword,omitempty"`
 }
 
 func New(title, url, category string) *Bookmark {
@@ -22,7 +22,7 @@ func HandleCancelError(err error) error {
 }
 
 // LoadDataAndBuildTree loads storage data and builds category tree
-func LoadDataAndBuildTree(store *storage.Storage) (*storage.Data, *category.Node, error) {
+func LoadDataAndBuildTree(store storage.Backend) (*storage.Data, *category.Node, error) {
 	data, err := store.Load()
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to load data: %w", err)
@@ -34,16 +34,7 @@ func LoadDataAndBuildTree(store *storage.Storage) (*storage.Data, *category.Node
 
 // EnsureCategoryExists adds a category to the data if it doesn't exist
 func EnsureCategoryExists(data *storage.Data, category string) {
-	if category == "" {
-		return
-	}
-
-	for _, cat := range data.Categories {
-		if cat == category {
-			return
-		}
-	}
-	data.Categories = append(data.Categories, category)
+	data.AddCategory(category)
 }
 
 // PrintBookmarkSuccess prints a success message for bookmark operations
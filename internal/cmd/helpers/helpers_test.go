@@ -184,4 +184,24 @@ func TestLoadDataAndBuildTree(t *testing.T) {
 	if data2 == nil || tree2 == nil {
 		t.Error("LoadDataAndBuildTree() should return non-nil data and tree even for new storage")
 	}
-}
\ No newline at end of file
+}
+
+func TestLoadDataAndBuildTree_MemoryBackend(t *testing.T) {
+	store := storage.NewMemory()
+	store.AddBookmark(&bookmark.Bookmark{ID: "1", Title: "Go", URL: "https://go.dev", Category: "dev/go"})
+	store.AddBookmark(&bookmark.Bookmark{ID: "2", Title: "Misc", URL: "https://misc.com", Category: ""})
+
+	data, tree, err := LoadDataAndBuildTree(store)
+	if err != nil {
+		t.Fatalf("LoadDataAndBuildTree() error = %v", err)
+	}
+
+	if len(data.Bookmarks) != 2 {
+		t.Errorf("LoadDataAndBuildTree() got %d bookmarks, want 2", len(data.Bookmarks))
+	}
+
+	// dev and uncategorized at the root
+	if len(tree.Children) != 2 {
+		t.Errorf("Root should have 2 children, got %d", len(tree.Children))
+	}
+}
@@ -14,6 +14,44 @@ type Config struct {
 	DisplayFormat  string `yaml:"display_format"`
 	AutoBackup     bool   `yaml:"auto_backup"`
 	MaxBackups     int    `yaml:"max_backups"`
+	StorageFormat  string `yaml:"storage_format"`
+	Backend        string `yaml:"backend"`
+	TrashDays      int    `yaml:"trash_retention_days"`
+	Encryption     string `yaml:"encryption"`
+	KeyFile        string `yaml:"key_file"`
+	TeamLibrary    string `yaml:"team_library"`
+	Git            bool   `yaml:"git"`
+	// Remote is a WebDAV or HTTP location ubm sync exchanges the library with
+	Remote RemoteConfig `yaml:"remote,omitempty"`
+	// Library is the library used when neither --library nor UBM_LIBRARY is given
+	Library   string                   `yaml:"library"`
+	Libraries map[string]LibraryConfig `yaml:"libraries,omitempty"`
+}
+
+// DefaultLibrary is the library that lives directly in the data directory
+const DefaultLibrary = "default"
+
+// LibraryConfig holds the storage settings of a named library. The default
+// library keeps using the top-level settings.
+type LibraryConfig struct {
+	StorageFormat string `yaml:"storage_format,omitempty"`
+	Backend       string `yaml:"backend,omitempty"`
+	Encryption    string `yaml:"encryption,omitempty"`
+	KeyFile       string `yaml:"key_file,omitempty"`
+	// TeamLibrary is a read-only bookmark file shown under the library
+	TeamLibrary string `yaml:"team_library,omitempty"`
+	// Git commits every save to the git repository the library is in
+	Git bool `yaml:"git,omitempty"`
+	// Remote is a WebDAV or HTTP location the library is synced with
+	Remote RemoteConfig `yaml:"remote,omitempty"`
+}
+
+// RemoteConfig is a library file on a WebDAV or plain HTTP server, such as
+// Nextcloud or a NAS, and the credentials to access it
+type RemoteConfig struct {
+	URL      string `yaml:"url,omitempty"`
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
 }
 
 var defaultConfig = Config{
@@ -22,14 +60,79 @@ var defaultConfig = Config{
 	DisplayFormat:  "tree",
 	AutoBackup:     true,
 	MaxBackups:     5,
+	StorageFormat:  "json",
+	Backend:        "file",
+	TrashDays:      0,
+	Encryption:     "",
+	KeyFile:        "",
+	TeamLibrary:    "",
+	Git:            false,
+	Library:        DefaultLibrary,
 }
 
-func GetConfigDir() (string, error) {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("failed to get home directory: %w", err)
+// ForLibrary returns the storage settings of a library
+func (c *Config) ForLibrary(name string) LibraryConfig {
+	if name == DefaultLibrary {
+		return LibraryConfig{
+			StorageFormat: c.StorageFormat,
+			Backend:       c.Backend,
+			Encryption:    c.Encryption,
+			KeyFile:       c.KeyFile,
+			TeamLibrary:   c.TeamLibrary,
+			Git:           c.Git,
+			Remote:        c.Remote,
+		}
+	}
+
+	lc := c.Libraries[name]
+	if lc.StorageFormat == "" {
+		lc.StorageFormat = defaultConfig.StorageFormat
+	}
+	if lc.Backend == "" {
+		lc.Backend = defaultConfig.Backend
+	}
+	return lc
+}
+
+// SetLibrary stores the storage settings of a library
+func (c *Config) SetLibrary(name string, lc LibraryConfig) {
+	if name == DefaultLibrary {
+		c.StorageFormat = lc.StorageFormat
+		c.Backend = lc.Backend
+		c.Encryption = lc.Encryption
+		c.KeyFile = lc.KeyFile
+		c.TeamLibrary = lc.TeamLibrary
+		c.Git = lc.Git
+		c.Remote = lc.Remote
+		return
+	}
+
+	if c.Libraries == nil {
+		c.Libraries = map[string]LibraryConfig{}
+	}
+	c.Libraries[name] = lc
+}
+
+// RenameLibrary moves the settings of a library to its new name
+func (c *Config) RenameLibrary(from, to string) {
+	if lc, ok := c.Libraries[from]; ok {
+		delete(c.Libraries, from)
+		if c.Libraries == nil {
+			c.Libraries = map[string]LibraryConfig{}
+		}
+		c.Libraries[to] = lc
+	}
+	if c.Library == from {
+		c.Library = to
+	}
+}
+
+// DeleteLibrary forgets the settings of a library
+func (c *Config) DeleteLibrary(name string) {
+	delete(c.Libraries, name)
+	if c.Library == name {
+		c.Library = DefaultLibrary
 	}
-	return filepath.Join(homeDir, ".config", "ubm"), nil
 }
 
 func Load() (*Config, error) {
@@ -65,6 +168,15 @@ func Load() (*Config, error) {
 	if cfg.MaxBackups == 0 {
 		cfg.MaxBackups = defaultConfig.MaxBackups
 	}
+	if cfg.StorageFormat == "" {
+		cfg.StorageFormat = defaultConfig.StorageFormat
+	}
+	if cfg.Backend == "" {
+		cfg.Backend = defaultConfig.Backend
+	}
+	if cfg.Library == "" {
+		cfg.Library = defaultConfig.Library
+	}
 
 	return &cfg, nil
 }
@@ -88,8 +200,12 @@ func Save(cfg *Config) error {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	// Write config file
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
+	// Write config file. It can hold the password of a sync server, so only
+	// the user may read it, including a file written by an older version.
+	if err := os.WriteFile(configPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Chmod(configPath, 0600); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 
@@ -114,6 +230,11 @@ func Init() error {
 		return nil // Config already exists
 	}
 
+	// Bring over the config from where older versions kept it
+	if moved, err := migrateConfig(configDir); err != nil || moved {
+		return err
+	}
+
 	// Save default config
 	cfg := defaultConfig
 	return Save(&cfg)
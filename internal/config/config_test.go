@@ -0,0 +1,26 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSave_KeepsConfigPrivate(t *testing.T) {
+	home := isolate(t)
+	path := filepath.Join(home, ".config", "ubm", "config.yaml")
+	writeFile(t, path)
+
+	cfg := &Config{Remote: RemoteConfig{URL: "https://dav.example.com/bookmarks.json", Password: "secret"}}
+	if err := Save(cfg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("config.yaml mode = %o, want 600", mode)
+	}
+}
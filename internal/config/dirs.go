@@ -0,0 +1,291 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/tom-023/ubm/internal/lockfile"
+)
+
+const appDir = "ubm"
+
+// legacyDir is where ubm kept both its config and its data before it
+// followed the XDG base directory spec
+func legacyDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".config", appDir), nil
+}
+
+// baseDir returns the ubm directory under the XDG base directory named by
+// env, under %APPDATA% on Windows, or under fallback in the home directory
+func baseDir(env, fallback string) (string, error) {
+	// The spec says relative paths are invalid and must be ignored
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return filepath.Join(dir, appDir), nil
+	}
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("APPDATA"); dir != "" {
+			return filepath.Join(dir, appDir), nil
+		}
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, fallback, appDir), nil
+}
+
+func defaultConfigDir() (string, error) {
+	return baseDir("XDG_CONFIG_HOME", ".config")
+}
+
+func defaultDataDir() (string, error) {
+	return baseDir("XDG_DATA_HOME", filepath.Join(".local", "share"))
+}
+
+// GetConfigDir returns the directory of config.yaml: UBM_CONFIG_DIR,
+// $XDG_CONFIG_HOME/ubm, %APPDATA%\ubm on Windows, or ~/.config/ubm
+func GetConfigDir() (string, error) {
+	if dir := os.Getenv("UBM_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+	return defaultConfigDir()
+}
+
+// GetDataDir returns the directory the libraries are kept in: override (the
+// --data-dir flag), UBM_DATA_DIR, $XDG_DATA_HOME/ubm, %APPDATA%\ubm on
+// Windows, or ~/.local/share/ubm. A library that older versions left in a
+// git repository at ~/.config/ubm stays there, since moving it would take it
+// out of the repository.
+func GetDataDir(override string) (string, error) {
+	if override != "" {
+		return override, nil
+	}
+	if dir := os.Getenv("UBM_DATA_DIR"); dir != "" {
+		return dir, nil
+	}
+
+	dir, err := defaultDataDir()
+	if err != nil {
+		return "", err
+	}
+	legacy, err := legacyDir()
+	if err != nil {
+		return "", err
+	}
+	if dir != legacy && !hasData(dir) && hasData(legacy) && isDir(filepath.Join(legacy, ".git")) {
+		return legacy, nil
+	}
+	return dir, nil
+}
+
+// MigrateData moves the libraries older versions kept in ~/.config/ubm to
+// dir, if dir is the default data directory and has no library yet. It
+// returns the directory they were moved from, or "" if nothing was moved.
+//
+// The files are moved while the default library is locked, first into a
+// staging directory next to dir and then into dir. A move that was
+// interrupted leaves the staging directory behind and is finished the next
+// time, instead of leaving part of the library in each place.
+func MigrateData(dir string) (string, error) {
+	defaultDir, err := defaultDataDir()
+	if err != nil {
+		return "", err
+	}
+	legacy, err := legacyDir()
+	if err != nil {
+		return "", err
+	}
+	staging := dir + ".moving"
+	if dir != defaultDir || dir == legacy || !needsMigration(dir, staging, legacy) {
+		return "", nil
+	}
+
+	if err := os.MkdirAll(legacy, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", legacy, err)
+	}
+	unlock, err := lockfile.LockDir(legacy)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	// Another ubm may have moved them while this one waited for the lock
+	if !needsMigration(dir, staging, legacy) {
+		return "", nil
+	}
+
+	if err := moveData(legacy, staging); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := moveData(staging, dir); err != nil {
+		return "", err
+	}
+	if err := os.Remove(staging); err != nil {
+		return "", fmt.Errorf("failed to remove %s: %w", staging, err)
+	}
+	return legacy, nil
+}
+
+// needsMigration reports whether the libraries in legacy are still to be
+// moved to dir, or were partly moved by an earlier MigrateData
+func needsMigration(dir, staging, legacy string) bool {
+	return isDir(staging) || (!hasData(dir) && hasData(legacy))
+}
+
+// moveData moves the library files in one directory to another, leaving the
+// lock file that guards the move where it is
+func moveData(from, to string) error {
+	if err := os.MkdirAll(to, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", to, err)
+	}
+	entries, err := os.ReadDir(from)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", from, err)
+	}
+	for _, entry := range entries {
+		if !isDataEntry(entry.Name()) || entry.Name() == lockfile.FileName {
+			continue
+		}
+		src := filepath.Join(from, entry.Name())
+		if err := move(src, filepath.Join(to, entry.Name())); err != nil {
+			return fmt.Errorf("failed to move %s to %s: %w", src, to, err)
+		}
+	}
+	return nil
+}
+
+// move renames a file or directory, or copies it and removes the original
+// when the two are on different file systems or a copy an interrupted move
+// made is in the way
+func move(from, to string) error {
+	err := os.Rename(from, to)
+	if err == nil {
+		return nil
+	}
+	if _, statErr := os.Stat(to); isCrossDevice(err) || statErr == nil {
+		return copyAndRemove(from, to)
+	}
+	return err
+}
+
+// copyAndRemove copies a file or directory, syncing every file to disk
+// before it removes the original. A copy an earlier attempt left at to is
+// replaced.
+func copyAndRemove(from, to string) error {
+	if err := os.RemoveAll(to); err != nil {
+		return err
+	}
+	err := filepath.WalkDir(from, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(to, rel)
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+		return copyFile(path, target, info.Mode().Perm())
+	})
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(from)
+}
+
+func copyFile(from, to string, perm fs.FileMode) error {
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// migrateConfig moves config.yaml from ~/.config/ubm to configDir if that is
+// the default config directory, e.g. %APPDATA%\ubm on Windows
+func migrateConfig(configDir string) (bool, error) {
+	defaultDir, err := defaultConfigDir()
+	if err != nil {
+		return false, err
+	}
+	legacy, err := legacyDir()
+	if err != nil {
+		return false, err
+	}
+	if configDir != defaultDir || configDir == legacy {
+		return false, nil
+	}
+
+	from := filepath.Join(legacy, "config.yaml")
+	if _, err := os.Stat(from); err != nil {
+		return false, nil
+	}
+	if err := os.Rename(from, filepath.Join(configDir, "config.yaml")); err != nil {
+		return false, fmt.Errorf("failed to move %s to %s: %w", from, configDir, err)
+	}
+	return true, nil
+}
+
+// isDataEntry reports whether a file in the old directory belongs to a
+// library: the storage file and its lock, backups, history, sync state and
+// the other libraries
+func isDataEntry(name string) bool {
+	switch name {
+	case "backups", "journal.jsonl", "libraries", "sync-state.json":
+		return true
+	}
+	return strings.HasPrefix(name, "bookmarks.")
+}
+
+// hasData reports whether dir holds a library. A lock file alone does not
+// count; it is created by merely reading.
+func hasData(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if isDataEntry(entry.Name()) && entry.Name() != lockfile.FileName {
+			return true
+		}
+	}
+	return false
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/tom-023/ubm/internal/testutil"
+)
+
+// isolate points every directory lookup into a fresh home directory
+func isolate(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the default directories are under %APPDATA% on Windows")
+	}
+
+	home, cleanup := testutil.TempDir(t)
+	t.Cleanup(cleanup)
+	t.Setenv("HOME", home)
+	for _, env := range []string{"XDG_CONFIG_HOME", "XDG_DATA_HOME", "UBM_CONFIG_DIR", "UBM_DATA_DIR"} {
+		t.Setenv(env, "")
+	}
+	return home
+}
+
+func writeFile(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetConfigDir(t *testing.T) {
+	home := isolate(t)
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"default", nil, filepath.Join(home, ".config", "ubm")},
+		{"XDG_CONFIG_HOME", map[string]string{"XDG_CONFIG_HOME": "/xdg/config"}, "/xdg/config/ubm"},
+		{"relative XDG_CONFIG_HOME is ignored", map[string]string{"XDG_CONFIG_HOME": "config"}, filepath.Join(home, ".config", "ubm")},
+		{"UBM_CONFIG_DIR", map[string]string{"UBM_CONFIG_DIR": "/ubm/config", "XDG_CONFIG_HOME": "/xdg/config"}, "/ubm/config"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			got, err := GetConfigDir()
+			if err != nil || got != tt.want {
+				t.Errorf("GetConfigDir() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetDataDir(t *testing.T) {
+	home := isolate(t)
+
+	tests := []struct {
+		name     string
+		override string
+		env      map[string]string
+		want     string
+	}{
+		{"default", "", nil, filepath.Join(home, ".local", "share", "ubm")},
+		{"XDG_DATA_HOME", "", map[string]string{"XDG_DATA_HOME": "/xdg/data"}, "/xdg/data/ubm"},
+		{"UBM_DATA_DIR", "", map[string]string{"UBM_DATA_DIR": "/ubm/data", "XDG_DATA_HOME": "/xdg/data"}, "/ubm/data"},
+		{"flag", "/flag", map[string]string{"UBM_DATA_DIR": "/ubm/data"}, "/flag"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			got, err := GetDataDir(tt.override)
+			if err != nil || got != tt.want {
+				t.Errorf("GetDataDir(%q) = %q, %v, want %q", tt.override, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigrateData(t *testing.T) {
+	home := isolate(t)
+	legacy := filepath.Join(home, ".config", "ubm")
+	writeFile(t, filepath.Join(legacy, "config.yaml"))
+	writeFile(t, filepath.Join(legacy, "bookmarks.json"))
+	writeFile(t, filepath.Join(legacy, "backups", "bookmarks_20240101_000000.json"))
+	writeFile(t, filepath.Join(legacy, "libraries", "work", "bookmarks.json"))
+	writeFile(t, filepath.Join(legacy, "key"))
+
+	dir, err := GetDataDir("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, err := MigrateData(dir)
+	if err != nil || from != legacy {
+		t.Fatalf("MigrateData() = %q, %v, want a move from %s", from, err, legacy)
+	}
+
+	for _, name := range []string{"bookmarks.json", "backups", filepath.Join("libraries", "work", "bookmarks.json")} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s was not moved: %v", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(legacy, name)); !os.IsNotExist(err) {
+			t.Errorf("%s is still in the old location", name)
+		}
+	}
+	// Everything that is not library data stays where it was
+	for _, name := range []string{"config.yaml", "key"} {
+		if _, err := os.Stat(filepath.Join(legacy, name)); err != nil {
+			t.Errorf("%s should stay in the old location: %v", name, err)
+		}
+	}
+
+	if from, err := MigrateData(dir); err != nil || from != "" {
+		t.Errorf("Second MigrateData() = %q, %v, want nothing to do", from, err)
+	}
+}
+
+func TestMigrateData_ExplicitDir(t *testing.T) {
+	home := isolate(t)
+	writeFile(t, filepath.Join(home, ".config", "ubm", "bookmarks.json"))
+
+	dir := filepath.Join(home, "elsewhere")
+	if from, err := MigrateData(dir); err != nil || from != "" {
+		t.Errorf("MigrateData(%s) = %q, %v, want the old library left alone", dir, from, err)
+	}
+}
+
+func TestMigrateData_Interrupted(t *testing.T) {
+	home := isolate(t)
+	legacy := filepath.Join(home, ".config", "ubm")
+	dir, err := GetDataDir("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A move that stopped after staging the library file but before the
+	// backups, and one that copied the backups but did not remove them
+	writeFile(t, filepath.Join(dir+".moving", "bookmarks.json"))
+	writeFile(t, filepath.Join(dir+".moving", "backups", "partial.json"))
+	writeFile(t, filepath.Join(legacy, "backups", "bookmarks_20240101_000000.json"))
+	writeFile(t, filepath.Join(legacy, "journal.jsonl"))
+
+	from, err := MigrateData(dir)
+	if err != nil || from != legacy {
+		t.Fatalf("MigrateData() = %q, %v, want the move from %s finished", from, err, legacy)
+	}
+	for _, name := range []string{"bookmarks.json", "journal.jsonl", filepath.Join("backups", "bookmarks_20240101_000000.json")} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s was not moved: %v", name, err)
+		}
+	}
+	if hasData(legacy) {
+		t.Error("The old location still has library data")
+	}
+	if _, err := os.Stat(dir + ".moving"); !os.IsNotExist(err) {
+		t.Errorf("The staging directory was not removed: %v", err)
+	}
+}
+
+func TestCopyAndRemove(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+	from, to := filepath.Join(dir, "from"), filepath.Join(dir, "to")
+	writeFile(t, filepath.Join(from, "a", "b.json"))
+	// What an interrupted copy left
+	writeFile(t, filepath.Join(to, "stale.json"))
+
+	if err := copyAndRemove(from, to); err != nil {
+		t.Fatalf("copyAndRemove() error = %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(to, "a", "b.json")); err != nil || string(data) != "{}" {
+		t.Errorf("Copy = %q, %v, want the original", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(to, "stale.json")); !os.IsNotExist(err) {
+		t.Error("copyAndRemove() kept what an earlier copy left")
+	}
+	if _, err := os.Stat(from); !os.IsNotExist(err) {
+		t.Error("copyAndRemove() did not remove the original")
+	}
+}
+
+func TestGetDataDir_LegacyGitRepository(t *testing.T) {
+	home := isolate(t)
+	legacy := filepath.Join(home, ".config", "ubm")
+	writeFile(t, filepath.Join(legacy, "bookmarks.json"))
+	if err := os.MkdirAll(filepath.Join(legacy, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := GetDataDir("")
+	if err != nil || dir != legacy {
+		t.Errorf("GetDataDir() = %q, %v, want the repository at %s", dir, err, legacy)
+	}
+	if from, err := MigrateData(dir); err != nil || from != "" {
+		t.Errorf("MigrateData() = %q, %v, want nothing moved", from, err)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !unix && !windows
+
+package config
+
+func isCrossDevice(err error) bool {
+	return false
+}
@@ -0,0 +1,12 @@
+//go:build unix
+
+package config
+
+import (
+	"errors"
+	"syscall"
+)
+
+func isCrossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
@@ -0,0 +1,315 @@
+// Package doctor checks a bookmark library for inconsistencies and repairs the
+// ones that can be fixed without guessing.
+package doctor
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/internal/category"
+	"github.com/tom-023/ubm/internal/storage"
+	"github.com/tom-023/ubm/pkg/validator"
+)
+
+// Severity ranks how much a problem matters
+type Severity int
+
+const (
+	// Info is harmless untidiness
+	Info Severity = iota
+	// Warning means some commands may show or count things wrongly
+	Warning
+	// Error means bookmarks may be unreachable or lost on the next change
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	default:
+		return "info"
+	}
+}
+
+// Problem is one inconsistency found in the library
+type Problem struct {
+	Severity Severity
+	// BookmarkID or Category names what the problem is about
+	BookmarkID string
+	Category   string
+	Message    string
+	// Fixable problems are repaired by Fix; the rest need manual review
+	Fixable bool
+	// Fixed is set by Fix once the problem was repaired
+	Fixed bool
+}
+
+// check inspects data and, if fix is set, repairs what it safely can
+type check func(data *storage.Data, fix bool) []Problem
+
+// Checks run in this order so that each one sees the repairs of the previous
+// ones, e.g. categories are cleaned up before missing ones are registered
+var checks = []check{
+	checkIDs,
+	checkCategoryPaths,
+	checkDuplicateCategories,
+	checkMissingCategories,
+	checkURLs,
+	checkTimestamps,
+}
+
+// Check reports every problem in data without changing it
+func Check(data *storage.Data) []Problem {
+	return run(data.Clone(), false)
+}
+
+// Fix repairs the fixable problems in data and returns every problem found,
+// with Fixed set on the repaired ones
+func Fix(data *storage.Data) []Problem {
+	return run(data, true)
+}
+
+func run(data *storage.Data, fix bool) []Problem {
+	problems := []Problem{}
+	for _, c := range checks {
+		problems = append(problems, c(data, fix)...)
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Severity > problems[j].Severity
+	})
+	return problems
+}
+
+// checkIDs finds bookmarks without an ID or sharing one. An exact copy of
+// another bookmark is dropped; otherwise the later one gets a new ID so that
+// both stay reachable.
+func checkIDs(data *storage.Data, fix bool) []Problem {
+	var problems []Problem
+
+	taken := make(map[string]bool, len(data.Bookmarks)+len(data.Trash))
+	for _, b := range allBookmarks(data) {
+		taken[b.ID] = true
+	}
+
+	first := make(map[string]*bookmark.Bookmark, len(data.Bookmarks))
+	kept := make([]*bookmark.Bookmark, 0, len(data.Bookmarks))
+	for _, b := range data.Bookmarks {
+		original, duplicate := first[b.ID]
+		if b.ID != "" && !duplicate {
+			first[b.ID] = b
+			kept = append(kept, b)
+			continue
+		}
+
+		p := Problem{Severity: Error, BookmarkID: b.ID, Fixable: true, Fixed: fix}
+		switch {
+		case b.ID == "":
+			p.Message = fmt.Sprintf("bookmark %q has no ID", b.Title)
+		case reflect.DeepEqual(original, b):
+			p.Message = fmt.Sprintf("bookmark %q is listed twice", b.Title)
+			if fix {
+				p.Message += "; removed the copy"
+			}
+			problems = append(problems, p)
+			continue
+		default:
+			p.Message = fmt.Sprintf("bookmark %q shares its ID with %q", b.Title, original.Title)
+		}
+
+		if fix {
+			b.ID = newID(taken)
+			p.Message += fmt.Sprintf("; assigned new ID %s", b.ID)
+		}
+		kept = append(kept, b)
+		problems = append(problems, p)
+	}
+	if fix {
+		data.Bookmarks = kept
+	}
+
+	for _, t := range data.Trash {
+		if first[t.Bookmark.ID] != nil {
+			problems = append(problems, Problem{
+				Severity:   Warning,
+				BookmarkID: t.Bookmark.ID,
+				Message:    fmt.Sprintf("trashed bookmark %q shares its ID with a bookmark in the library and cannot be restored", t.Bookmark.Title),
+			})
+		}
+	}
+
+	return problems
+}
+
+func newID(taken map[string]bool) string {
+	for {
+		id := uuid.New().String()
+		if !taken[id] {
+			taken[id] = true
+			return id
+		}
+	}
+}
+
+// checkCategoryPaths finds categories with empty segments, such as "a//b" or
+// "tools/". Dropping the empty segments is what the category tree already
+// shows, so that is the repair.
+func checkCategoryPaths(data *storage.Data, fix bool) []Problem {
+	var problems []Problem
+	m := category.NewManager()
+
+	for i, c := range data.Categories {
+		if m.ValidateCategory(c) == nil {
+			continue
+		}
+
+		p := Problem{Severity: Error, Category: c, Fixable: true, Message: fmt.Sprintf("category %q has empty path segments", c)}
+		if fix {
+			data.Categories[i] = cleanCategory(c)
+			p.Fixed = true
+		}
+		problems = append(problems, p)
+	}
+
+	for _, b := range data.Bookmarks {
+		if m.ValidateCategory(b.Category) == nil {
+			continue
+		}
+
+		p := Problem{Severity: Error, BookmarkID: b.ID, Category: b.Category, Fixable: true,
+			Message: fmt.Sprintf("bookmark %q is in category %q, which has empty path segments", b.Title, b.Category)}
+		if fix {
+			b.Category = cleanCategory(b.Category)
+			p.Fixed = true
+		}
+		problems = append(problems, p)
+	}
+
+	return problems
+}
+
+func cleanCategory(path string) string {
+	parts := []string{}
+	for _, part := range strings.Split(path, "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// checkDuplicateCategories finds categories listed more than once, including
+// the empty category, which is implied and never listed
+func checkDuplicateCategories(data *storage.Data, fix bool) []Problem {
+	var problems []Problem
+
+	seen := make(map[string]bool, len(data.Categories))
+	categories := make([]string, 0, len(data.Categories))
+	for _, c := range data.Categories {
+		if c != "" && !seen[c] {
+			seen[c] = true
+			categories = append(categories, c)
+			continue
+		}
+
+		message := fmt.Sprintf("category %q is listed more than once", c)
+		if c == "" {
+			message = "the category list contains an empty entry"
+		}
+		problems = append(problems, Problem{Severity: Info, Category: c, Message: message, Fixable: true, Fixed: fix})
+	}
+
+	if fix {
+		data.Categories = categories
+	}
+	return problems
+}
+
+// checkMissingCategories finds bookmarks whose category is not in the list,
+// which hides them from category selection
+func checkMissingCategories(data *storage.Data, fix bool) []Problem {
+	var problems []Problem
+
+	for _, b := range data.Bookmarks {
+		if b.Category == "" || data.HasCategory(b.Category) {
+			continue
+		}
+
+		p := Problem{Severity: Warning, BookmarkID: b.ID, Category: b.Category, Fixable: true,
+			Message: fmt.Sprintf("bookmark %q is in category %q, which does not exist", b.Title, b.Category)}
+		if fix {
+			data.AddCategory(b.Category)
+			p.Message += "; created it"
+			p.Fixed = true
+		}
+		problems = append(problems, p)
+	}
+
+	return problems
+}
+
+// checkURLs finds URLs that ubm would not accept when adding a bookmark.
+// Only surrounding whitespace is repaired; anything else would be a guess.
+func checkURLs(data *storage.Data, fix bool) []Problem {
+	var problems []Problem
+
+	for _, b := range allBookmarks(data) {
+		err := validator.ValidateURL(b.URL)
+		if err == nil {
+			continue
+		}
+
+		trimmed := strings.TrimSpace(b.URL)
+		p := Problem{Severity: Error, BookmarkID: b.ID, Message: fmt.Sprintf("bookmark %q has an invalid URL %q: %v", b.Title, b.URL, err)}
+		if trimmed != b.URL && validator.ValidateURL(trimmed) == nil {
+			p.Fixable = true
+			if fix {
+				b.URL = trimmed
+				p.Fixed = true
+			}
+		}
+		problems = append(problems, p)
+	}
+
+	return problems
+}
+
+// checkTimestamps finds bookmarks updated before they were created. The
+// creation time is trusted, as it never changes after adding.
+func checkTimestamps(data *storage.Data, fix bool) []Problem {
+	var problems []Problem
+
+	for _, b := range allBookmarks(data) {
+		if !b.UpdatedAt.Before(b.CreatedAt) {
+			continue
+		}
+
+		p := Problem{Severity: Warning, BookmarkID: b.ID, Fixable: true,
+			Message: fmt.Sprintf("bookmark %q was updated (%s) before it was created (%s)",
+				b.Title, b.UpdatedAt.Format("2006-01-02 15:04"), b.CreatedAt.Format("2006-01-02 15:04"))}
+		if fix {
+			b.UpdatedAt = b.CreatedAt
+			p.Fixed = true
+		}
+		problems = append(problems, p)
+	}
+
+	return problems
+}
+
+// allBookmarks returns the bookmarks in the library followed by those in the trash
+func allBookmarks(data *storage.Data) []*bookmark.Bookmark {
+	bookmarks := make([]*bookmark.Bookmark, 0, len(data.Bookmarks)+len(data.Trash))
+	bookmarks = append(bookmarks, data.Bookmarks...)
+	for _, t := range data.Trash {
+		bookmarks = append(bookmarks, t.Bookmark)
+	}
+	return bookmarks
+}
@@ -0,0 +1,130 @@
+package doctor
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/internal/storage"
+)
+
+func brokenLibrary() *storage.Data {
+	created := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	earlier := created.Add(-time.Hour)
+
+	return &storage.Data{
+		Bookmarks: []*bookmark.Bookmark{
+			{ID: "1", Title: "Go", URL: "https://go.dev", Category: "dev//go", CreatedAt: created, UpdatedAt: created},
+			{ID: "1", Title: "Go", URL: "https://go.dev", Category: "dev//go", CreatedAt: created, UpdatedAt: created},
+			{ID: "1", Title: "Rust", URL: "https://rust-lang.org", Category: "dev/rust", CreatedAt: created, UpdatedAt: earlier},
+			{ID: "", Title: "Zig", URL: " https://ziglang.org ", Category: "", CreatedAt: created, UpdatedAt: created},
+			{ID: "4", Title: "Bad", URL: "javascript:alert(1)", Category: "", CreatedAt: created, UpdatedAt: created},
+		},
+		Categories: []string{"dev//go", "dev", "dev", ""},
+		Trash: []*storage.TrashedBookmark{
+			{Bookmark: &bookmark.Bookmark{ID: "4", Title: "Old", URL: "https://old.example.com", CreatedAt: created, UpdatedAt: created}},
+		},
+	}
+}
+
+func TestCheck(t *testing.T) {
+	data := brokenLibrary()
+	before := data.Clone()
+
+	problems := Check(data)
+	if !reflect.DeepEqual(data, before) {
+		t.Error("Check() must not modify the library")
+	}
+
+	type key struct {
+		severity Severity
+		fixable  bool
+	}
+	got := map[key]int{}
+	for _, p := range problems {
+		if p.Fixed {
+			t.Errorf("Check() marked %q as fixed", p.Message)
+		}
+		got[key{p.Severity, p.Fixable}]++
+	}
+
+	want := map[key]int{
+		// listed twice, shared ID, missing ID, category path (1 category + 2 bookmarks), whitespace URL
+		{Error, true}: 7,
+		// javascript: URL
+		{Error, false}: 1,
+		// missing category, timestamps
+		{Warning, true}: 2,
+		// trashed bookmark shares an ID
+		{Warning, false}: 1,
+		// duplicate and empty category entries
+		{Info, true}: 2,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Check() found %v, want %v", got, want)
+		for _, p := range problems {
+			t.Logf("%s: %s", p.Severity, p.Message)
+		}
+	}
+
+	for i := 1; i < len(problems); i++ {
+		if problems[i].Severity > problems[i-1].Severity {
+			t.Errorf("Problems are not ordered by severity: %v", problems)
+			break
+		}
+	}
+}
+
+func TestFix(t *testing.T) {
+	data := brokenLibrary()
+
+	for _, p := range Fix(data) {
+		if p.Fixable != p.Fixed {
+			t.Errorf("Fix() left fixable problem %q unfixed", p.Message)
+		}
+	}
+
+	if len(data.Bookmarks) != 4 {
+		t.Fatalf("Expected the exact copy to be dropped, got %d bookmarks", len(data.Bookmarks))
+	}
+
+	ids := map[string]bool{}
+	for _, b := range data.Bookmarks {
+		if b.ID == "" || ids[b.ID] {
+			t.Errorf("Bookmark %q has ID %q after Fix()", b.Title, b.ID)
+		}
+		ids[b.ID] = true
+	}
+
+	byTitle := map[string]*bookmark.Bookmark{}
+	for _, b := range data.Bookmarks {
+		byTitle[b.Title] = b
+	}
+	if byTitle["Go"].ID != "1" || byTitle["Go"].Category != "dev/go" {
+		t.Errorf("Go = %+v, want ID 1 in dev/go", byTitle["Go"])
+	}
+	if !byTitle["Rust"].UpdatedAt.Equal(byTitle["Rust"].CreatedAt) {
+		t.Errorf("Rust UpdatedAt = %v, want CreatedAt", byTitle["Rust"].UpdatedAt)
+	}
+	if byTitle["Zig"].URL != "https://ziglang.org" {
+		t.Errorf("Zig URL = %q, want it trimmed", byTitle["Zig"].URL)
+	}
+	if byTitle["Bad"].URL != "javascript:alert(1)" {
+		t.Error("Fix() must leave URLs it cannot repair alone")
+	}
+	if want := []string{"dev/go", "dev", "dev/rust"}; !reflect.DeepEqual(data.Categories, want) {
+		t.Errorf("Categories = %v, want %v", data.Categories, want)
+	}
+
+	// Only what needs manual review is left
+	remaining := Check(data)
+	if len(remaining) != 2 {
+		t.Errorf("Check() after Fix() found %d problems, want 2", len(remaining))
+	}
+	for _, p := range remaining {
+		if p.Fixable {
+			t.Errorf("Problem %q is still fixable after Fix()", p.Message)
+		}
+	}
+}
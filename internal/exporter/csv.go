@@ -0,0 +1,79 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/internal/importer"
+	"github.com/tom-023/ubm/internal/storage"
+)
+
+// WriteCSV writes the library as CSV, or TSV with a tab as comma, with a
+// header row and the columns of importer.CSVColumns. Tags share one cell,
+// separated by commas, and times are RFC 3339. With a category, only it and
+// its subcategories are written. It returns the number of bookmarks written.
+func WriteCSV(w io.Writer, data *storage.Data, categoryPath string, comma rune) (int, error) {
+	node, err := categoryTree(data, categoryPath)
+	if err != nil {
+		return 0, err
+	}
+
+	cw := csv.NewWriter(w)
+	if comma != 0 {
+		cw.Comma = comma
+	}
+	if err := cw.Write(importer.CSVColumns); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, b := range data.Bookmarks {
+		if !node.IsRoot && b.Category != node.Path && !strings.HasPrefix(b.Category, node.Path+"/") {
+			continue
+		}
+		if err := cw.Write(csvRecord(b)); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	cw.Flush()
+	return count, cw.Error()
+}
+
+func csvRecord(b *bookmark.Bookmark) []string {
+	record := make([]string, len(importer.CSVColumns))
+	for i, column := range importer.CSVColumns {
+		switch column {
+		case "title":
+			record[i] = b.Title
+		case "url":
+			record[i] = b.URL
+		case "category":
+			record[i] = b.Category
+		case "tags":
+			record[i] = strings.Join(b.Tags, ", ")
+		case "description":
+			record[i] = b.Description
+		case "keyword":
+			record[i] = b.Keyword
+		case "created_at":
+			record[i] = csvTime(b.CreatedAt)
+		case "updated_at":
+			record[i] = csvTime(b.UpdatedAt)
+		case "id":
+			record[i] = b.ID
+		}
+	}
+	return record
+}
+
+func csvTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
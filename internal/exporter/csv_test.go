@@ -0,0 +1,112 @@
+package exporter
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tom-023/ubm/internal/importer"
+	"github.com/tom-023/ubm/internal/storage"
+)
+
+func TestWriteCSV_RoundTrip(t *testing.T) {
+	for _, comma := range []rune{',', '\t'} {
+		data := testLibrary()
+
+		var buf bytes.Buffer
+		n, err := WriteCSV(&buf, data, "", comma)
+		if err != nil {
+			t.Fatalf("WriteCSV() error = %v", err)
+		}
+		if n != len(data.Bookmarks) {
+			t.Errorf("WriteCSV() = %d, want %d", n, len(data.Bookmarks))
+		}
+
+		imp, err := importer.ParseCSV(&buf, importer.CSVOptions{Comma: comma})
+		if err != nil {
+			t.Fatalf("ParseCSV() error = %v", err)
+		}
+		imported := &storage.Data{}
+		result, err := importer.Apply(imported, imp)
+		if err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+		if len(result.Added) != len(data.Bookmarks) {
+			t.Errorf("Apply() = %+v, want every bookmark added", result)
+		}
+
+		if got, want := entries(imported.Bookmarks), entries(data.Bookmarks); !reflect.DeepEqual(got, want) {
+			t.Errorf("Round trip with %q =\n%+v\nwant\n%+v", comma, got, want)
+		}
+		for i, b := range imported.Bookmarks {
+			if b.ID != data.Bookmarks[i].ID {
+				t.Errorf("%s has ID %s, want %s", b.Title, b.ID, data.Bookmarks[i].ID)
+			}
+		}
+	}
+}
+
+func TestWriteCSV_Category(t *testing.T) {
+	var buf bytes.Buffer
+	n, err := WriteCSV(&buf, testLibrary(), "dev/go", 0)
+	if err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("WriteCSV() = %d, want the 2 bookmarks in dev/go", n)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if want := "title,url,category,tags,description,keyword,created_at,updated_at,id"; lines[0] != want {
+		t.Errorf("Header = %s, want %s", lines[0], want)
+	}
+	if !strings.HasPrefix(lines[1], `Go & friends,https://go.dev/?a=1&b=2,dev/go,"go, lang","The Go`) {
+		t.Errorf("First row = %s", lines[1])
+	}
+
+	if _, err := WriteCSV(&buf, testLibrary(), "nope", 0); err == nil {
+		t.Error("WriteCSV() of a missing category should fail")
+	}
+}
+
+func TestWriteCSV_ReimportEdits(t *testing.T) {
+	data := testLibrary()
+	var buf bytes.Buffer
+	if _, err := WriteCSV(&buf, data, "", 0); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	imp, err := importer.ParseCSV(&buf, importer.CSVOptions{})
+	if err != nil {
+		t.Fatalf("ParseCSV() error = %v", err)
+	}
+
+	// Edit a copy the way one would in a spreadsheet, then import it back
+	edited := &storage.Data{}
+	if _, err := importer.Apply(edited, imp); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	b := edited.Bookmarks[0]
+	b.Tags = []string{"golang"}
+	b.Description = "Edited"
+	b.Keyword = "go"
+	buf.Reset()
+	if _, err := WriteCSV(&buf, edited, "", 0); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	if imp, err = importer.ParseCSV(&buf, importer.CSVOptions{}); err != nil {
+		t.Fatalf("ParseCSV() error = %v", err)
+	}
+
+	result, err := importer.Apply(data, imp)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(result.Updated) != 1 || len(result.Unchanged) != len(data.Bookmarks)-1 {
+		t.Fatalf("Apply() updated %d and left %d unchanged, want 1 and %d", len(result.Updated), len(result.Unchanged), len(data.Bookmarks)-1)
+	}
+	got := data.FindBookmark(b.ID)
+	if !reflect.DeepEqual(got.Tags, b.Tags) || got.Description != b.Description || got.Keyword != b.Keyword {
+		t.Errorf("Re-import = %+v, want the edited tags, description and keyword", got)
+	}
+}
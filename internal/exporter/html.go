@@ -0,0 +1,153 @@
+// Package exporter writes a library in formats browsers and other tools can
+// import.
+package exporter
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/internal/category"
+	"github.com/tom-023/ubm/internal/storage"
+)
+
+const htmlHeader = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+`
+
+// WriteHTML writes the library as a Netscape bookmark file, which Chrome,
+// Firefox and other browsers import with categories as nested folders and
+// keywords as Firefox keyword shortcuts. With a category, only it and its
+// subcategories are written, as a folder of its own. It returns the number of
+// bookmarks written.
+func WriteHTML(w io.Writer, data *storage.Data, categoryPath string) (int, error) {
+	root, err := categoryTree(data, categoryPath)
+	if err != nil {
+		return 0, err
+	}
+
+	hw := &htmlWriter{w: bufio.NewWriter(w), byCategory: map[string][]*bookmark.Bookmark{}}
+	for _, b := range data.Bookmarks {
+		hw.byCategory[b.Category] = append(hw.byCategory[b.Category], b)
+	}
+
+	hw.write(htmlHeader)
+	hw.write("<DL><p>\n")
+	if root.IsRoot {
+		hw.contents(root, 1)
+	} else {
+		hw.folder(root, 1)
+	}
+	hw.write("</DL><p>\n")
+
+	if hw.err == nil {
+		hw.err = hw.w.Flush()
+	}
+	return hw.count, hw.err
+}
+
+// categoryTree builds the category tree of data, including categories only
+// bookmarks mention, and returns the node of categoryPath
+func categoryTree(data *storage.Data, categoryPath string) (*category.Node, error) {
+	categories := append([]string{}, data.Categories...)
+	counts := make(map[string]int)
+	for _, b := range data.Bookmarks {
+		counts[b.Category]++
+		categories = append(categories, b.Category)
+	}
+	root := category.NewManager().BuildTree(categories, counts)
+
+	categoryPath = strings.Trim(categoryPath, "/")
+	if categoryPath == "" {
+		return root, nil
+	}
+	if node := findNode(root, categoryPath); node != nil {
+		return node, nil
+	}
+	return nil, fmt.Errorf("category %s does not exist", categoryPath)
+}
+
+func findNode(node *category.Node, path string) *category.Node {
+	for _, child := range node.Children {
+		if child.Path == path {
+			return child
+		}
+		if strings.HasPrefix(path, child.Path+"/") {
+			return findNode(child, path)
+		}
+	}
+	return nil
+}
+
+type htmlWriter struct {
+	w          *bufio.Writer
+	byCategory map[string][]*bookmark.Bookmark
+	count      int
+	err        error
+}
+
+func (hw *htmlWriter) write(s string) {
+	if hw.err == nil {
+		_, hw.err = hw.w.WriteString(s)
+	}
+}
+
+func (hw *htmlWriter) folder(node *category.Node, depth int) {
+	indent := strings.Repeat("    ", depth)
+	hw.write(fmt.Sprintf("%s<DT><H3>%s</H3>\n", indent, html.EscapeString(node.Name)))
+	hw.write(indent + "<DL><p>\n")
+	hw.contents(node, depth+1)
+	hw.write(indent + "</DL><p>\n")
+}
+
+// contents writes the subfolders of a category, then its bookmarks
+func (hw *htmlWriter) contents(node *category.Node, depth int) {
+	for _, child := range node.Children {
+		// The root's bookmarks are written below, not as a folder
+		if child.Path == "" {
+			continue
+		}
+		hw.folder(child, depth)
+	}
+
+	indent := strings.Repeat("    ", depth)
+	for _, b := range hw.byCategory[node.Path] {
+		hw.bookmark(b, indent)
+	}
+}
+
+func (hw *htmlWriter) bookmark(b *bookmark.Bookmark, indent string) {
+	attrs := fmt.Sprintf(`HREF="%s"`, html.EscapeString(b.URL))
+	if !b.CreatedAt.IsZero() {
+		attrs += fmt.Sprintf(` ADD_DATE="%s"`, unixSeconds(b.CreatedAt))
+	}
+	if !b.UpdatedAt.IsZero() {
+		attrs += fmt.Sprintf(` LAST_MODIFIED="%s"`, unixSeconds(b.UpdatedAt))
+	}
+	if len(b.Tags) > 0 {
+		attrs += fmt.Sprintf(` TAGS="%s"`, html.EscapeString(strings.Join(b.Tags, ",")))
+	}
+	if b.Keyword != "" {
+		attrs += fmt.Sprintf(` SHORTCUTURL="%s"`, html.EscapeString(b.Keyword))
+	}
+
+	hw.write(fmt.Sprintf("%s<DT><A %s>%s</A>\n", indent, attrs, html.EscapeString(b.Title)))
+	if b.Description != "" {
+		hw.write(fmt.Sprintf("%s<DD>%s\n", indent, html.EscapeString(b.Description)))
+	}
+	hw.count++
+}
+
+func unixSeconds(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
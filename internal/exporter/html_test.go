@@ -0,0 +1,155 @@
+package exporter
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/internal/importer"
+	"github.com/tom-023/ubm/internal/storage"
+)
+
+func testLibrary() *storage.Data {
+	created := time.Unix(1700000000, 0)
+	newBookmark := func(title, url, category, description string, tags ...string) *bookmark.Bookmark {
+		b := bookmark.New(title, url, category)
+		b.Description = description
+		b.Tags = tags
+		b.CreatedAt = created
+		b.UpdatedAt = created.Add(time.Hour)
+		return b
+	}
+
+	gopkg := newBookmark("Go packages", "https://pkg.go.dev/search?q=%s", "dev/go", "")
+	gopkg.Keyword = "gopkg"
+
+	data := &storage.Data{}
+	data.AddCategory("dev/empty")
+	for _, b := range []*bookmark.Bookmark{
+		newBookmark("Go & friends", "https://go.dev/?a=1&b=2", "dev/go", "The Go\nprogramming language", "go", "lang"),
+		newBookmark(`"Rust" <book>`, "https://doc.rust-lang.org/book/", "dev", ""),
+		newBookmark("News", "https://news.ycombinator.com", "", "Read daily", "news"),
+		gopkg,
+		newBookmark("日本語", "https://example.jp/", "読み物", ""),
+	} {
+		if err := data.AddBookmark(b); err != nil {
+			panic(err)
+		}
+	}
+	return data
+}
+
+type entry struct {
+	Title, URL, Category, Description, Keyword string
+	Tags                                       []string
+	CreatedAt, UpdatedAt                       int64
+}
+
+func entries(bookmarks []*bookmark.Bookmark) []entry {
+	var result []entry
+	for _, b := range bookmarks {
+		tags := b.Tags
+		if len(tags) == 0 {
+			tags = nil
+		}
+		result = append(result, entry{b.Title, b.URL, b.Category, b.Description, b.Keyword, tags, b.CreatedAt.Unix(), b.UpdatedAt.Unix()})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].URL < result[j].URL })
+	return result
+}
+
+func TestWriteHTML_RoundTrip(t *testing.T) {
+	data := testLibrary()
+
+	var buf bytes.Buffer
+	n, err := WriteHTML(&buf, data, "")
+	if err != nil {
+		t.Fatalf("WriteHTML() error = %v", err)
+	}
+	if n != len(data.Bookmarks) {
+		t.Errorf("WriteHTML() = %d, want %d", n, len(data.Bookmarks))
+	}
+
+	imp, err := importer.ParseHTML(&buf)
+	if err != nil {
+		t.Fatalf("ParseHTML() error = %v", err)
+	}
+	imported := &storage.Data{}
+	result, err := importer.Apply(imported, imp)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(result.Duplicates) > 0 || len(result.Invalid) > 0 {
+		t.Errorf("Apply() skipped %+v", result)
+	}
+
+	if got, want := entries(imported.Bookmarks), entries(data.Bookmarks); !reflect.DeepEqual(got, want) {
+		t.Errorf("Round trip =\n%+v\nwant\n%+v", got, want)
+	}
+	for _, c := range []string{"dev", "dev/empty", "dev/go", "読み物"} {
+		if !imported.HasCategory(c) {
+			t.Errorf("Category %s was lost, got %v", c, imported.Categories)
+		}
+	}
+}
+
+func TestWriteHTML_Category(t *testing.T) {
+	var buf bytes.Buffer
+	n, err := WriteHTML(&buf, testLibrary(), "dev/")
+	if err != nil {
+		t.Fatalf("WriteHTML() error = %v", err)
+	}
+	if n != 3 {
+		t.Errorf("WriteHTML() = %d, want the 3 bookmarks under dev", n)
+	}
+
+	imp, err := importer.ParseHTML(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var categories []string
+	for _, b := range imp.Bookmarks {
+		categories = append(categories, b.Category)
+	}
+	sort.Strings(categories)
+	if want := []string{"dev", "dev/go", "dev/go"}; !reflect.DeepEqual(categories, want) {
+		t.Errorf("Categories = %v, want %v", categories, want)
+	}
+}
+
+func TestWriteHTML_Format(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := WriteHTML(&buf, testLibrary(), ""); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"<!DOCTYPE NETSCAPE-Bookmark-file-1>\n",
+		`<DT><A HREF="https://go.dev/?a=1&amp;b=2" ADD_DATE="1700000000" LAST_MODIFIED="1700003600" TAGS="go,lang">Go &amp; friends</A>`,
+		`<DT><A HREF="https://doc.rust-lang.org/book/" ADD_DATE="1700000000" LAST_MODIFIED="1700003600">&#34;Rust&#34; &lt;book&gt;</A>`,
+		"    <DT><H3>dev</H3>\n    <DL><p>\n        <DT><H3>empty</H3>\n        <DL><p>\n        </DL><p>\n",
+		"<DD>Read daily\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Output is missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "uncategorized") {
+		t.Errorf("Uncategorized bookmarks should be at the top level:\n%s", out)
+	}
+}
+
+func TestWriteHTML_UnknownCategory(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := WriteHTML(&buf, testLibrary(), "nope"); err == nil {
+		t.Error("WriteHTML() of a missing category should fail")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("WriteHTML() wrote %q for a missing category", buf.String())
+	}
+}
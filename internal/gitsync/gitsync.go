@@ -0,0 +1,322 @@
+// Package gitsync keeps a library in a git repository: every save becomes a
+// commit, and Sync exchanges commits with a remote, merging the library
+// bookmark by bookmark instead of line by line.
+package gitsync
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/tom-023/ubm/internal/storage"
+)
+
+// ErrNotRepository is returned when the library is not inside a git work tree
+var ErrNotRepository = errors.New("not a git repository")
+
+// Repo runs git in the directory of a library
+type Repo struct {
+	dir string
+}
+
+// Open returns the repository that contains dir
+func Open(dir string) (*Repo, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git is not installed: %w", err)
+	}
+
+	r := &Repo{dir: dir}
+	if out, err := r.git("rev-parse", "--is-inside-work-tree"); err != nil || out != "true" {
+		return nil, fmt.Errorf("%s: %w (run git init there first)", dir, ErrNotRepository)
+	}
+	return r, nil
+}
+
+// git runs a git command and returns its trimmed output
+func (r *Repo) git(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", append([]string{"-C", r.dir}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stdout.String())
+		}
+		return "", &Error{Args: args, Output: msg, Err: err}
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// Error is a failed git command
+type Error struct {
+	Args   []string
+	Output string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Output == "" {
+		return fmt.Sprintf("git %s: %v", e.Args[0], e.Err)
+	}
+	return fmt.Sprintf("git %s: %s", e.Args[0], e.Output)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// exitCode returns the exit status of a failed git command, or -1
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// Commit commits the library file alone, leaving anything else in the
+// repository untouched. While a merge is in progress the file is only
+// staged, and the merge commit picks it up.
+func (r *Repo) Commit(path, message string) error {
+	if _, err := r.git("add", "--", path); err != nil {
+		return err
+	}
+
+	// Saving without changes, e.g. after a failed edit, is not worth a commit
+	if _, err := r.git("diff", "--cached", "--quiet", "--", path); err == nil {
+		return nil
+	} else if exitCode(err) != 1 {
+		return err
+	}
+
+	if r.merging() {
+		return nil
+	}
+
+	_, err := r.git("commit", "--quiet", "--no-verify", "--message", message, "--", path)
+	return err
+}
+
+func (r *Repo) merging() bool {
+	_, err := r.git("rev-parse", "--quiet", "--verify", "MERGE_HEAD")
+	return err == nil
+}
+
+// relPath returns path relative to the repository directory, in the form
+// git understands in "<rev>:<path>"
+func (r *Repo) relPath(path string) (string, error) {
+	rel, err := filepath.Rel(r.dir, path)
+	if err != nil {
+		return "", err
+	}
+	return "./" + filepath.ToSlash(rel), nil
+}
+
+// show returns the file at rel in rev, or nil if it does not exist there
+func (r *Repo) show(rev, rel string) ([]byte, error) {
+	if _, err := r.git("cat-file", "-e", rev+":"+rel); err != nil {
+		return nil, nil
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", "-C", r.dir, "show", rev+":"+rel)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, &Error{Args: []string{"show", rev + ":" + rel}, Output: strings.TrimSpace(stderr.String()), Err: err}
+	}
+	return stdout.Bytes(), nil
+}
+
+// Result describes what Sync did
+type Result struct {
+	// Pulled is set if changes from the remote were brought in
+	Pulled bool
+	// Merged is set if both sides had changes and they were merged
+	Merged bool
+	// Pushed is set if local commits were sent to the remote
+	Pushed bool
+	// Conflicts lists bookmarks both sides changed, with the version kept
+	Conflicts []storage.MergeConflict
+}
+
+// Sync pulls the current branch from remote, merges the library bookmark by
+// bookmark, and pushes the result. Other files in the repository are merged
+// by git as usual; if they conflict, the merge is aborted.
+func Sync(r *Repo, s *storage.Storage, remote string) (*Result, error) {
+	rel, err := r.relPath(s.Path())
+	if err != nil {
+		return nil, err
+	}
+	if r.merging() {
+		return nil, fmt.Errorf("a git merge is in progress in %s; finish or abort it first", r.dir)
+	}
+
+	// Changes saved before git mode was turned on
+	if status, err := r.git("status", "--porcelain", "--", rel); err != nil {
+		return nil, err
+	} else if status != "" {
+		if err := r.Commit(s.Path(), "update library"); err != nil {
+			return nil, err
+		}
+	}
+
+	branch, err := r.git("symbolic-ref", "--short", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("cannot sync without a current branch: %w", err)
+	}
+
+	result := &Result{}
+
+	if _, err := r.git("ls-remote", "--exit-code", "--heads", remote, branch); err != nil {
+		if exitCode(err) != 2 {
+			return nil, err
+		}
+		// The remote has no such branch yet
+		if _, err := r.git("rev-parse", "--quiet", "--verify", "HEAD"); err != nil {
+			// ... and neither has this repository
+			return result, nil
+		}
+		if err := r.push(remote, branch); err != nil {
+			return nil, err
+		}
+		result.Pushed = true
+		return result, nil
+	}
+
+	if _, err := r.git("fetch", "--quiet", remote, branch); err != nil {
+		return nil, err
+	}
+	theirs, err := r.git("rev-parse", "FETCH_HEAD")
+	if err != nil {
+		return nil, err
+	}
+	ours, err := r.git("rev-parse", "--quiet", "--verify", "HEAD")
+	if err != nil {
+		// Nothing was ever committed here
+		ours = ""
+	}
+
+	switch {
+	case ours == theirs:
+		return result, nil
+	case ours != "" && r.isAncestor(theirs, ours):
+		// Only local commits
+	case ours == "" || r.isAncestor(ours, theirs):
+		if err := r.fastForward(s, theirs); err != nil {
+			return nil, err
+		}
+		result.Pulled = true
+		return result, nil
+	default:
+		conflicts, err := r.merge(s, rel, ours, theirs, fmt.Sprintf("sync: merge %s/%s", remote, branch))
+		if err != nil {
+			return nil, err
+		}
+		result.Pulled = true
+		result.Merged = true
+		result.Conflicts = conflicts
+	}
+
+	if err := r.push(remote, branch); err != nil {
+		return nil, err
+	}
+	result.Pushed = true
+	return result, nil
+}
+
+func (r *Repo) isAncestor(ancestor, rev string) bool {
+	_, err := r.git("merge-base", "--is-ancestor", ancestor, rev)
+	return err == nil
+}
+
+func (r *Repo) push(remote, branch string) error {
+	if _, err := r.git("push", "--quiet", remote, "HEAD:refs/heads/"+branch); err != nil {
+		return fmt.Errorf("failed to push (run ubm sync again if the remote changed meanwhile): %w", err)
+	}
+	return nil
+}
+
+// fastForward moves to rev and journals how it changed the library
+func (r *Repo) fastForward(s *storage.Storage, rev string) error {
+	before, err := s.Load()
+	if err != nil {
+		return err
+	}
+	if _, err := r.git("merge", "--quiet", "--ff-only", rev); err != nil {
+		return err
+	}
+	after, err := s.Load()
+	if err != nil {
+		return err
+	}
+	if err := s.Journal().Record(before, after); err != nil {
+		return fmt.Errorf("pulled changes were not added to the history: %w", err)
+	}
+	return nil
+}
+
+// merge merges rev into HEAD. git merges the rest of the repository; the
+// library is merged with storage.Merge and saved through s, so it gets a
+// backup and a journal entry like any other change.
+func (r *Repo) merge(s *storage.Storage, rel, ours, theirs, message string) ([]storage.MergeConflict, error) {
+	baseRev, err := r.git("merge-base", ours, theirs)
+	if err != nil {
+		return nil, err
+	}
+
+	var sides [3]*storage.Data
+	for i, rev := range []string{baseRev, ours, theirs} {
+		raw, err := r.show(rev, rel)
+		if err != nil {
+			return nil, err
+		}
+		if raw == nil {
+			sides[i] = &storage.Data{}
+			continue
+		}
+		if sides[i], err = s.Decode(raw); err != nil {
+			return nil, fmt.Errorf("failed to read %s at %s: %w", rel, rev, err)
+		}
+	}
+	merged := storage.Merge(sides[0], sides[1], sides[2])
+
+	// The library may come out of git's line merge with conflict markers,
+	// which is why our version is put back before saving the real merge
+	if _, err := r.git("merge", "--quiet", "--no-ff", "--no-commit", theirs); err != nil && !r.merging() {
+		return nil, err
+	}
+	abort := func(err error) ([]storage.MergeConflict, error) {
+		r.git("merge", "--abort")
+		return nil, err
+	}
+
+	if _, err := r.git("checkout", ours, "--", rel); err != nil {
+		return abort(err)
+	}
+	unmerged, err := r.git("diff", "--name-only", "--diff-filter=U")
+	if err != nil {
+		return abort(err)
+	}
+	if unmerged != "" {
+		return abort(fmt.Errorf("other files in the repository conflict, resolve them with git: %s", strings.ReplaceAll(unmerged, "\n", ", ")))
+	}
+
+	err = s.Update(func(data *storage.Data) error {
+		data.Bookmarks = merged.Data.Bookmarks
+		data.Categories = merged.Data.Categories
+		data.Trash = merged.Data.Trash
+		return nil
+	})
+	if err != nil {
+		return abort(err)
+	}
+
+	if _, err := r.git("commit", "--quiet", "--no-verify", "--message", message); err != nil {
+		return abort(err)
+	}
+	return merged.Conflicts, nil
+}
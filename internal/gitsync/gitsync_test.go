@@ -0,0 +1,237 @@
+package gitsync
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/internal/storage"
+	"github.com/tom-023/ubm/internal/testutil"
+)
+
+func run(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// remote creates a bare repository to sync with, isolated from the user's
+// git configuration
+func remote(t *testing.T) (root, bare string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	root, cleanup := testutil.TempDir(t)
+	t.Cleanup(cleanup)
+
+	t.Setenv("HOME", root)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "ubm test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "ubm@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "ubm test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "ubm@example.com")
+
+	bare = filepath.Join(root, "remote.git")
+	run(t, root, "init", "--quiet", "--bare", "--initial-branch=main", bare)
+	return root, bare
+}
+
+// machine clones the remote and opens a git-backed library in the clone
+func machine(t *testing.T, root, bare, name string) (*Repo, *storage.Storage) {
+	t.Helper()
+	dir := filepath.Join(root, name)
+	run(t, root, "clone", "--quiet", bare, dir)
+	run(t, dir, "symbolic-ref", "HEAD", "refs/heads/main")
+
+	repo, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	opts := storage.DefaultOptions()
+	opts.Committer = repo
+	s, err := storage.NewWithOptions(dir, opts)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	return repo, s
+}
+
+func add(t *testing.T, s *storage.Storage, title string) *bookmark.Bookmark {
+	t.Helper()
+	b := bookmark.New(title, "https://"+strings.ToLower(title)+".example.com", "dev")
+	if err := s.AddBookmark(b); err != nil {
+		t.Fatalf("AddBookmark() failed: %v", err)
+	}
+	return b
+}
+
+func sync(t *testing.T, repo *Repo, s *storage.Storage) *Result {
+	t.Helper()
+	result, err := Sync(repo, s, "origin")
+	if err != nil {
+		t.Fatalf("Sync() failed: %v", err)
+	}
+	return result
+}
+
+func libraryTitles(t *testing.T, s *storage.Storage) []string {
+	t.Helper()
+	data, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	var titles []string
+	for _, b := range data.Bookmarks {
+		titles = append(titles, b.Title)
+	}
+	sort.Strings(titles)
+	return titles
+}
+
+func TestOpen_NotRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+	t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(dir))
+
+	if _, err := Open(dir); err == nil {
+		t.Error("Open() should fail outside a git repository")
+	}
+}
+
+func TestCommitOnSave(t *testing.T) {
+	root, bare := remote(t)
+	repo, s := machine(t, root, bare, "laptop")
+
+	add(t, s, "Go")
+	if got := run(t, repo.dir, "log", "-1", "--format=%s"); got != "add: Go" {
+		t.Errorf("Commit message = %q, want %q", got, "add: Go")
+	}
+
+	// Only the library is committed, never backups or the journal
+	files := run(t, repo.dir, "ls-files")
+	if files != "bookmarks.json" {
+		t.Errorf("Tracked files = %q, want only bookmarks.json", files)
+	}
+
+	// Other work in the repository is left alone
+	if err := os.WriteFile(filepath.Join(repo.dir, "vimrc"), []byte("set nu\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run(t, repo.dir, "add", "vimrc")
+	add(t, s, "Rust")
+	if got := run(t, repo.dir, "diff", "--cached", "--name-only"); got != "vimrc" {
+		t.Errorf("Staged files after a save = %q, want vimrc still staged", got)
+	}
+}
+
+func TestSync(t *testing.T) {
+	root, bare := remote(t)
+
+	laptopRepo, laptop := machine(t, root, bare, "laptop")
+	add(t, laptop, "Go")
+	if result := sync(t, laptopRepo, laptop); !result.Pushed {
+		t.Error("First sync should push to the empty remote")
+	}
+
+	desktopRepo, desktop := machine(t, root, bare, "desktop")
+	if got := libraryTitles(t, desktop); !reflect.DeepEqual(got, []string{"Go"}) {
+		t.Fatalf("Cloned library = %v, want [Go]", got)
+	}
+
+	// Both machines add a bookmark to the same category at the same time
+	add(t, laptop, "Rust")
+	add(t, desktop, "Zig")
+
+	if result := sync(t, laptopRepo, laptop); !result.Pushed || result.Pulled {
+		t.Errorf("Laptop sync = %+v, want a plain push", result)
+	}
+	result := sync(t, desktopRepo, desktop)
+	if !result.Merged || !result.Pushed || len(result.Conflicts) != 0 {
+		t.Errorf("Desktop sync = %+v, want a merge without conflicts", result)
+	}
+	if result := sync(t, laptopRepo, laptop); !result.Pulled || result.Merged {
+		t.Errorf("Second laptop sync = %+v, want a fast-forward", result)
+	}
+
+	want := []string{"Go", "Rust", "Zig"}
+	for name, s := range map[string]*storage.Storage{"laptop": laptop, "desktop": desktop} {
+		if got := libraryTitles(t, s); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s library = %v, want %v", name, got, want)
+		}
+	}
+	if got := run(t, laptopRepo.dir, "rev-parse", "HEAD"); got != run(t, desktopRepo.dir, "rev-parse", "HEAD") {
+		t.Error("Both machines should end up on the same commit")
+	}
+	if result := sync(t, desktopRepo, desktop); result.Pulled || result.Pushed {
+		t.Errorf("Sync after everything is in = %+v, want nothing to do", result)
+	}
+
+	// Pulled changes show up in each machine's history
+	for name, s := range map[string]*storage.Storage{"laptop": laptop, "desktop": desktop} {
+		entries, err := s.Journal().Entries(storage.JournalFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		added := map[string]bool{}
+		for _, e := range entries {
+			if e.Op == storage.OpAdd {
+				added[e.After.Title] = true
+			}
+		}
+		if !added["Rust"] || !added["Zig"] {
+			t.Errorf("%s history has adds %v, want Rust and Zig", name, added)
+		}
+	}
+}
+
+func TestSync_SameBookmarkChanged(t *testing.T) {
+	root, bare := remote(t)
+
+	laptopRepo, laptop := machine(t, root, bare, "laptop")
+	b := add(t, laptop, "Go")
+	sync(t, laptopRepo, laptop)
+	desktopRepo, desktop := machine(t, root, bare, "desktop")
+
+	rename := func(s *storage.Storage, title string) {
+		t.Helper()
+		err := s.Update(func(data *storage.Data) error {
+			target, err := data.GetBookmark(b.ID)
+			if err != nil {
+				return err
+			}
+			target.SetTitle(title)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	rename(laptop, "Go (laptop)")
+	rename(desktop, "Go (desktop)")
+
+	sync(t, laptopRepo, laptop)
+	result := sync(t, desktopRepo, desktop)
+	if len(result.Conflicts) != 1 {
+		t.Fatalf("Conflicts = %+v, want 1", result.Conflicts)
+	}
+	if c := result.Conflicts[0]; c.Resolved == nil || c.Resolved.Title != "Go (desktop)" {
+		t.Errorf("Kept %+v, want the newer desktop title", c.Resolved)
+	}
+	if got := libraryTitles(t, desktop); !reflect.DeepEqual(got, []string{"Go (desktop)"}) {
+		t.Errorf("Desktop library = %v, want [Go (desktop)]", got)
+	}
+}
@@ -0,0 +1,357 @@
+// Package httpsync keeps a library in sync with a copy on a WebDAV or plain
+// HTTP server that supports GET and PUT, such as Nextcloud or a NAS. ETags
+// tell whether the copy on the server changed since the last sync, and when
+// both sides changed the library is merged bookmark by bookmark.
+package httpsync
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tom-023/ubm/internal/storage"
+)
+
+// ErrChanged is returned when the file on the server was changed by someone
+// else between reading and writing it
+var ErrChanged = errors.New("remote library was changed by someone else")
+
+// stateFileName is kept next to the library and remembers the last sync
+const stateFileName = "sync-state.json"
+
+// maxAttempts is how often Sync starts over when another machine uploads
+// while it is merging
+const maxAttempts = 3
+
+// Client reads and writes one library file on a server
+type Client struct {
+	url      string
+	username string
+	password string
+	http     *http.Client
+}
+
+// NewClient returns a client for the file at url. Credentials are sent with
+// basic authentication if username is set.
+func NewClient(url, username, password string) *Client {
+	return &Client{
+		url:      url,
+		username: username,
+		password: password,
+		http:     &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Remote is the library file as found on the server
+type Remote struct {
+	Data []byte
+	// ETag is empty if the server does not send one
+	ETag string
+}
+
+func (c *Client) do(method string, body []byte, header http.Header) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, c.url, r)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: access denied (%s), check the username and password", method, c.url, resp.Status)
+	case http.StatusPreconditionFailed:
+		resp.Body.Close()
+		return nil, ErrChanged
+	}
+	return resp, nil
+}
+
+// Get downloads the file, or returns nil if it does not exist yet
+func (c *Client) Get() (*Remote, error) {
+	resp, err := c.do(http.MethodGet, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", c.url, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("GET %s: %w", c.url, err)
+	}
+	return &Remote{Data: data, ETag: resp.Header.Get("ETag")}, nil
+}
+
+// Put uploads data in place of prev, the file as returned by Get, or where
+// no file exists yet if prev is nil. If the file on the server changed in
+// between it returns ErrChanged. The server checks that through the ETag; if
+// it does not send one, the content is compared just before uploading, which
+// narrows the window for a concurrent upload but cannot close it. Put
+// returns the new ETag, which is empty if the server does not tell.
+func (c *Client) Put(data []byte, prev *Remote) (string, error) {
+	header := http.Header{}
+	switch {
+	case prev == nil:
+		header.Set("If-None-Match", "*")
+	case prev.ETag != "":
+		header.Set("If-Match", prev.ETag)
+	default:
+		current, err := c.Get()
+		if err != nil {
+			return "", err
+		}
+		if current == nil || !bytes.Equal(current.Data, prev.Data) {
+			return "", ErrChanged
+		}
+	}
+
+	resp, err := c.do(http.MethodPut, data, header)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+	case http.StatusConflict:
+		// WebDAV servers do not create missing parent collections
+		return "", fmt.Errorf("PUT %s: %s (does the folder exist on the server?)", c.url, resp.Status)
+	default:
+		return "", fmt.Errorf("PUT %s: %s", c.url, resp.Status)
+	}
+
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		return etag, nil
+	}
+	// Some servers only report the ETag when asked
+	resp, err = c.do(http.MethodHead, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("ETag"), nil
+}
+
+// state is what the last sync left behind: the file as it was exchanged
+// with the server, which is the base of the next merge, and its ETag
+type state struct {
+	URL  string `json:"url"`
+	ETag string `json:"etag,omitempty"`
+	Base []byte `json:"base,omitempty"`
+}
+
+func statePath(s *storage.Storage) string {
+	return filepath.Join(filepath.Dir(s.Path()), stateFileName)
+}
+
+// loadState returns the state of the last sync with url. Syncing with a
+// different URL starts from scratch.
+func loadState(path, url string) (*state, error) {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &state{URL: url}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync state: %w", err)
+	}
+
+	var st state
+	if err := json.Unmarshal(raw, &st); err != nil || st.URL != url {
+		return &state{URL: url}, nil
+	}
+	return &st, nil
+}
+
+func (st *state) save(path string) error {
+	raw, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0600); err != nil {
+		return fmt.Errorf("failed to write sync state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write sync state: %w", err)
+	}
+	return nil
+}
+
+// Result describes what Sync did
+type Result struct {
+	// Pulled is set if changes from the server were brought in
+	Pulled bool
+	// Merged is set if both sides had changes and they were merged
+	Merged bool
+	// Pushed is set if the library was uploaded
+	Pushed bool
+	// Conflicts lists bookmarks both sides changed, with the version kept
+	Conflicts []storage.MergeConflict
+	// BaseLost is set if the library as of the last sync could not be read,
+	// for example after its key changed, so the merge could not tell a
+	// deletion on one side from an addition on the other
+	BaseLost bool
+}
+
+// Sync exchanges changes between the library in s and the file on the
+// server. The file is uploaded as it is stored, so an encrypted library stays
+// encrypted on the server.
+func Sync(c *Client, s *storage.Storage) (*Result, error) {
+	path := statePath(s)
+	st, err := loadState(path, c.url)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		result, err := syncOnce(c, s, st, path)
+		if errors.Is(err, ErrChanged) && attempt < maxAttempts {
+			continue
+		}
+		return result, err
+	}
+}
+
+func syncOnce(c *Client, s *storage.Storage, st *state, path string) (*Result, error) {
+	result := &Result{}
+
+	remote, err := c.Get()
+	if err != nil {
+		return nil, err
+	}
+	local, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	base, baseLost := &storage.Data{}, false
+	if st.Base != nil {
+		if b, err := s.Decode(st.Base); err == nil {
+			base = b
+		} else {
+			// Typically sealed with a key the library no longer uses. Without
+			// a base, bookmarks deleted on one side come back from the other.
+			baseLost = true
+		}
+	}
+	localChanged := len(storage.Diff(base, local)) > 0
+
+	if remote == nil {
+		if !localChanged && len(storage.Diff(&storage.Data{}, local)) == 0 {
+			// Nothing here and nothing there
+			return result, nil
+		}
+		if err := push(c, s, st, path, nil); err != nil {
+			return nil, err
+		}
+		result.Pushed = true
+		return result, nil
+	}
+
+	remoteChanged := remote.ETag != st.ETag
+	if remote.ETag == "" {
+		remoteChanged = !bytes.Equal(remote.Data, st.Base)
+	}
+
+	// After encrypting, decrypting or changing the key, the copy on the
+	// server must be replaced even though no bookmark changed
+	resealed := !s.SealedAsLibrary(remote.Data)
+
+	if !remoteChanged {
+		if localChanged || resealed {
+			if err := push(c, s, st, path, remote); err != nil {
+				return nil, err
+			}
+			result.Pushed = true
+		}
+		return result, nil
+	}
+
+	theirs, err := s.Decode(remote.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the remote library: %w", err)
+	}
+
+	merged := theirs
+	if localChanged {
+		m := storage.Merge(base, local, theirs)
+		merged = m.Data
+		result.Merged = true
+		result.BaseLost = baseLost
+		result.Conflicts = m.Conflicts
+	}
+
+	if len(storage.Diff(local, merged)) > 0 {
+		err := storage.UpdateFrom(s, local, func(data *storage.Data) error {
+			data.Bookmarks = merged.Bookmarks
+			data.Categories = merged.Categories
+			data.Trash = merged.Trash
+			return nil
+		})
+		if errors.Is(err, storage.ErrConflict) {
+			return nil, fmt.Errorf("the library was changed while syncing; run ubm sync again")
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Pulled = true
+	}
+
+	if len(storage.Diff(theirs, merged)) == 0 && !resealed {
+		// The server already has everything
+		st.ETag, st.Base = remote.ETag, remote.Data
+		if err := st.save(path); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	if err := push(c, s, st, path, remote); err != nil {
+		return nil, err
+	}
+	result.Pushed = true
+	return result, nil
+}
+
+// push uploads the library file in place of remote, and remembers it as the
+// base of the next sync
+func push(c *Client, s *storage.Storage, st *state, path string, remote *Remote) error {
+	raw, err := s.ReadRaw()
+	if err != nil {
+		return fmt.Errorf("failed to read library: %w", err)
+	}
+
+	etag, err := c.Put(raw, remote)
+	if err != nil {
+		return err
+	}
+
+	st.ETag, st.Base = etag, raw
+	return st.save(path)
+}
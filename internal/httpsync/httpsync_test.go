@@ -0,0 +1,334 @@
+package httpsync
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/internal/storage"
+	"github.com/tom-023/ubm/internal/testutil"
+)
+
+// davServer is a stand-in for a WebDAV server holding one file. It honours
+// If-Match and If-None-Match the way Nextcloud does.
+type davServer struct {
+	mu      sync.Mutex
+	data    []byte
+	version int
+	puts    int
+	// noETag makes the server behave like one that sends no ETags
+	noETag bool
+	// beforePut runs once before the next PUT is handled
+	beforePut func()
+}
+
+func (d *davServer) etag() string {
+	return fmt.Sprintf(`"v%d"`, d.version)
+}
+
+func (d *davServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, pass, ok := r.BasicAuth(); !ok || user != "alice" || pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method == http.MethodPut && d.beforePut != nil {
+		hook := d.beforePut
+		d.beforePut = nil
+		hook()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if d.data == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if !d.noETag {
+			w.Header().Set("ETag", d.etag())
+		}
+		if r.Method == http.MethodGet {
+			w.Write(d.data)
+		}
+	case http.MethodPut:
+		if match := r.Header.Get("If-Match"); match != "" && (d.data == nil || match != d.etag()) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		if r.Header.Get("If-None-Match") == "*" && d.data != nil {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		d.data = body
+		d.version++
+		d.puts++
+		if !d.noETag {
+			w.Header().Set("ETag", d.etag())
+		}
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func setup(t *testing.T) (*davServer, *Client) {
+	t.Helper()
+	dav := &davServer{}
+	server := httptest.NewServer(dav)
+	t.Cleanup(server.Close)
+	return dav, NewClient(server.URL+"/ubm/bookmarks.json", "alice", "secret")
+}
+
+func machine(t *testing.T) *storage.Storage {
+	t.Helper()
+	dir, cleanup := testutil.TempDir(t)
+	t.Cleanup(cleanup)
+	s, err := storage.New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	return s
+}
+
+func add(t *testing.T, s *storage.Storage, title string) {
+	t.Helper()
+	b := bookmark.New(title, "https://"+strings.ToLower(title)+".example.com", "dev")
+	if err := s.AddBookmark(b); err != nil {
+		t.Fatalf("AddBookmark() failed: %v", err)
+	}
+}
+
+func mustSync(t *testing.T, c *Client, s *storage.Storage) *Result {
+	t.Helper()
+	result, err := Sync(c, s)
+	if err != nil {
+		t.Fatalf("Sync() failed: %v", err)
+	}
+	return result
+}
+
+func libraryTitles(t *testing.T, s *storage.Storage) []string {
+	t.Helper()
+	data, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	var titles []string
+	for _, b := range data.Bookmarks {
+		titles = append(titles, b.Title)
+	}
+	sort.Strings(titles)
+	return titles
+}
+
+func TestSync(t *testing.T) {
+	dav, client := setup(t)
+	laptop, desktop := machine(t), machine(t)
+
+	if result := mustSync(t, client, laptop); result.Pushed || dav.puts != 0 {
+		t.Errorf("Syncing an empty library = %+v, want nothing uploaded", result)
+	}
+
+	add(t, laptop, "Go")
+	if result := mustSync(t, client, laptop); !result.Pushed {
+		t.Errorf("First sync = %+v, want a push", result)
+	}
+
+	if result := mustSync(t, client, desktop); !result.Pulled || result.Pushed {
+		t.Errorf("Desktop sync = %+v, want a plain pull", result)
+	}
+	if got := libraryTitles(t, desktop); !reflect.DeepEqual(got, []string{"Go"}) {
+		t.Fatalf("Desktop library = %v, want [Go]", got)
+	}
+	if result := mustSync(t, client, desktop); result.Pulled || result.Pushed {
+		t.Errorf("Second desktop sync = %+v, want nothing to do", result)
+	}
+
+	// Both machines change the library before syncing again
+	add(t, laptop, "Rust")
+	add(t, desktop, "Zig")
+	mustSync(t, client, laptop)
+	result := mustSync(t, client, desktop)
+	if !result.Merged || !result.Pushed || len(result.Conflicts) != 0 {
+		t.Errorf("Desktop sync = %+v, want a merge without conflicts", result)
+	}
+	if result := mustSync(t, client, laptop); !result.Pulled || result.Pushed {
+		t.Errorf("Laptop sync = %+v, want a plain pull", result)
+	}
+
+	want := []string{"Go", "Rust", "Zig"}
+	for name, s := range map[string]*storage.Storage{"laptop": laptop, "desktop": desktop} {
+		if got := libraryTitles(t, s); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s library = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestSync_ChangedWhileMerging(t *testing.T) {
+	dav, client := setup(t)
+	laptop, desktop := machine(t), machine(t)
+
+	add(t, laptop, "Go")
+	mustSync(t, client, laptop)
+	mustSync(t, client, desktop)
+
+	add(t, desktop, "Zig")
+	add(t, laptop, "Rust")
+	// The laptop uploads just before the desktop does
+	dav.beforePut = func() {
+		if _, err := Sync(client, laptop); err != nil {
+			t.Errorf("Laptop Sync() failed: %v", err)
+		}
+	}
+
+	mustSync(t, client, desktop)
+	if got, want := libraryTitles(t, desktop), []string{"Go", "Rust", "Zig"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Desktop library = %v, want %v after starting over", got, want)
+	}
+
+	mustSync(t, client, laptop)
+	if got, want := libraryTitles(t, laptop), []string{"Go", "Rust", "Zig"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Laptop library = %v, want %v", got, want)
+	}
+}
+
+func TestSync_Unauthorized(t *testing.T) {
+	_, client := setup(t)
+	client.password = "wrong"
+
+	s := machine(t)
+	add(t, s, "Go")
+	_, err := Sync(client, s)
+	if err == nil || !strings.Contains(err.Error(), "access denied") {
+		t.Errorf("Sync() error = %v, want access denied", err)
+	}
+}
+
+func TestClient_Put(t *testing.T) {
+	dav, client := setup(t)
+
+	etag, err := client.Put([]byte("one"), nil)
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if _, err := client.Put([]byte("two"), nil); !errors.Is(err, ErrChanged) {
+		t.Errorf("Put() over an existing file error = %v, want ErrChanged", err)
+	}
+	if _, err := client.Put([]byte("two"), &Remote{Data: []byte("one"), ETag: `"stale"`}); !errors.Is(err, ErrChanged) {
+		t.Errorf("Put() with a stale ETag error = %v, want ErrChanged", err)
+	}
+	if _, err := client.Put([]byte("two"), &Remote{Data: []byte("one"), ETag: etag}); err != nil {
+		t.Errorf("Put() with the current ETag error = %v", err)
+	}
+
+	remote, err := client.Get()
+	if err != nil || string(remote.Data) != "two" {
+		t.Errorf("Get() = %v, %v, want the last upload", remote, err)
+	}
+
+	// Without ETags the content read before is compared instead
+	dav.noETag = true
+	if _, err := client.Put([]byte("three"), &Remote{Data: []byte("one")}); !errors.Is(err, ErrChanged) {
+		t.Errorf("Put() over changed content error = %v, want ErrChanged", err)
+	}
+	if etag, err := client.Put([]byte("three"), &Remote{Data: []byte("two")}); err != nil || etag != "" {
+		t.Errorf("Put() over unchanged content = %q, %v", etag, err)
+	}
+}
+
+func TestSync_WithoutETags(t *testing.T) {
+	dav, client := setup(t)
+	dav.noETag = true
+	laptop, desktop := machine(t), machine(t)
+
+	add(t, laptop, "Go")
+	if result := mustSync(t, client, laptop); !result.Pushed {
+		t.Errorf("First sync = %+v, want a push", result)
+	}
+	add(t, laptop, "Rust")
+	if result := mustSync(t, client, laptop); !result.Pushed {
+		t.Errorf("Second sync = %+v, want a push", result)
+	}
+
+	add(t, desktop, "Zig")
+	if result := mustSync(t, client, desktop); !result.Merged || !result.Pushed {
+		t.Errorf("Desktop sync = %+v, want a merge", result)
+	}
+	if result := mustSync(t, client, laptop); !result.Pulled || result.Pushed {
+		t.Errorf("Laptop sync = %+v, want a plain pull", result)
+	}
+	if result := mustSync(t, client, laptop); result.Pulled || result.Pushed {
+		t.Errorf("Last sync = %+v, want nothing to do", result)
+	}
+
+	want := []string{"Go", "Rust", "Zig"}
+	for name, s := range map[string]*storage.Storage{"laptop": laptop, "desktop": desktop} {
+		if got := libraryTitles(t, s); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s library = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestSync_Reencrypted(t *testing.T) {
+	dav, client := setup(t)
+	laptop := machine(t)
+	add(t, laptop, "Go")
+	mustSync(t, client, laptop)
+
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+	reencrypt := func(name string) {
+		t.Helper()
+		var enc *storage.Encryption
+		if name != "" {
+			path := filepath.Join(dir, name)
+			if err := storage.GenerateKeyFile(path); err != nil {
+				t.Fatal(err)
+			}
+			var err error
+			if enc, err = storage.NewKeyFileEncryption(path); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := laptop.Reencrypt(enc); err != nil {
+			t.Fatalf("Reencrypt() failed: %v", err)
+		}
+	}
+	plaintext := func() bool { return strings.Contains(string(dav.data), "go.example.com") }
+
+	// No bookmark changed, but the server must not keep a plain copy
+	reencrypt("first.key")
+	if result := mustSync(t, client, laptop); !result.Pushed || plaintext() {
+		t.Errorf("Sync after encrypting = %+v, want the encrypted library uploaded", result)
+	}
+
+	// The last sync was sealed with the old key
+	reencrypt("second.key")
+	if result := mustSync(t, client, laptop); !result.Pushed || result.BaseLost {
+		t.Errorf("Sync after changing the key = %+v, want an upload", result)
+	}
+	if result := mustSync(t, client, laptop); result.Pushed || result.Pulled {
+		t.Errorf("Second sync = %+v, want nothing to do", result)
+	}
+
+	reencrypt("")
+	if result := mustSync(t, client, laptop); !result.Pushed || !plaintext() {
+		t.Errorf("Sync after decrypting = %+v, want the plain library uploaded", result)
+	}
+	if got := libraryTitles(t, laptop); !reflect.DeepEqual(got, []string{"Go"}) {
+		t.Errorf("Library = %v, want [Go]", got)
+	}
+}
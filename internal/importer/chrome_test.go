@@ -0,0 +1,142 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const chromeBookmarks = `{
+   "checksum": "0a1b2c",
+   "roots": {
+      "bookmark_bar": {
+         "children": [ {
+            "date_added": "13345000000000000",
+            "date_last_used": "0",
+            "guid": "a8c1d0e2-1111-4c5b-9d7e-000000000001",
+            "id": "5",
+            "name": "Go",
+            "type": "url",
+            "url": "https://go.dev/"
+         }, {
+            "children": [ {
+               "date_added": "13345000000000000",
+               "guid": "a8c1d0e2-1111-4c5b-9d7e-000000000002",
+               "id": "7",
+               "name": "Actions",
+               "type": "url",
+               "url": "https://github.com/features/actions"
+            } ],
+            "date_added": "13345000000000000",
+            "date_modified": "13345000000000000",
+            "guid": "a8c1d0e2-1111-4c5b-9d7e-000000000003",
+            "id": "6",
+            "name": "CI/CD",
+            "type": "folder"
+         }, {
+            "children": [  ],
+            "guid": "a8c1d0e2-1111-4c5b-9d7e-000000000004",
+            "id": "8",
+            "name": "Empty",
+            "type": "folder"
+         } ],
+         "guid": "0bc5d13f-2cba-5d74-951f-3f233fe6c908",
+         "id": "1",
+         "name": "Bookmarks bar",
+         "type": "folder"
+      },
+      "other": {
+         "children": [ {
+            "date_added": "13345000000000000",
+            "id": "9",
+            "name": "",
+            "type": "url",
+            "url": "https://example.com/"
+         } ],
+         "guid": "82b081ec-3dd3-529c-8475-ab6c344590dd",
+         "id": "2",
+         "name": "Other bookmarks",
+         "type": "folder"
+      },
+      "synced": {
+         "children": [  ],
+         "guid": "4cf2e351-0e85-532b-bb37-df045d8f8d0f",
+         "id": "3",
+         "name": "Mobile bookmarks",
+         "type": "folder"
+      }
+   },
+   "version": 1
+}`
+
+func TestParseChrome(t *testing.T) {
+	imp, err := ParseChrome(strings.NewReader(chromeBookmarks))
+	if err != nil {
+		t.Fatalf("ParseChrome() error = %v", err)
+	}
+
+	type entry struct{ Title, URL, Category string }
+	var got []entry
+	for _, b := range imp.Bookmarks {
+		got = append(got, entry{b.Title, b.URL, b.Category})
+	}
+	want := []entry{
+		{"Go", "https://go.dev/", "Bookmarks bar"},
+		{"Actions", "https://github.com/features/actions", "Bookmarks bar/CI-CD"},
+		{"https://example.com/", "https://example.com/", "Other bookmarks"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseChrome() = %+v, want %+v", got, want)
+	}
+
+	wantCategories := []string{"Bookmarks bar", "Bookmarks bar/CI-CD", "Bookmarks bar/Empty", "Other bookmarks"}
+	if !reflect.DeepEqual(imp.Categories, wantCategories) {
+		t.Errorf("Categories = %v, want %v", imp.Categories, wantCategories)
+	}
+
+	// 13345000000000000µs after 1601-01-01
+	if want := time.Unix(1700526400, 0); !imp.Bookmarks[0].CreatedAt.Equal(want) {
+		t.Errorf("CreatedAt = %v, want %v", imp.Bookmarks[0].CreatedAt, want)
+	}
+
+	again, err := ParseChrome(strings.NewReader(chromeBookmarks))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, b := range imp.Bookmarks {
+		if b.ID != again.Bookmarks[i].ID {
+			t.Errorf("%s has ID %s, then %s; want the same one", b.Title, b.ID, again.Bookmarks[i].ID)
+		}
+	}
+	if imp.Bookmarks[0].ID == imp.Bookmarks[1].ID {
+		t.Error("Every bookmark needs its own ID")
+	}
+}
+
+func TestParseChrome_UnnamedFolder(t *testing.T) {
+	const file = `{"roots": {"bookmark_bar": {"name": "Bookmarks bar", "type": "folder", "children": [
+		{"name": "  ", "type": "folder", "children": [
+			{"name": "", "type": "folder", "children": [
+				{"name": "Go", "type": "url", "url": "https://go.dev/"}
+			]}
+		]}
+	]}}}`
+
+	imp, err := ParseChrome(strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("ParseChrome() error = %v", err)
+	}
+	if len(imp.Bookmarks) != 1 || imp.Bookmarks[0].Category != "Bookmarks bar" {
+		t.Errorf("Bookmarks = %+v, want Go in Bookmarks bar", imp.Bookmarks)
+	}
+	if want := []string{"Bookmarks bar"}; !reflect.DeepEqual(imp.Categories, want) {
+		t.Errorf("Categories = %v, want %v", imp.Categories, want)
+	}
+}
+
+func TestParseChrome_Invalid(t *testing.T) {
+	if _, err := ParseChrome(strings.NewReader("<html>")); err == nil {
+		t.Error("ParseChrome() of HTML should fail")
+	}
+}
@@ -0,0 +1,272 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/pkg/validator"
+)
+
+// CSVColumns are the bookmark fields a CSV file can hold, in the order
+// ubm export csv writes them and a file without a header is read
+var CSVColumns = []string{"title", "url", "category", "tags", "description", "keyword", "created_at", "updated_at", "id"}
+
+// csvAliases are other header names spreadsheets and other tools use
+var csvAliases = map[string]string{
+	"name":          "title",
+	"link":          "url",
+	"href":          "url",
+	"address":       "url",
+	"folder":        "category",
+	"path":          "category",
+	"tag":           "tags",
+	"labels":        "tags",
+	"notes":         "description",
+	"note":          "description",
+	"shortcut":      "keyword",
+	"created":       "created_at",
+	"added":         "created_at",
+	"date_added":    "created_at",
+	"updated":       "updated_at",
+	"modified":      "updated_at",
+	"last_modified": "updated_at",
+}
+
+// csvNamespace turns IDs that are not ubm IDs, such as row numbers, into
+// ones that stay the same every time the file is imported
+var csvNamespace = uuid.MustParse("c3a7e0d2-5b19-4f64-8d2e-71f4a9b6e053")
+
+// HeaderMode says whether the first row of a CSV file names its columns
+type HeaderMode int
+
+const (
+	// DetectHeader treats the first row as a header if one of its cells
+	// names the url column
+	DetectHeader HeaderMode = iota
+	WithHeader
+	WithoutHeader
+)
+
+// CSVOptions configures ParseCSV
+type CSVOptions struct {
+	// Comma is the delimiter, ',' if zero
+	Comma rune
+	// Columns names the field of each column, "" or "-" skipping one. When
+	// empty the header names the columns, or CSVColumns does if there is
+	// none.
+	Columns []string
+	Header  HeaderMode
+}
+
+// ParseCSV reads bookmarks from a CSV or TSV file, one per row. The tags
+// cell holds a comma-separated list, times are RFC 3339 or Unix seconds, and
+// categories are slash-separated paths.
+//
+// Rows that cannot be read, such as ones with an invalid URL, are reported
+// in Import.Invalid with their line number while the rest are imported. A
+// row with an ID updates the bookmark it was exported from, in every column
+// the file has but the times.
+func ParseCSV(r io.Reader, opts CSVOptions) (*Import, error) {
+	reader := csv.NewReader(r)
+	if opts.Comma != 0 {
+		reader.Comma = opts.Comma
+	}
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	// Trimming would also swallow empty cells between tabs
+	reader.TrimLeadingSpace = !unicode.IsSpace(reader.Comma)
+
+	var columns []string
+	if len(opts.Columns) > 0 {
+		var err error
+		if columns, err = csvFields(opts.Columns, true); err != nil {
+			return nil, err
+		}
+	}
+
+	imp := &Import{}
+	first := true
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			imp.Invalid = append(imp.Invalid, Skipped{Bookmark: &bookmark.Bookmark{}, Err: fmt.Errorf("line %d: %w", parseErr.Line, parseErr.Err)})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if first {
+			first = false
+			if isHeader(record, opts.Header) {
+				if columns == nil {
+					columns, _ = csvFields(record, false)
+				}
+				continue
+			}
+		}
+		if columns == nil {
+			columns = CSVColumns
+		}
+		if !hasColumn(columns, "url") {
+			return nil, fmt.Errorf("no url column")
+		}
+		if isBlank(record) {
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		b, err := csvBookmark(record, columns)
+		if err != nil {
+			imp.Invalid = append(imp.Invalid, Skipped{Bookmark: b, Err: fmt.Errorf("line %d: %w", line, err)})
+			continue
+		}
+		imp.addCategory(b.Category)
+		imp.Bookmarks = append(imp.Bookmarks, b)
+	}
+
+	for _, field := range []string{"tags", "description", "keyword"} {
+		if hasColumn(columns, field) {
+			imp.Fields = append(imp.Fields, field)
+		}
+	}
+	return imp, nil
+}
+
+// csvFields maps column names to fields. With strict, unknown names are an
+// error; otherwise those columns are skipped.
+func csvFields(names []string, strict bool) ([]string, error) {
+	fields := make([]string, len(names))
+	for i, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		name = strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+		if alias, ok := csvAliases[name]; ok {
+			name = alias
+		}
+		switch {
+		case name == "" || name == "_":
+			continue
+		case hasColumn(CSVColumns, name):
+			fields[i] = name
+		case strict:
+			return nil, fmt.Errorf("unknown column %q, use one of %s", names[i], strings.Join(CSVColumns, ", "))
+		}
+	}
+	return fields, nil
+}
+
+func isHeader(record []string, mode HeaderMode) bool {
+	switch mode {
+	case WithHeader:
+		return true
+	case WithoutHeader:
+		return false
+	}
+
+	fields, _ := csvFields(record, false)
+	return hasColumn(fields, "url")
+}
+
+func hasColumn(columns []string, name string) bool {
+	for _, c := range columns {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isBlank(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// csvBookmark reads a row. On error it returns what it read so far, for
+// the report.
+func csvBookmark(record, columns []string) (*bookmark.Bookmark, error) {
+	cells := map[string]string{}
+	for i, field := range columns {
+		if field != "" && i < len(record) {
+			cells[field] = strings.TrimSpace(record[i])
+		}
+	}
+
+	b := bookmark.New(cells["title"], cells["url"], cleanCategory(cells["category"]))
+	b.Tags = splitTags(cells["tags"])
+	b.Description = cells["description"]
+	b.Keyword = cells["keyword"]
+	if id := cells["id"]; id != "" {
+		if _, err := uuid.Parse(id); err == nil {
+			b.ID = id
+		} else {
+			b.ID = uuid.NewSHA1(csvNamespace, []byte(id)).String()
+		}
+	}
+
+	url, err := validator.NormalizeURL(b.URL)
+	if err != nil {
+		return b, err
+	}
+	b.URL = url
+	if b.Title == "" {
+		b.Title = url
+	}
+
+	if value := cells["created_at"]; value != "" {
+		created, err := parseCSVTime(value)
+		if err != nil {
+			return b, fmt.Errorf("invalid created_at: %w", err)
+		}
+		b.CreatedAt = created
+		b.UpdatedAt = created
+	}
+	if value := cells["updated_at"]; value != "" {
+		updated, err := parseCSVTime(value)
+		if err != nil {
+			return b, fmt.Errorf("invalid updated_at: %w", err)
+		}
+		b.UpdatedAt = updated
+	}
+	return b, nil
+}
+
+// csvTimeLayouts are the time formats spreadsheets write besides Unix time
+var csvTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+func parseCSVTime(value string) (time.Time, error) {
+	if t := parseTimestamp(value); !t.IsZero() {
+		return t, nil
+	}
+	for _, layout := range csvTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date", value)
+}
+
+// cleanCategory trims the parts of a slash-separated category path and
+// drops empty ones
+func cleanCategory(category string) string {
+	var parts []string
+	for _, part := range strings.Split(category, "/") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
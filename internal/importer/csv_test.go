@@ -0,0 +1,103 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCSV(t *testing.T) {
+	file := `Name,Link,Folder,Tags,Owner,Created
+Go,go.dev, dev / go ,"go, lang",alice,2023-11-14
+Broken,not a url,dev,,bob,
+Rust,https://rust-lang.org,dev,,carol,yesterday
+
+,,,,,
+,https://example.com,,,dave,1700000000
+`
+	imp, err := ParseCSV(strings.NewReader(file), CSVOptions{})
+	if err != nil {
+		t.Fatalf("ParseCSV() error = %v", err)
+	}
+
+	type entry struct {
+		Title, URL, Category string
+		Tags                 []string
+	}
+	var got []entry
+	for _, b := range imp.Bookmarks {
+		got = append(got, entry{b.Title, b.URL, b.Category, b.Tags})
+	}
+	want := []entry{
+		{"Go", "https://go.dev", "dev/go", []string{"go", "lang"}},
+		{"https://example.com", "https://example.com", "", []string{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseCSV() = %+v, want %+v", got, want)
+	}
+
+	if want := time.Date(2023, 11, 14, 0, 0, 0, 0, time.Local); !imp.Bookmarks[0].CreatedAt.Equal(want) {
+		t.Errorf("CreatedAt = %v, want %v", imp.Bookmarks[0].CreatedAt, want)
+	}
+	if want := time.Unix(1700000000, 0); !imp.Bookmarks[1].CreatedAt.Equal(want) {
+		t.Errorf("CreatedAt = %v, want %v", imp.Bookmarks[1].CreatedAt, want)
+	}
+
+	if len(imp.Invalid) != 2 {
+		t.Fatalf("Invalid = %+v, want the rows with a bad URL and a bad date", imp.Invalid)
+	}
+	for i, want := range []string{"line 3:", "line 4: invalid created_at"} {
+		if err := imp.Invalid[i].Err.Error(); !strings.HasPrefix(err, want) {
+			t.Errorf("Invalid[%d] = %s, want %s...", i, err, want)
+		}
+	}
+}
+
+func TestParseCSV_Options(t *testing.T) {
+	file := "https://go.dev\tignored\tGo\tdev\n" +
+		"https://rust-lang.org\tignored\tRust\tdev\n"
+
+	imp, err := ParseCSV(strings.NewReader(file), CSVOptions{
+		Comma:   '\t',
+		Columns: []string{"url", "-", "title", "category"},
+	})
+	if err != nil {
+		t.Fatalf("ParseCSV() error = %v", err)
+	}
+	if got := titles(imp.Bookmarks); !reflect.DeepEqual(got, []string{"Go", "Rust"}) {
+		t.Errorf("Titles = %v, want [Go Rust]", got)
+	}
+
+	// A first row that names no column is only skipped when told to
+	imp, err = ParseCSV(strings.NewReader("URL to read\nhttps://go.dev\n"), CSVOptions{Columns: []string{"url"}, Header: WithHeader})
+	if err != nil || len(imp.Bookmarks) != 1 || len(imp.Invalid) != 0 {
+		t.Errorf("ParseCSV(WithHeader) = %+v, %v, want one bookmark", imp, err)
+	}
+
+	if _, err := ParseCSV(strings.NewReader(file), CSVOptions{Columns: []string{"url", "owner"}}); err == nil {
+		t.Error("ParseCSV() with an unknown column should fail")
+	}
+	if _, err := ParseCSV(strings.NewReader("a,b\n"), CSVOptions{Columns: []string{"title"}}); err == nil {
+		t.Error("ParseCSV() without a url column should fail")
+	}
+}
+
+func TestParseCSV_ID(t *testing.T) {
+	file := "url,id\nhttps://go.dev,7\nhttps://rust-lang.org,0b9c3c1e-4d7f-4a8e-9b1a-2f3e4d5c6b7a\n"
+	read := func() *Import {
+		imp, err := ParseCSV(strings.NewReader(file), CSVOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return imp
+	}
+
+	first, second := read(), read()
+	if first.Bookmarks[0].ID != second.Bookmarks[0].ID {
+		t.Errorf("ID = %s, then %s; want the same one", first.Bookmarks[0].ID, second.Bookmarks[0].ID)
+	}
+	if id := first.Bookmarks[1].ID; id != "0b9c3c1e-4d7f-4a8e-9b1a-2f3e4d5c6b7a" {
+		t.Errorf("ID = %s, want the one in the file", id)
+	}
+}
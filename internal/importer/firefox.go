@@ -0,0 +1,243 @@
+package importer
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tom-023/ubm/internal/bookmark"
+)
+
+// FirefoxPlacesFile is the name of the database in a Firefox profile that
+// holds bookmarks and history
+const FirefoxPlacesFile = "places.sqlite"
+
+// firefoxNamespace turns Firefox bookmark GUIDs into ubm IDs, so a bookmark
+// gets the same ID every time it is imported
+var firefoxNamespace = uuid.MustParse("9e6f3a41-2c8d-4b7e-8f15-6a0d9c3b2e74")
+
+// moz_bookmarks.type
+const (
+	firefoxBookmark = 1
+	firefoxFolder   = 2
+)
+
+// firefoxRoots are the GUIDs of the folders Firefox always has, with the
+// names its UI shows for them, in the order they are imported. The tags
+// folder is read separately.
+var firefoxRoots = []struct {
+	guid, name string
+}{
+	{"toolbar_____", "Bookmarks Toolbar"},
+	{"menu________", "Bookmarks Menu"},
+	{"unfiled_____", "Other Bookmarks"},
+	{"mobile______", "Mobile Bookmarks"},
+}
+
+const firefoxTagsRoot = "tags________"
+
+type firefoxNode struct {
+	id, kind, parent int64
+	title, guid, url string
+	placeID          int64
+	added, modified  int64
+	children         []*firefoxNode
+}
+
+// ReadFirefox reads the bookmarks in a Firefox places.sqlite database.
+// Folders become categories below the toolbar, menu, other and mobile
+// bookmarks, Firefox tags become tags and keyword shortcuts keywords. Every
+// bookmark gets an ID derived from its GUID, so importing the database again
+// updates what was imported before.
+//
+// Firefox keeps the database locked and its latest changes in a write-ahead
+// log while it runs, so ReadFirefox reads a snapshot copy of both.
+//
+// It needs the SQLite driver, which is only built on the platforms listed in
+// sqlite.go.
+func ReadFirefox(path string) (*Import, error) {
+	if !slices.Contains(sql.Drivers(), "sqlite") {
+		return nil, fmt.Errorf("reading Firefox bookmarks is not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+
+	dir, err := os.MkdirTemp("", "ubm-firefox-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	snapshot := filepath.Join(dir, FirefoxPlacesFile)
+	if err := copyFile(path, snapshot); err != nil {
+		return nil, err
+	}
+	if err := copyFile(path+"-wal", snapshot+"-wal"); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite", snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer db.Close()
+
+	imp, err := parseFirefox(db)
+	if err != nil {
+		return nil, fmt.Errorf("not a Firefox places database: %w", err)
+	}
+	return imp, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	return out.Close()
+}
+
+func parseFirefox(db *sql.DB) (*Import, error) {
+	nodes, err := firefoxNodes(db)
+	if err != nil {
+		return nil, err
+	}
+	keywords, err := firefoxKeywords(db)
+	if err != nil {
+		return nil, err
+	}
+
+	byGUID := map[string]*firefoxNode{}
+	for _, node := range nodes {
+		byGUID[node.guid] = node
+	}
+
+	// Firefox tags a URL by putting a bookmark of it in a folder named after
+	// the tag inside the tags root
+	tags := map[int64][]string{}
+	if root := byGUID[firefoxTagsRoot]; root != nil {
+		for _, tag := range root.children {
+			for _, tagged := range tag.children {
+				tags[tagged.placeID] = append(tags[tagged.placeID], strings.TrimSpace(tag.title))
+			}
+		}
+	}
+
+	imp := &Import{}
+	for _, root := range firefoxRoots {
+		node := byGUID[root.guid]
+		if node == nil || len(node.children) == 0 {
+			continue
+		}
+		imp.addFirefoxFolder(node, root.name, tags, keywords)
+	}
+	return imp, nil
+}
+
+// firefoxNodes reads every bookmark, folder and separator, with the
+// children of every folder in their order
+func firefoxNodes(db *sql.DB) ([]*firefoxNode, error) {
+	rows, err := db.Query(`
+		SELECT b.id, b.type, IFNULL(b.parent, 0), IFNULL(b.title, ''), IFNULL(b.guid, ''),
+			IFNULL(p.url, ''), IFNULL(p.id, 0), IFNULL(b.dateAdded, 0), IFNULL(b.lastModified, 0)
+		FROM moz_bookmarks b LEFT JOIN moz_places p ON p.id = b.fk
+		ORDER BY b.parent, b.position`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var nodes []*firefoxNode
+	byID := map[int64]*firefoxNode{}
+	for rows.Next() {
+		n := &firefoxNode{}
+		if err := rows.Scan(&n.id, &n.kind, &n.parent, &n.title, &n.guid, &n.url, &n.placeID, &n.added, &n.modified); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+		byID[n.id] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, n := range nodes {
+		if parent := byID[n.parent]; parent != nil {
+			parent.children = append(parent.children, n)
+		}
+	}
+	return nodes, nil
+}
+
+// firefoxKeywords maps places to their keyword shortcut
+func firefoxKeywords(db *sql.DB) (map[int64]string, error) {
+	rows, err := db.Query(`SELECT place_id, keyword FROM moz_keywords`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keywords := map[int64]string{}
+	for rows.Next() {
+		var placeID int64
+		var keyword string
+		if err := rows.Scan(&placeID, &keyword); err != nil {
+			return nil, err
+		}
+		keywords[placeID] = keyword
+	}
+	return keywords, rows.Err()
+}
+
+func (imp *Import) addFirefoxFolder(folder *firefoxNode, category string, tags map[int64][]string, keywords map[int64]string) {
+	imp.addCategory(category)
+	for _, node := range folder.children {
+		switch node.kind {
+		case firefoxFolder:
+			// A folder without a name keeps its bookmarks in the parent
+			imp.addFirefoxFolder(node, categoryPath([]string{category, folderName(node.title)}), tags, keywords)
+		case firefoxBookmark:
+			b := newFirefoxBookmark(node, category)
+			b.Tags = splitTags(strings.Join(tags[node.placeID], ","))
+			b.Keyword = keywords[node.placeID]
+			imp.Bookmarks = append(imp.Bookmarks, b)
+		}
+	}
+}
+
+func newFirefoxBookmark(node *firefoxNode, category string) *bookmark.Bookmark {
+	url := strings.TrimSpace(node.url)
+	title := strings.TrimSpace(node.title)
+	if title == "" {
+		title = url
+	}
+
+	b := bookmark.New(title, url, category)
+	if node.guid != "" {
+		b.ID = uuid.NewSHA1(firefoxNamespace, []byte(node.guid)).String()
+	}
+	// Firefox counts microseconds since the Unix epoch
+	if node.added > 0 {
+		b.CreatedAt = time.UnixMicro(node.added)
+		b.UpdatedAt = b.CreatedAt
+	}
+	if node.modified > 0 {
+		b.UpdatedAt = time.UnixMicro(node.modified)
+	}
+	return b
+}
@@ -0,0 +1,140 @@
+//go:build (linux && (386 || amd64 || arm || arm64 || loong64 || ppc64le || riscv64 || s390x)) || (darwin && (amd64 || arm64)) || (windows && (386 || amd64 || arm64)) || (freebsd && (386 || amd64 || arm || arm64)) || (openbsd && (amd64 || arm64)) || (netbsd && amd64) || (illumos && amd64)
+
+package importer
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tom-023/ubm/internal/testutil"
+)
+
+// firefoxSchema is the part of the places.sqlite schema ReadFirefox uses
+const firefoxSchema = `
+CREATE TABLE moz_places (id INTEGER PRIMARY KEY, url LONGVARCHAR, title LONGVARCHAR, guid TEXT);
+CREATE TABLE moz_bookmarks (id INTEGER PRIMARY KEY, type INTEGER, fk INTEGER DEFAULT NULL,
+	parent INTEGER, position INTEGER, title LONGVARCHAR, keyword_id INTEGER, folder_type TEXT,
+	dateAdded INTEGER, lastModified INTEGER, guid TEXT);
+CREATE TABLE moz_keywords (id INTEGER PRIMARY KEY AUTOINCREMENT, keyword TEXT UNIQUE,
+	place_id INTEGER, post_data TEXT);
+
+INSERT INTO moz_places (id, url, title) VALUES
+	(1, 'https://go.dev/', 'Go'),
+	(2, 'https://pkg.go.dev/search?q=%s', 'Go packages'),
+	(3, 'https://example.com/', 'Example'),
+	(4, 'place:sort=8&maxResults=10', NULL);
+
+INSERT INTO moz_bookmarks (id, type, fk, parent, position, title, dateAdded, lastModified, guid) VALUES
+	(1, 2, NULL, 0, 0, '', 0, 0, 'root________'),
+	(2, 2, NULL, 1, 0, 'menu', 0, 0, 'menu________'),
+	(3, 2, NULL, 1, 1, 'toolbar', 0, 0, 'toolbar_____'),
+	(4, 2, NULL, 1, 2, 'tags', 0, 0, 'tags________'),
+	(5, 2, NULL, 1, 3, 'unfiled', 0, 0, 'unfiled_____'),
+	(6, 2, NULL, 1, 4, 'mobile', 0, 0, 'mobile______'),
+	(10, 1, 1, 3, 1, 'Go', 1700000000000000, 1700003600000000, 'bm-go-00001'),
+	(11, 2, NULL, 3, 0, 'Dev/Ops', 1700000000000000, 1700000000000000, 'fold-devops1'),
+	(12, 1, 2, 11, 0, 'Go packages', 1700000000000000, 1700000000000000, 'bm-pkg-0001'),
+	(13, 3, NULL, 11, 1, NULL, 1700000000000000, 1700000000000000, 'separator01'),
+	(16, 2, NULL, 11, 2, ' ', 1700000000000000, 1700000000000000, 'fold-blank01'),
+	(14, 1, 4, 2, 0, 'Most Visited', 1700000000000000, 1700000000000000, 'bm-query001'),
+	(15, 1, 3, 5, 0, NULL, 1700000000000000, 1700000000000000, 'bm-example1'),
+	(20, 2, NULL, 4, 0, 'go', 0, 0, 'tag-go00001'),
+	(21, 1, 1, 20, 0, NULL, 0, 0, 'tag-go-bm01'),
+	(22, 1, 2, 20, 1, NULL, 0, 0, 'tag-go-bm02'),
+	(23, 2, NULL, 4, 1, 'lang', 0, 0, 'tag-lang001'),
+	(24, 1, 1, 23, 0, NULL, 0, 0, 'tag-lang-b1');
+
+INSERT INTO moz_keywords (keyword, place_id) VALUES ('gopkg', 2);
+`
+
+func TestReadFirefox(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, FirefoxPlacesFile)
+
+	// Hold the database open like a running Firefox does: locked, with the
+	// bookmarks still in the write-ahead log
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	for _, pragma := range []string{"PRAGMA journal_mode = WAL", "PRAGMA wal_autocheckpoint = 0", "PRAGMA locking_mode = EXCLUSIVE"} {
+		if _, err := db.Exec(pragma); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec(firefoxSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	imp, err := ReadFirefox(path)
+	if err != nil {
+		t.Fatalf("ReadFirefox() error = %v", err)
+	}
+
+	type entry struct {
+		Title, URL, Category, Keyword string
+		Tags                          []string
+	}
+	var got []entry
+	for _, b := range imp.Bookmarks {
+		got = append(got, entry{b.Title, b.URL, b.Category, b.Keyword, b.Tags})
+	}
+	want := []entry{
+		{"Go packages", "https://pkg.go.dev/search?q=%s", "Bookmarks Toolbar/Dev-Ops", "gopkg", []string{"go"}},
+		{"Go", "https://go.dev/", "Bookmarks Toolbar", "", []string{"go", "lang"}},
+		{"Most Visited", "place:sort=8&maxResults=10", "Bookmarks Menu", "", []string{}},
+		{"https://example.com/", "https://example.com/", "Other Bookmarks", "", []string{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadFirefox() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	wantCategories := []string{"Bookmarks Toolbar", "Bookmarks Toolbar/Dev-Ops", "Bookmarks Menu", "Other Bookmarks"}
+	if !reflect.DeepEqual(imp.Categories, wantCategories) {
+		t.Errorf("Categories = %v, want %v", imp.Categories, wantCategories)
+	}
+
+	goBookmark := imp.Bookmarks[1]
+	if want := time.Unix(1700000000, 0); !goBookmark.CreatedAt.Equal(want) {
+		t.Errorf("CreatedAt = %v, want %v", goBookmark.CreatedAt, want)
+	}
+	if want := time.Unix(1700003600, 0); !goBookmark.UpdatedAt.Equal(want) {
+		t.Errorf("UpdatedAt = %v, want %v", goBookmark.UpdatedAt, want)
+	}
+
+	again, err := ReadFirefox(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Bookmarks[1].ID != goBookmark.ID {
+		t.Errorf("ID = %s, then %s; want the same one", goBookmark.ID, again.Bookmarks[1].ID)
+	}
+}
+
+func TestReadFirefox_NotPlaces(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	if _, err := ReadFirefox(filepath.Join(dir, "missing.sqlite")); err == nil {
+		t.Error("ReadFirefox() of a missing file should fail")
+	}
+
+	path := filepath.Join(dir, "other.sqlite")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("CREATE TABLE t (x)"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	if _, err := ReadFirefox(path); err == nil {
+		t.Error("ReadFirefox() of another database should fail")
+	}
+}
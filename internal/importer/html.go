@@ -0,0 +1,192 @@
+package importer
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tom-023/ubm/internal/bookmark"
+	"golang.org/x/net/html"
+)
+
+// ParseHTML reads a Netscape bookmark file, the format every browser
+// exports. Folders become slash-separated category paths, ADD_DATE and
+// LAST_MODIFIED the creation and update times, TAGS the tags, Firefox's
+// SHORTCUTURL the keyword, and the <DD> text after a link its description.
+func ParseHTML(r io.Reader) (*Import, error) {
+	z := html.NewTokenizer(r)
+	imp := &Import{}
+
+	var (
+		// folders has an entry for every open <DL>; the one without a
+		// heading, around the whole file, is empty
+		folders []string
+		// heading is the folder name whose <DL> comes next
+		heading string
+		// describing is the bookmark the current <DD> belongs to
+		describing  *bookmark.Bookmark
+		last        *bookmark.Bookmark
+		description strings.Builder
+	)
+
+	endDescription := func() {
+		if describing != nil {
+			describing.Description = strings.TrimSpace(description.String())
+			describing = nil
+		}
+		description.Reset()
+	}
+
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			endDescription()
+			if z.Err() == io.EOF {
+				return imp, nil
+			}
+			return nil, z.Err()
+
+		case html.TextToken:
+			if describing != nil {
+				description.Write(z.Text())
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			tag := string(name)
+			if tag == "br" && describing != nil {
+				description.WriteString("\n")
+				continue
+			}
+			if tag == "p" {
+				// Netscape files put a stray <p> after every <DL>
+				continue
+			}
+			endDescription()
+
+			switch tag {
+			case "h3":
+				heading = folderName(textUntil(z, "h3"))
+				last = nil
+			case "dl":
+				folders = append(folders, heading)
+				imp.addCategory(categoryPath(folders))
+				heading = ""
+				last = nil
+			case "a":
+				attrs := attributes(z, hasAttr)
+				last = newBookmark(textUntil(z, "a"), attrs, categoryPath(folders))
+				imp.Bookmarks = append(imp.Bookmarks, last)
+			case "dd":
+				describing = last
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			if string(name) == "dl" {
+				endDescription()
+				if len(folders) > 0 {
+					folders = folders[:len(folders)-1]
+				}
+				last = nil
+			}
+		}
+	}
+}
+
+// textUntil returns the text up to the end tag of tag
+func textUntil(z *html.Tokenizer, tag string) string {
+	var text strings.Builder
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return strings.TrimSpace(text.String())
+		case html.TextToken:
+			text.Write(z.Text())
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == tag {
+				return strings.TrimSpace(text.String())
+			}
+		}
+	}
+}
+
+// attributes returns the attributes of the current tag, with lowercase keys
+func attributes(z *html.Tokenizer, hasAttr bool) map[string]string {
+	attrs := map[string]string{}
+	for hasAttr {
+		var key, val []byte
+		key, val, hasAttr = z.TagAttr()
+		attrs[strings.ToLower(string(key))] = string(val)
+	}
+	return attrs
+}
+
+func newBookmark(title string, attrs map[string]string, category string) *bookmark.Bookmark {
+	url := strings.TrimSpace(attrs["href"])
+	if title == "" {
+		title = url
+	}
+
+	b := bookmark.New(title, url, category)
+	if created := parseTimestamp(attrs["add_date"]); !created.IsZero() {
+		b.CreatedAt = created
+		b.UpdatedAt = created
+	}
+	if modified := parseTimestamp(attrs["last_modified"]); !modified.IsZero() {
+		b.UpdatedAt = modified
+	}
+	b.Tags = splitTags(attrs["tags"])
+	b.Keyword = strings.TrimSpace(attrs["shortcuturl"])
+	return b
+}
+
+// parseTimestamp reads a Unix time as browsers write it: in seconds, though
+// some tools use milliseconds or microseconds
+func parseTimestamp(value string) time.Time {
+	n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || n <= 0 {
+		return time.Time{}
+	}
+	switch {
+	case n > 1e14:
+		return time.UnixMicro(n)
+	case n > 1e11:
+		return time.UnixMilli(n)
+	default:
+		return time.Unix(n, 0)
+	}
+}
+
+// splitTags reads a comma-separated tag list, dropping empty and repeated tags
+func splitTags(value string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, tag := range strings.Split(value, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// folderName turns a folder name into a category name, which cannot
+// contain '/'
+func folderName(name string) string {
+	return strings.TrimSpace(strings.ReplaceAll(name, "/", "-"))
+}
+
+func categoryPath(folders []string) string {
+	var parts []string
+	for _, f := range folders {
+		if f != "" {
+			parts = append(parts, f)
+		}
+	}
+	return strings.Join(parts, "/")
+}
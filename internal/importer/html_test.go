@@ -0,0 +1,85 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const netscapeFile = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<!-- This is an automatically generated file.
+     It will be read and overwritten.
+     DO NOT EDIT! -->
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><H3 ADD_DATE="1700000000" LAST_MODIFIED="1700000100" PERSONAL_TOOLBAR_FOLDER="true">Bookmarks bar</H3>
+    <DL><p>
+        <DT><A HREF="https://go.dev/" ADD_DATE="1700000000" LAST_MODIFIED="1700003600" TAGS="go,lang, go">Go &amp; friends</A>
+        <DD>The Go
+programming language
+        <DT><H3>CI/CD</H3>
+        <DL><p>
+            <DT><A HREF="https://github.com/features/actions" ADD_DATE="1700000000000">Actions</A>
+        </DL><p>
+        <DT><A HREF="javascript:alert(1)">Bookmarklet</A>
+        <DT><H3>Empty</H3>
+        <DL><p>
+        </DL><p>
+    </DL><p>
+    <DT><A HREF="https://example.com">Top level</A>
+    <DD>Described
+</DL><p>
+`
+
+func TestParseHTML(t *testing.T) {
+	imp, err := ParseHTML(strings.NewReader(netscapeFile))
+	if err != nil {
+		t.Fatalf("ParseHTML() error = %v", err)
+	}
+	bookmarks := imp.Bookmarks
+
+	type entry struct {
+		Title, URL, Category, Description string
+		Tags                              []string
+	}
+	var got []entry
+	for _, b := range bookmarks {
+		got = append(got, entry{b.Title, b.URL, b.Category, b.Description, b.Tags})
+	}
+	want := []entry{
+		{"Go & friends", "https://go.dev/", "Bookmarks bar", "The Go\nprogramming language", []string{"go", "lang"}},
+		{"Actions", "https://github.com/features/actions", "Bookmarks bar/CI-CD", "", []string{}},
+		{"Bookmarklet", "javascript:alert(1)", "Bookmarks bar", "", []string{}},
+		{"Top level", "https://example.com", "", "Described", []string{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseHTML() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if want := time.Unix(1700000000, 0); !bookmarks[0].CreatedAt.Equal(want) {
+		t.Errorf("CreatedAt = %v, want %v", bookmarks[0].CreatedAt, want)
+	}
+	if want := time.Unix(1700003600, 0); !bookmarks[0].UpdatedAt.Equal(want) {
+		t.Errorf("UpdatedAt = %v, want LAST_MODIFIED %v", bookmarks[0].UpdatedAt, want)
+	}
+	// Milliseconds, and no LAST_MODIFIED
+	if want := time.Unix(1700000000, 0); !bookmarks[1].CreatedAt.Equal(want) || !bookmarks[1].UpdatedAt.Equal(want) {
+		t.Errorf("Times = %v, %v, want both %v", bookmarks[1].CreatedAt, bookmarks[1].UpdatedAt, want)
+	}
+	if want := []string{"Bookmarks bar", "Bookmarks bar/CI-CD", "Bookmarks bar/Empty"}; !reflect.DeepEqual(imp.Categories, want) {
+		t.Errorf("Categories = %v, want %v", imp.Categories, want)
+	}
+	if bookmarks[0].ID == "" || bookmarks[0].ID == bookmarks[1].ID {
+		t.Error("Every bookmark needs its own ID")
+	}
+}
+
+func TestParseHTML_Empty(t *testing.T) {
+	imp, err := ParseHTML(strings.NewReader(""))
+	if err != nil || len(imp.Bookmarks) != 0 || len(imp.Categories) != 0 {
+		t.Errorf("ParseHTML(\"\") = %+v, %v, want nothing", imp, err)
+	}
+}
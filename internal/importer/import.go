@@ -0,0 +1,168 @@
+// Package importer reads bookmarks exported by browsers and other tools and
+// adds them to a library.
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/internal/storage"
+	"github.com/tom-023/ubm/pkg/validator"
+)
+
+// Import is what was read from an export
+type Import struct {
+	Bookmarks []*bookmark.Bookmark
+	// Categories holds every folder, including empty ones
+	Categories []string
+	// Invalid lists entries that could not be read, for the result
+	Invalid []Skipped
+	// Fields lists what a re-import updates besides the title, URL and
+	// category: those of "tags", "description" and "keyword" the source
+	// holds, such as the columns of a CSV file
+	Fields []string
+}
+
+// addCategory records a folder once
+func (imp *Import) addCategory(category string) {
+	if category == "" {
+		return
+	}
+	for _, c := range imp.Categories {
+		if c == category {
+			return
+		}
+	}
+	imp.Categories = append(imp.Categories, category)
+}
+
+// Skipped is a bookmark that was not imported, and why
+type Skipped struct {
+	Bookmark *bookmark.Bookmark
+	Err      error
+}
+
+// Result describes what Apply did
+type Result struct {
+	Added []*bookmark.Bookmark
+	// Updated were imported before and have changed since
+	Updated []*bookmark.Bookmark
+	// Unchanged were imported before and are still the same
+	Unchanged []*bookmark.Bookmark
+	// Trashed were imported before and have since been deleted in ubm, so
+	// they stay in the trash
+	Trashed []*bookmark.Bookmark
+	// Duplicates have the same URL as a bookmark already in their category
+	Duplicates []*bookmark.Bookmark
+	// Invalid lists bookmarks whose URL ubm cannot use, such as bookmarklets
+	Invalid []Skipped
+}
+
+// Apply adds the bookmarks and categories of imp to data, normalizing URLs
+// like ubm add does. A bookmark whose URL is already in its category is
+// skipped as a duplicate, as is one that appears twice in the import.
+//
+// Importers that give a bookmark the same ID every time it is read make
+// imports repeatable: a bookmark whose ID is already in the library has its
+// title, URL, category and imp.Fields updated instead, keeping the rest.
+func Apply(data *storage.Data, imp *Import) (*Result, error) {
+	for _, c := range imp.Categories {
+		data.AddCategory(c)
+	}
+
+	result := &Result{Invalid: append([]Skipped{}, imp.Invalid...)}
+	for _, b := range imp.Bookmarks {
+		url, err := normalizeURL(b.URL)
+		if err != nil {
+			result.Invalid = append(result.Invalid, Skipped{Bookmark: b, Err: err})
+			continue
+		}
+		b.URL = url
+
+		if existing := data.FindBookmark(b.ID); existing != nil {
+			fields := append([]string{"title", "URL", "category"}, imp.Fields...)
+			switch {
+			case unchanged(existing, b, fields):
+				result.Unchanged = append(result.Unchanged, existing)
+			case hasDuplicate(data, b):
+				result.Duplicates = append(result.Duplicates, b)
+			default:
+				update(existing, b, fields)
+				data.AddCategory(b.Category)
+				result.Updated = append(result.Updated, existing)
+			}
+			continue
+		}
+		if data.FindTrashed(b.ID) != nil {
+			result.Trashed = append(result.Trashed, b)
+			continue
+		}
+
+		err = data.AddBookmark(b)
+		switch {
+		case errors.Is(err, storage.ErrDuplicate):
+			result.Duplicates = append(result.Duplicates, b)
+		case err != nil:
+			return nil, err
+		default:
+			result.Added = append(result.Added, b)
+		}
+	}
+	return result, nil
+}
+
+// unchanged reports whether an import still has what the library has for a
+// bookmark in the given fields
+func unchanged(existing, imported *bookmark.Bookmark, fields []string) bool {
+	for _, f := range fields {
+		if storage.FieldValue(existing, f) != storage.FieldValue(imported, f) {
+			return false
+		}
+	}
+	return true
+}
+
+// update copies the given fields of an imported bookmark into the library's.
+// Its times are left alone but for UpdatedAt, which is now.
+func update(existing, imported *bookmark.Bookmark, fields []string) {
+	for _, f := range fields {
+		switch f {
+		case "title":
+			existing.Title = imported.Title
+		case "URL":
+			existing.URL = imported.URL
+		case "category":
+			existing.Category = imported.Category
+		case "tags":
+			existing.Tags = append([]string{}, imported.Tags...)
+		case "description":
+			existing.Description = imported.Description
+		case "keyword":
+			existing.Keyword = imported.Keyword
+		}
+	}
+	existing.Update()
+}
+
+// hasDuplicate reports whether another bookmark has the URL of b in its
+// category
+func hasDuplicate(data *storage.Data, b *bookmark.Bookmark) bool {
+	for _, other := range data.Bookmarks {
+		if other.ID != b.ID && other.URL == b.URL && other.Category == b.Category {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeURL validates a URL from an export. Unlike typed input it is
+// never missing its scheme; without "://" it is a bookmarklet or a browser
+// page such as about: or place:, which cannot be opened from ubm.
+func normalizeURL(url string) (string, error) {
+	if !strings.Contains(url, "://") {
+		return "", fmt.Errorf("unsupported URL %q", url)
+	}
+	return validator.NormalizeURL(url)
+}
@@ -0,0 +1,103 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/internal/storage"
+	"github.com/tom-023/ubm/internal/testutil"
+)
+
+func TestApply(t *testing.T) {
+	data := &storage.Data{}
+	if err := data.AddBookmark(testutil.CreateTestBookmark("Go", "https://go.dev", "dev")); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Apply(data, &Import{Categories: []string{"empty"}, Bookmarks: []*bookmark.Bookmark{
+		bookmark.New("Go again", "https://go.dev", "dev"),
+		bookmark.New("Go elsewhere", "https://go.dev", "lang"),
+		bookmark.New("Rust", "  https://rust-lang.org  ", "lang"),
+		bookmark.New("Rust twice", "https://rust-lang.org", "lang"),
+		bookmark.New("Bookmarklet", "javascript:void(0)", "lang"),
+		bookmark.New("Settings", "chrome://settings", ""),
+	}})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	if got := titles(result.Added); len(got) != 2 || got[0] != "Go elsewhere" || got[1] != "Rust" {
+		t.Errorf("Added = %v, want [Go elsewhere Rust]", got)
+	}
+	if got := titles(result.Duplicates); len(got) != 2 || got[0] != "Go again" || got[1] != "Rust twice" {
+		t.Errorf("Duplicates = %v, want [Go again Rust twice]", got)
+	}
+	if len(result.Invalid) != 2 {
+		t.Errorf("Invalid = %+v, want the bookmarklet and the browser page", result.Invalid)
+	}
+
+	if len(data.Bookmarks) != 3 {
+		t.Errorf("Library has %d bookmarks, want 3", len(data.Bookmarks))
+	}
+	if b := result.Added[1]; b.URL != "https://rust-lang.org" {
+		t.Errorf("URL = %q, want it normalized", b.URL)
+	}
+	if !data.HasCategory("lang") || !data.HasCategory("empty") {
+		t.Errorf("Categories = %v, want those of the bookmarks and the empty folder", data.Categories)
+	}
+}
+
+func TestApply_Again(t *testing.T) {
+	data := &storage.Data{}
+	read := func() *Import {
+		imp, err := ParseChrome(strings.NewReader(chromeBookmarks))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return imp
+	}
+	if _, err := Apply(data, read()); err != nil {
+		t.Fatal(err)
+	}
+	data.Bookmarks[0].Tags = []string{"go"}
+	if err := data.TrashBookmark(data.Bookmarks[2].ID, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Go was renamed and moved in the browser
+	imp := read()
+	imp.Bookmarks[0].Title = "The Go language"
+	imp.Bookmarks[0].Category = "Bookmarks bar/CI-CD"
+	result, err := Apply(data, imp)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	if len(result.Added) != 0 || len(result.Duplicates) != 0 {
+		t.Errorf("Apply() = %+v, want nothing added again", result)
+	}
+	if got := titles(result.Updated); len(got) != 1 || got[0] != "The Go language" {
+		t.Errorf("Updated = %v, want [The Go language]", got)
+	}
+	if got := titles(result.Unchanged); len(got) != 1 || got[0] != "Actions" {
+		t.Errorf("Unchanged = %v, want [Actions]", got)
+	}
+	if len(result.Trashed) != 1 || len(data.Bookmarks) != 2 {
+		t.Errorf("Trashed = %v, want the trashed bookmark left in the trash", titles(result.Trashed))
+	}
+
+	b := data.Bookmarks[0]
+	if b.Category != "Bookmarks bar/CI-CD" || len(b.Tags) != 1 {
+		t.Errorf("Updated bookmark = %+v, want it moved with its tags kept", b)
+	}
+}
+
+func titles(bookmarks []*bookmark.Bookmark) []string {
+	var result []string
+	for _, b := range bookmarks {
+		result = append(result, b.Title)
+	}
+	return result
+}
@@ -0,0 +1,7 @@
+//go:build (linux && (386 || amd64 || arm || arm64 || loong64 || ppc64le || riscv64 || s390x)) || (darwin && (amd64 || arm64)) || (windows && (386 || amd64 || arm64)) || (freebsd && (386 || amd64 || arm || arm64)) || (openbsd && (amd64 || arm64)) || (netbsd && amd64) || (illumos && amd64)
+
+package importer
+
+// Registers the pure-Go "sqlite" driver ReadFirefox uses, on the platforms
+// it supports
+import _ "modernc.org/sqlite"
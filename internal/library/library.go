@@ -0,0 +1,160 @@
+// Package library manages named bookmark libraries. Each library is a
+// directory with its own storage file, backups, lock and journal. The default
+// library lives directly in the data directory; the others live under
+// libraries/<name>.
+package library
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/tom-023/ubm/internal/config"
+	"github.com/tom-023/ubm/internal/lockfile"
+)
+
+// Default is the library used when none is chosen
+const Default = config.DefaultLibrary
+
+const librariesDir = "libraries"
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// Manager creates, lists and removes the libraries in a data directory
+type Manager struct {
+	dataDir string
+}
+
+func NewManager(dataDir string) *Manager {
+	return &Manager{dataDir: dataDir}
+}
+
+// ValidateName checks that name can be used as a directory name everywhere
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid library name %q: use letters, digits, '.', '_' or '-', starting with a letter or digit", name)
+	}
+	return nil
+}
+
+// Dir returns the directory that holds the library
+func (m *Manager) Dir(name string) string {
+	if name == Default {
+		return m.dataDir
+	}
+	return filepath.Join(m.dataDir, librariesDir, name)
+}
+
+// Exists reports whether the library has been created. The default library
+// always exists.
+func (m *Manager) Exists(name string) bool {
+	if name == Default {
+		return true
+	}
+	if ValidateName(name) != nil {
+		return false
+	}
+	info, err := os.Stat(m.Dir(name))
+	return err == nil && info.IsDir()
+}
+
+// List returns the names of all libraries, the default one first
+func (m *Manager) List() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(m.dataDir, librariesDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read libraries: %w", err)
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() && ValidateName(entry.Name()) == nil && entry.Name() != Default {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	return append([]string{Default}, names...), nil
+}
+
+// Create makes a new, empty library
+func (m *Manager) Create(name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if m.Exists(name) {
+		return fmt.Errorf("library %q already exists", name)
+	}
+
+	if err := os.MkdirAll(m.Dir(name), 0755); err != nil {
+		return fmt.Errorf("failed to create library: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a library with all its bookmarks, backups and history. It
+// refuses active, the library in use, and waits for other processes to
+// finish saving to the library being deleted.
+func (m *Manager) Delete(name, active string) error {
+	if name == Default {
+		return fmt.Errorf("the default library cannot be deleted")
+	}
+	if !m.Exists(name) {
+		return fmt.Errorf("library %q does not exist", name)
+	}
+	if name == active {
+		return fmt.Errorf("library %q is in use; switch to another library first", name)
+	}
+
+	dir := m.Dir(name)
+	unlock, err := lockfile.LockDir(dir)
+	if err != nil {
+		return err
+	}
+
+	// Everything but the lock file goes while the lock is held. The lock
+	// file follows once released, as Windows cannot remove an open file.
+	entries, err := os.ReadDir(dir)
+	if err == nil {
+		for _, entry := range entries {
+			if entry.Name() == lockfile.FileName {
+				continue
+			}
+			if err = os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+				break
+			}
+		}
+	}
+	if unlockErr := unlock(); err == nil {
+		err = unlockErr
+	}
+	if err == nil {
+		err = os.RemoveAll(dir)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete library: %w", err)
+	}
+	return nil
+}
+
+// Rename gives a library a new name
+func (m *Manager) Rename(from, to string) error {
+	if from == Default || to == Default {
+		return fmt.Errorf("the default library cannot be renamed")
+	}
+	if !m.Exists(from) {
+		return fmt.Errorf("library %q does not exist", from)
+	}
+	if err := ValidateName(to); err != nil {
+		return err
+	}
+	if m.Exists(to) {
+		return fmt.Errorf("library %q already exists", to)
+	}
+
+	if err := os.Rename(m.Dir(from), m.Dir(to)); err != nil {
+		return fmt.Errorf("failed to rename library: %w", err)
+	}
+	return nil
+}
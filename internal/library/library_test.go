@@ -0,0 +1,133 @@
+package library
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/internal/storage"
+	"github.com/tom-023/ubm/internal/testutil"
+)
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"work", "Personal", "team-2", "a.b_c"} {
+		if err := ValidateName(name); err != nil {
+			t.Errorf("ValidateName(%q) = %v, want nil", name, err)
+		}
+	}
+	for _, name := range []string{"", ".hidden", "-flag", "a/b", "..", `a\b`, "with space"} {
+		if err := ValidateName(name); err == nil {
+			t.Errorf("ValidateName(%q) = nil, want an error", name)
+		}
+	}
+}
+
+func TestManager(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	m := NewManager(dir)
+
+	if m.Dir(Default) != dir {
+		t.Errorf("Dir(%q) = %q, want the config directory", Default, m.Dir(Default))
+	}
+	if !m.Exists(Default) {
+		t.Error("The default library should always exist")
+	}
+	if m.Exists("../outside") {
+		t.Error("Exists() must reject names outside the libraries directory")
+	}
+
+	for _, name := range []string{"work", "home"} {
+		if err := m.Create(name); err != nil {
+			t.Fatalf("Create(%q) failed: %v", name, err)
+		}
+	}
+	if err := m.Create("work"); err == nil {
+		t.Error("Create() should fail for an existing library")
+	}
+	if err := m.Create(Default); err == nil {
+		t.Error("Create() should fail for the default library")
+	}
+
+	names, err := m.List()
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if want := []string{Default, "home", "work"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("List() = %v, want %v", names, want)
+	}
+
+	if err := m.Rename("home", "personal"); err != nil {
+		t.Fatalf("Rename() failed: %v", err)
+	}
+	if m.Exists("home") || !m.Exists("personal") {
+		t.Error("Rename() did not move the library")
+	}
+	if err := m.Rename("personal", "work"); err == nil {
+		t.Error("Rename() should not overwrite an existing library")
+	}
+	if err := m.Rename(Default, "other"); err == nil {
+		t.Error("Rename() should refuse the default library")
+	}
+
+	if err := m.Delete("personal", "personal"); err == nil {
+		t.Error("Delete() should refuse the library in use")
+	}
+	if err := m.Delete("personal", Default); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if m.Exists("personal") {
+		t.Error("Delete() left the library in place")
+	}
+	if err := m.Delete(Default, "work"); err == nil {
+		t.Error("Delete() should refuse the default library")
+	}
+	if err := m.Delete("missing", Default); err == nil {
+		t.Error("Delete() should fail for a missing library")
+	}
+}
+
+func TestLibrariesAreSeparate(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	m := NewManager(dir)
+	if err := m.Create("work"); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	def, err := storage.New(m.Dir(Default))
+	if err != nil {
+		t.Fatal(err)
+	}
+	work, err := storage.New(m.Dir("work"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = work.Update(func(data *storage.Data) error {
+		return data.AddBookmark(bookmark.New("Tracker", "https://tracker.example.com", "jobs/tools"))
+	})
+	if err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+
+	data, err := def.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Bookmarks) != 0 || len(data.Categories) != 0 {
+		t.Errorf("The default library sees %d bookmarks and %v categories from another library", len(data.Bookmarks), data.Categories)
+	}
+
+	// The default library's files must not end up inside another library
+	if _, err := os.Stat(filepath.Join(m.Dir("work"), "bookmarks.json")); err != nil {
+		t.Errorf("work library file missing: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "bookmarks.json")); !os.IsNotExist(err) {
+		t.Errorf("Saving the work library touched the default library: %v", err)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !unix && !windows
+
+package lockfile
+
+import "os"
+
+// Platforms without advisory locking fall back to the in-process mutex only.
+func tryLockFile(file *os.File, exclusive bool) error {
+	return nil
+}
+
+func unlockFile(file *os.File) error {
+	return nil
+}
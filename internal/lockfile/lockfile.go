@@ -0,0 +1,128 @@
+// Package lockfile guards a library directory across processes with an
+// advisory lock on a dedicated file. Exclusive holders write their PID into
+// it so that others can say who they are waiting for.
+package lockfile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileName is the file in a library directory that processes lock
+const FileName = "bookmarks.lock"
+
+const (
+	// DefaultTimeout is how long to wait for another process by default
+	DefaultTimeout = 5 * time.Second
+	retryInterval  = 20 * time.Millisecond
+)
+
+// ErrLocked is matched by errors.Is when the library lock could not be acquired
+var ErrLocked = errors.New("library is locked")
+
+// errWouldBlock is returned by tryLockFile when another holder owns the lock
+var errWouldBlock = errors.New("lock is held by another process")
+
+// LockedError is returned when the library lock is still held after the timeout
+type LockedError struct {
+	Path string
+	PID  int
+}
+
+func (e *LockedError) Error() string {
+	if e.PID > 0 {
+		return fmt.Sprintf("library is locked by PID %d (lock file: %s)", e.PID, e.Path)
+	}
+	return fmt.Sprintf("library is locked by another process (lock file: %s)", e.Path)
+}
+
+func (e *LockedError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+// Lock is an advisory OS-level lock on a dedicated lock file.
+// Exclusive holders record their PID in the file so that waiters can report it.
+type Lock struct {
+	file      *os.File
+	exclusive bool
+}
+
+// Acquire locks the file at path, shared or exclusively, creating it if
+// needed. It returns a *LockedError if the lock is still held by someone
+// else after timeout.
+func Acquire(path string, exclusive bool, timeout time.Duration) (*Lock, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := tryLockFile(file, exclusive)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errWouldBlock) {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if time.Now().After(deadline) {
+			pid := readLockPID(file)
+			file.Close()
+			return nil, &LockedError{Path: path, PID: pid}
+		}
+		time.Sleep(retryInterval)
+	}
+
+	if exclusive {
+		// Record the owner; failing to do so only degrades the error message
+		if err := file.Truncate(0); err == nil {
+			file.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+		}
+	}
+
+	return &Lock{file: file, exclusive: exclusive}, nil
+}
+
+// Release unlocks and closes the lock file
+func (l *Lock) Release() error {
+	if l.exclusive {
+		l.file.Truncate(0)
+	}
+	unlockErr := unlockFile(l.file)
+	closeErr := l.file.Close()
+	if unlockErr != nil {
+		return fmt.Errorf("failed to unlock: %w", unlockErr)
+	}
+	return closeErr
+}
+
+func readLockPID(file *os.File) int {
+	buf := make([]byte, 32)
+	n, err := file.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(buf[:n])))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+// LockDir takes the lock a save holds on the library in dir, waiting as
+// long as a save would, for work such as moving its files. It returns the
+// function that releases it.
+func LockDir(dir string) (func() error, error) {
+	lock, err := Acquire(filepath.Join(dir, FileName), true, DefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return lock.Release, nil
+}
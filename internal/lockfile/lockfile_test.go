@@ -0,0 +1,52 @@
+package lockfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tom-023/ubm/internal/testutil"
+)
+
+func TestAcquire(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, FileName)
+
+	// Readers share the lock
+	first, err := Acquire(path, false, time.Second)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	second, err := Acquire(path, false, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Second shared Acquire() error = %v", err)
+	}
+	if _, err := Acquire(path, true, 100*time.Millisecond); !errors.Is(err, ErrLocked) {
+		t.Errorf("Exclusive Acquire() while shared error = %v, want ErrLocked", err)
+	}
+	first.Release()
+	second.Release()
+
+	// A writer keeps everyone else out and says who it is
+	unlock, err := LockDir(dir)
+	if err != nil {
+		t.Fatalf("LockDir() error = %v", err)
+	}
+	_, err = Acquire(path, false, 100*time.Millisecond)
+	var locked *LockedError
+	if !errors.As(err, &locked) || locked.PID != os.Getpid() {
+		t.Errorf("Acquire() while locked error = %v, want a *LockedError with PID %d", err, os.Getpid())
+	}
+	if err := unlock(); err != nil {
+		t.Fatalf("unlock() error = %v", err)
+	}
+
+	lock, err := Acquire(path, true, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Acquire() after release error = %v", err)
+	}
+	lock.Release()
+}
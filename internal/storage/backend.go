@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tom-023/ubm/internal/bookmark"
+)
+
+// Backend is the persistence interface used by the commands. Storage,
+// BoltStorage and MemoryStorage implement it; backendConformance in the
+// tests pins down the behaviour every implementation must share.
+type Backend interface {
+	Load() (*Data, error)
+	Save(data *Data) error
+	Update(fn func(*Data) error) error
+	AddBookmark(b *bookmark.Bookmark) error
+	UpdateBookmark(b *bookmark.Bookmark) error
+	DeleteBookmark(id string) error
+	GetBookmark(id string) (*bookmark.Bookmark, error)
+	GetBookmarksByCategory(category string) ([]*bookmark.Bookmark, error)
+	SearchBookmarks(query string) ([]*bookmark.Bookmark, error)
+}
+
+// BookmarkEditor is implemented by backends that can change a single
+// bookmark through an index, without reading and writing the whole library
+type BookmarkEditor interface {
+	EditBookmark(id string, fn func(*bookmark.Bookmark) error) error
+	DeleteBookmarkIf(id string, check func(*bookmark.Bookmark) error) error
+}
+
+var (
+	_ Backend = (*Storage)(nil)
+	_ Backend = (*MemoryStorage)(nil)
+	_ Backend = (*BoltStorage)(nil)
+
+	_ BookmarkEditor = (*BoltStorage)(nil)
+)
+
+// Names of the persistent backends as used in config.yaml
+const (
+	BackendFile = "file"
+	BackendBolt = "bolt"
+)
+
+// ParseBackend validates a backend name as used in config.yaml and on the command line
+func ParseBackend(name string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", BackendFile:
+		return BackendFile, nil
+	case BackendBolt:
+		return BackendBolt, nil
+	default:
+		return "", fmt.Errorf("unsupported storage backend %q (expected file or bolt)", name)
+	}
+}
+
+// Copy replaces the content of dst with the content of src. It is used to
+// move a library between backends.
+func Copy(dst, src Backend) error {
+	data, err := src.Load()
+	if err != nil {
+		return fmt.Errorf("failed to read source library: %w", err)
+	}
+
+	// The revision belongs to src; dst is replaced as a whole
+	data.UpdatedAt = time.Time{}
+	if err := dst.Save(data); err != nil {
+		return fmt.Errorf("failed to write target library: %w", err)
+	}
+	return nil
+}
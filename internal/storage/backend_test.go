@@ -0,0 +1,388 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/internal/testutil"
+)
+
+// backendConformance runs the behaviour every Backend implementation must
+// share. newBackend must return an empty backend for each call.
+func backendConformance(t *testing.T, newBackend func(t *testing.T) Backend) {
+	t.Run("LoadEmpty", func(t *testing.T) {
+		b := newBackend(t)
+
+		data, err := b.Load()
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if data.Bookmarks == nil || len(data.Bookmarks) != 0 {
+			t.Errorf("Expected empty non-nil bookmarks, got %v", data.Bookmarks)
+		}
+		if data.Categories == nil || len(data.Categories) != 0 {
+			t.Errorf("Expected empty non-nil categories, got %v", data.Categories)
+		}
+		if data.SchemaVersion != CurrentSchemaVersion {
+			t.Errorf("SchemaVersion = %d, want %d", data.SchemaVersion, CurrentSchemaVersion)
+		}
+	})
+
+	t.Run("SaveAndLoad", func(t *testing.T) {
+		b := newBackend(t)
+
+		bookmarks := testutil.CreateTestBookmarks()
+		data := &Data{
+			Bookmarks:  bookmarks,
+			Categories: testutil.SampleCategories(),
+		}
+		if err := b.Save(data); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+		if data.UpdatedAt.IsZero() {
+			t.Error("Save() should stamp UpdatedAt")
+		}
+
+		loaded, err := b.Load()
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if len(loaded.Bookmarks) != len(bookmarks) {
+			t.Fatalf("Loaded %d bookmarks, want %d", len(loaded.Bookmarks), len(bookmarks))
+		}
+		for i, got := range loaded.Bookmarks {
+			want := bookmarks[i]
+			if got.ID != want.ID || got.Title != want.Title || got.URL != want.URL || got.Category != want.Category {
+				t.Errorf("Bookmark[%d] = %+v, want %+v", i, got, want)
+			}
+			if !got.CreatedAt.Equal(want.CreatedAt) {
+				t.Errorf("Bookmark[%d].CreatedAt = %v, want %v", i, got.CreatedAt, want.CreatedAt)
+			}
+		}
+		if !reflect.DeepEqual(loaded.Categories, data.Categories) {
+			t.Errorf("Categories = %v, want %v", loaded.Categories, data.Categories)
+		}
+	})
+
+	t.Run("LoadIsIsolated", func(t *testing.T) {
+		b := newBackend(t)
+
+		if err := b.AddBookmark(testutil.CreateTestBookmark("Test", "https://test.com", "test")); err != nil {
+			t.Fatalf("AddBookmark() error = %v", err)
+		}
+
+		// Changes to loaded data must not leak in without a save
+		data, _ := b.Load()
+		data.Bookmarks[0].Title = "Changed"
+		data.Bookmarks = nil
+
+		reloaded, _ := b.Load()
+		if len(reloaded.Bookmarks) != 1 || reloaded.Bookmarks[0].Title != "Test" {
+			t.Errorf("Unsaved changes leaked into the backend: %+v", reloaded.Bookmarks)
+		}
+	})
+
+	t.Run("AddBookmark", func(t *testing.T) {
+		b := newBackend(t)
+
+		if err := b.AddBookmark(testutil.CreateTestBookmark("Test1", "https://test1.com", "category1")); err != nil {
+			t.Fatalf("AddBookmark() error = %v", err)
+		}
+		if err := b.AddBookmark(testutil.CreateTestBookmark("Copy", "https://test1.com", "category1")); !errors.Is(err, ErrDuplicate) {
+			t.Errorf("AddBookmark() of a duplicate URL in the same category error = %v, want ErrDuplicate", err)
+		}
+		if err := b.AddBookmark(testutil.CreateTestBookmark("Other", "https://test1.com", "category2")); err != nil {
+			t.Errorf("AddBookmark() in different category error = %v", err)
+		}
+		if err := b.AddBookmark(testutil.CreateTestBookmark("Uncategorized", "https://uncat.com", "")); err != nil {
+			t.Errorf("AddBookmark() uncategorized error = %v", err)
+		}
+
+		data, _ := b.Load()
+		if len(data.Bookmarks) != 3 {
+			t.Errorf("Expected 3 bookmarks, got %d", len(data.Bookmarks))
+		}
+		if !reflect.DeepEqual(data.Categories, []string{"category1", "category2"}) {
+			t.Errorf("Categories = %v, want [category1 category2]", data.Categories)
+		}
+	})
+
+	t.Run("UpdateBookmark", func(t *testing.T) {
+		b := newBackend(t)
+
+		original := testutil.CreateTestBookmark("Original", "https://original.com", "test")
+		b.AddBookmark(original)
+
+		updated := *original
+		updated.Title = "Updated"
+		if err := b.UpdateBookmark(&updated); err != nil {
+			t.Fatalf("UpdateBookmark() error = %v", err)
+		}
+
+		got, err := b.GetBookmark(original.ID)
+		if err != nil {
+			t.Fatalf("GetBookmark() error = %v", err)
+		}
+		if got.Title != "Updated" {
+			t.Errorf("Title = %v, want Updated", got.Title)
+		}
+
+		missing := testutil.CreateTestBookmark("Missing", "https://missing.com", "test")
+		if err := b.UpdateBookmark(missing); err == nil {
+			t.Error("Expected error when updating a missing bookmark")
+		}
+	})
+
+	t.Run("DeleteBookmark", func(t *testing.T) {
+		b := newBackend(t)
+
+		b1 := testutil.CreateTestBookmark("Test1", "https://test1.com", "test")
+		b2 := testutil.CreateTestBookmark("Test2", "https://test2.com", "test")
+		b.AddBookmark(b1)
+		b.AddBookmark(b2)
+
+		if err := b.DeleteBookmark(b1.ID); err != nil {
+			t.Fatalf("DeleteBookmark() error = %v", err)
+		}
+		if _, err := b.GetBookmark(b1.ID); err == nil {
+			t.Error("Deleted bookmark is still returned")
+		}
+		if _, err := b.GetBookmark(b2.ID); err != nil {
+			t.Errorf("Wrong bookmark was deleted: %v", err)
+		}
+		if err := b.DeleteBookmark("non-existent-id"); err == nil {
+			t.Error("Expected error when deleting a missing bookmark")
+		}
+	})
+
+	t.Run("DeleteMovesToTrash", func(t *testing.T) {
+		b := newBackend(t)
+
+		bm := testutil.CreateTestBookmark("Test", "https://test.com", "test")
+		b.AddBookmark(bm)
+		if err := b.DeleteBookmark(bm.ID); err != nil {
+			t.Fatalf("DeleteBookmark() error = %v", err)
+		}
+
+		data, _ := b.Load()
+		trashed := data.FindTrashed(bm.ID)
+		if trashed == nil {
+			t.Fatal("Deleted bookmark should be in the trash")
+		}
+		if trashed.Bookmark.Category != "test" || trashed.DeletedAt.IsZero() {
+			t.Errorf("Trashed bookmark = %+v, want original category and deletion time", trashed)
+		}
+		if results, _ := b.SearchBookmarks("Test"); len(results) != 0 {
+			t.Error("SearchBookmarks() should not return trashed bookmarks")
+		}
+
+		err := b.Update(func(data *Data) error {
+			_, err := data.RestoreBookmark(bm.ID)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("RestoreBookmark() error = %v", err)
+		}
+		if _, err := b.GetBookmark(bm.ID); err != nil {
+			t.Errorf("Restored bookmark not found: %v", err)
+		}
+		if data, _ := b.Load(); len(data.Trash) != 0 {
+			t.Errorf("Trash should be empty after restore, has %d", len(data.Trash))
+		}
+	})
+
+	t.Run("SaveConflict", func(t *testing.T) {
+		b := newBackend(t)
+
+		b.AddBookmark(testutil.CreateTestBookmark("Test", "https://test.com", "test"))
+		stale, _ := b.Load()
+
+		// Someone else saves after stale was loaded
+		b.AddBookmark(testutil.CreateTestBookmark("Other", "https://other.com", "test"))
+
+		stale.Bookmarks[0].Title = "Changed"
+		err := b.Save(stale)
+		if !errors.Is(err, ErrConflict) {
+			t.Fatalf("Save() of a stale revision error = %v, want ErrConflict", err)
+		}
+		var conflict *ConflictError
+		if !errors.As(err, &conflict) || len(conflict.Current.Bookmarks) != 2 {
+			t.Errorf("ConflictError should carry the current library, got %+v", err)
+		}
+		if data, _ := b.Load(); len(data.Bookmarks) != 2 || data.Bookmarks[0].Title != "Test" {
+			t.Error("Rejected Save() must not change the library")
+		}
+
+		// A fresh load, or data not loaded from the library at all, saves fine
+		fresh, _ := b.Load()
+		fresh.Bookmarks[0].Title = "Changed"
+		if err := b.Save(fresh); err != nil {
+			t.Errorf("Save() of the current revision error = %v", err)
+		}
+		if err := b.Save(&Data{}); err != nil {
+			t.Errorf("Save() of new data error = %v", err)
+		}
+	})
+
+	t.Run("UpdateFrom", func(t *testing.T) {
+		b := newBackend(t)
+
+		// Nothing was ever saved
+		base, _ := b.Load()
+		bm := testutil.CreateTestBookmark("Test", "https://test.com", "test")
+		if err := UpdateFrom(b, base, func(data *Data) error { return data.AddBookmark(bm) }); err != nil {
+			t.Fatalf("UpdateFrom() on a new library error = %v", err)
+		}
+
+		base, _ = b.Load()
+		b.Update(func(data *Data) error {
+			data.FindBookmark(bm.ID).Title = "Theirs"
+			return nil
+		})
+
+		called := false
+		err := UpdateFrom(b, base, func(data *Data) error {
+			called = true
+			return nil
+		})
+		var conflict *ConflictError
+		if !errors.As(err, &conflict) {
+			t.Fatalf("UpdateFrom() error = %v, want ConflictError", err)
+		}
+		if called {
+			t.Error("UpdateFrom() must not run fn on a conflict")
+		}
+		if len(conflict.Changes) != 1 || conflict.Changes[0].Op != OpUpdate || conflict.Changes[0].After.Title != "Theirs" {
+			t.Errorf("Changes = %+v, want the title update", conflict.Changes)
+		}
+
+		if err := UpdateFrom(b, conflict.Current, func(data *Data) error { return nil }); err != nil {
+			t.Errorf("UpdateFrom() against the current revision error = %v", err)
+		}
+	})
+
+	t.Run("GetBookmarksByCategory", func(t *testing.T) {
+		b := newBackend(t)
+
+		for _, bm := range testutil.CreateTestBookmarks() {
+			b.AddBookmark(bm)
+		}
+
+		tests := map[string]int{
+			"programming/go": 1,
+			"programming":    1,
+			"tools":          1,
+			"":               1,
+			"non-existent":   0,
+		}
+		for category, want := range tests {
+			got, err := b.GetBookmarksByCategory(category)
+			if err != nil {
+				t.Fatalf("GetBookmarksByCategory(%q) error = %v", category, err)
+			}
+			if len(got) != want {
+				t.Errorf("GetBookmarksByCategory(%q) returned %d, want %d", category, len(got), want)
+			}
+		}
+	})
+
+	t.Run("SearchBookmarks", func(t *testing.T) {
+		b := newBackend(t)
+
+		b.AddBookmark(&bookmark.Bookmark{ID: "1", Title: "Go Programming Language", URL: "https://golang.org", Category: "go"})
+		b.AddBookmark(&bookmark.Bookmark{ID: "2", Title: "Python", URL: "https://python.org", Description: "Learn programming", Category: "python"})
+		b.AddBookmark(&bookmark.Bookmark{ID: "3", Title: "GitHub", URL: "https://github.com", Category: "tools"})
+
+		tests := map[string][]string{
+			"programming": {"1", "2"},
+			"GITHUB":      {"3"},
+			"https://":    {"1", "2", "3"},
+			"nothing":     {},
+		}
+		for query, want := range tests {
+			results, err := b.SearchBookmarks(query)
+			if err != nil {
+				t.Fatalf("SearchBookmarks(%q) error = %v", query, err)
+			}
+			got := []string{}
+			for _, r := range results {
+				got = append(got, r.ID)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("SearchBookmarks(%q) = %v, want %v", query, got, want)
+			}
+		}
+	})
+
+	t.Run("UpdateRollback", func(t *testing.T) {
+		b := newBackend(t)
+
+		b.AddBookmark(testutil.CreateTestBookmark("Test", "https://test.com", "test"))
+
+		wantErr := errors.New("abort")
+		err := b.Update(func(data *Data) error {
+			data.Bookmarks[0].Title = "Changed"
+			data.Categories = append(data.Categories, "extra")
+			return wantErr
+		})
+		if !errors.Is(err, wantErr) {
+			t.Fatalf("Update() error = %v, want %v", err, wantErr)
+		}
+
+		data, _ := b.Load()
+		if data.Bookmarks[0].Title != "Test" || len(data.Categories) != 1 {
+			t.Errorf("Failed Update() was not rolled back: %+v %v", data.Bookmarks[0], data.Categories)
+		}
+	})
+
+	t.Run("UpdateConcurrent", func(t *testing.T) {
+		b := newBackend(t)
+
+		const workers = 10
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := b.Update(func(data *Data) error {
+					data.Categories = append(data.Categories, fmt.Sprintf("cat%d", len(data.Categories)))
+					return nil
+				})
+				if err != nil {
+					t.Errorf("Update() error = %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		data, _ := b.Load()
+		if len(data.Categories) != workers {
+			t.Errorf("Expected %d categories, got %d (lost updates)", workers, len(data.Categories))
+		}
+	})
+}
+
+func TestFileBackendConformance(t *testing.T) {
+	backendConformance(t, func(t *testing.T) Backend {
+		dir, cleanup := testutil.TempDir(t)
+		t.Cleanup(cleanup)
+
+		s, err := New(dir)
+		if err != nil {
+			t.Fatalf("Failed to create storage: %v", err)
+		}
+		return s
+	})
+}
+
+func TestMemoryBackendConformance(t *testing.T) {
+	backendConformance(t, func(t *testing.T) Backend {
+		return NewMemory()
+	})
+}
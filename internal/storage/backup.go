@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxBackups = 5
+	backupPrefix      = "bookmarks-"
+	backupTimeFormat  = "20060102T150405.000000000Z"
+	backupDirFileMode = 0755
+)
+
+// Backup describes one backup generation on disk
+type Backup struct {
+	Path      string
+	CreatedAt time.Time
+}
+
+// createBackup copies the current file into a new timestamped generation and
+// drops the oldest generations beyond the configured limit.
+func (s *Storage) createBackup() error {
+	if err := os.MkdirAll(s.backupDir, backupDirFileMode); err != nil {
+		return err
+	}
+
+	// Backups of a plain library are already encrypted once encryption is on
+	raw, err := os.ReadFile(s.filePath)
+	if err != nil {
+		return err
+	}
+	if raw, err = s.sealPlain(raw); err != nil {
+		return err
+	}
+
+	// Coarse clocks can produce the same timestamp twice; never overwrite
+	now := time.Now().UTC()
+	path := s.backupFilePath(now)
+	for {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		now = now.Add(time.Nanosecond)
+		path = s.backupFilePath(now)
+	}
+
+	dst, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fileMode(s.enc))
+	if err != nil {
+		return err
+	}
+
+	if _, err := dst.Write(raw); err != nil {
+		dst.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	_, err = s.pruneBackups(s.maxBackups)
+	return err
+}
+
+// sealPlain encrypts raw unless it is already encrypted or encryption is off
+func (s *Storage) sealPlain(raw []byte) ([]byte, error) {
+	if s.enc == nil || isEncrypted(raw) {
+		return raw, nil
+	}
+	return s.enc.seal(raw)
+}
+
+func (s *Storage) backupFilePath(t time.Time) string {
+	name := backupPrefix + t.Format(backupTimeFormat) + filepath.Ext(s.filePath)
+	return filepath.Join(s.backupDir, name)
+}
+
+// ListBackups returns the available backup generations, newest first
+func (s *Storage) ListBackups() ([]Backup, error) {
+	entries, err := os.ReadDir(s.backupDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Backup{}, nil
+		}
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	backups := []Backup{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, backupPrefix) {
+			continue
+		}
+
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), filepath.Ext(name))
+		createdAt, err := time.Parse(backupTimeFormat, stamp)
+		if err != nil {
+			continue
+		}
+
+		backups = append(backups, Backup{
+			Path:      filepath.Join(s.backupDir, name),
+			CreatedAt: createdAt,
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+
+	return backups, nil
+}
+
+// LoadBackup reads the n-th newest backup generation (1-based)
+func (s *Storage) LoadBackup(n int) (*Data, error) {
+	var data *Data
+	err := s.withLock(false, func() error {
+		backup, err := s.backupAt(n)
+		if err != nil {
+			return err
+		}
+		data, err = s.loadFile(backup.Path)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// RestoreBackup replaces the library with the n-th newest backup generation
+// (1-based). The current file is backed up first, even with automatic
+// backups off, so a restore can itself be rolled back.
+func (s *Storage) RestoreBackup(n int) error {
+	return s.withLock(true, func() error {
+		backup, err := s.backupAt(n)
+		if err != nil {
+			return err
+		}
+
+		data, err := s.loadFile(backup.Path)
+		if err != nil {
+			return fmt.Errorf("failed to read backup %d: %w", n, err)
+		}
+
+		// save only backs up when autoBackup is set
+		if _, err := os.Stat(s.filePath); err == nil && !s.autoBackup {
+			if err := s.createBackup(); err != nil {
+				return fmt.Errorf("failed to create backup: %w", err)
+			}
+		}
+		return s.save(s.previous(), data, txMeta{})
+	})
+}
+
+// PruneBackups deletes all but the newest keep generations and returns how
+// many were removed
+func (s *Storage) PruneBackups(keep int) (int, error) {
+	var removed int
+	err := s.withLock(true, func() error {
+		var err error
+		removed, err = s.pruneBackups(keep)
+		return err
+	})
+	return removed, err
+}
+
+func (s *Storage) pruneBackups(keep int) (int, error) {
+	if keep < 0 {
+		keep = 0
+	}
+
+	backups, err := s.ListBackups()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for i := keep; i < len(backups); i++ {
+		if err := os.Remove(backups[i].Path); err != nil && !os.IsNotExist(err) {
+			return removed, fmt.Errorf("failed to remove backup: %w", err)
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+func (s *Storage) backupAt(n int) (Backup, error) {
+	backups, err := s.ListBackups()
+	if err != nil {
+		return Backup{}, err
+	}
+
+	if n < 1 || n > len(backups) {
+		return Backup{}, fmt.Errorf("backup %d not found (%d available)", n, len(backups))
+	}
+
+	return backups[n-1], nil
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/internal/testutil"
+)
+
+func saveGenerations(t *testing.T, s *Storage, count int) {
+	t.Helper()
+	for i := 1; i <= count; i++ {
+		title := fmt.Sprintf("Gen%d", i)
+		data := &Data{
+			Bookmarks:  []*bookmark.Bookmark{testutil.CreateTestBookmark(title, "https://example.com/"+title, "test")},
+			Categories: []string{"test"},
+		}
+		if err := s.Save(data); err != nil {
+			t.Fatalf("Save() generation %d error = %v", i, err)
+		}
+	}
+}
+
+func TestStorage_BackupRotation(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s, err := NewWithOptions(dir, Options{AutoBackup: true, MaxBackups: 3})
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	saveGenerations(t, s, 6)
+
+	backups, err := s.ListBackups()
+	if err != nil {
+		t.Fatalf("ListBackups() error = %v", err)
+	}
+	if len(backups) != 3 {
+		t.Fatalf("Expected 3 backups, got %d", len(backups))
+	}
+
+	// Newest first: the file holds Gen6, so backups hold Gen5, Gen4, Gen3
+	for i, want := range []string{"Gen5", "Gen4", "Gen3"} {
+		data, err := s.LoadBackup(i + 1)
+		if err != nil {
+			t.Fatalf("LoadBackup(%d) error = %v", i+1, err)
+		}
+		if got := data.Bookmarks[0].Title; got != want {
+			t.Errorf("LoadBackup(%d) title = %v, want %v", i+1, got, want)
+		}
+	}
+
+	for i := 1; i < len(backups); i++ {
+		if !backups[i-1].CreatedAt.After(backups[i].CreatedAt) {
+			t.Errorf("Backups are not ordered newest first: %v", backups)
+		}
+	}
+}
+
+func TestStorage_AutoBackupDisabled(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s, err := NewWithOptions(dir, Options{AutoBackup: false, MaxBackups: 3})
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	saveGenerations(t, s, 3)
+
+	backups, err := s.ListBackups()
+	if err != nil {
+		t.Fatalf("ListBackups() error = %v", err)
+	}
+	if len(backups) != 0 {
+		t.Errorf("Expected no backups with AutoBackup disabled, got %d", len(backups))
+	}
+}
+
+func TestStorage_RestoreBackup(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	saveGenerations(t, s, 4)
+
+	// Backup 3 is two generations before the previous one: Gen1
+	if err := s.RestoreBackup(3); err != nil {
+		t.Fatalf("RestoreBackup() error = %v", err)
+	}
+
+	data, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := data.Bookmarks[0].Title; got != "Gen1" {
+		t.Errorf("Restored title = %v, want Gen1", got)
+	}
+
+	// The pre-restore state is now the newest backup
+	latest, err := s.LoadBackup(1)
+	if err != nil {
+		t.Fatalf("LoadBackup(1) error = %v", err)
+	}
+	if got := latest.Bookmarks[0].Title; got != "Gen4" {
+		t.Errorf("Newest backup title = %v, want Gen4", got)
+	}
+
+	if err := s.RestoreBackup(99); err == nil {
+		t.Error("Expected error when restoring a missing backup")
+	}
+	if err := s.RestoreBackup(0); err == nil {
+		t.Error("Expected error when restoring backup 0")
+	}
+}
+
+func TestStorage_RestoreBackupWithoutAutoBackup(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	saveGenerations(t, s, 3)
+
+	manual, err := NewWithOptions(dir, Options{AutoBackup: false})
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := manual.RestoreBackup(2); err != nil {
+		t.Fatalf("RestoreBackup() error = %v", err)
+	}
+
+	// The pre-restore state is backed up all the same
+	latest, err := manual.LoadBackup(1)
+	if err != nil {
+		t.Fatalf("LoadBackup(1) error = %v", err)
+	}
+	if got := latest.Bookmarks[0].Title; got != "Gen3" {
+		t.Errorf("Newest backup title = %v, want Gen3", got)
+	}
+}
+
+func TestStorage_PruneBackups(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s, err := NewWithOptions(dir, Options{AutoBackup: true, MaxBackups: 10})
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	saveGenerations(t, s, 6)
+
+	removed, err := s.PruneBackups(2)
+	if err != nil {
+		t.Fatalf("PruneBackups() error = %v", err)
+	}
+	if removed != 3 {
+		t.Errorf("PruneBackups() removed %d, want 3", removed)
+	}
+
+	backups, _ := s.ListBackups()
+	if len(backups) != 2 {
+		t.Errorf("Expected 2 backups after prune, got %d", len(backups))
+	}
+}
@@ -0,0 +1,776 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	bolterrors "go.etcd.io/bbolt/errors"
+
+	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/internal/lockfile"
+)
+
+const boltFileName = "bookmarks.db"
+
+var (
+	// Bookmarks are keyed by an insertion sequence so Load keeps their order
+	bucketBookmarks = []byte("bookmarks")
+	bucketMeta      = []byte("meta")
+
+	// Index buckets map "<value>\x00<seq>" to nothing, except the ID index
+	// which maps an ID straight to its sequence key
+	bucketIDIndex       = []byte("idx_id")
+	bucketCategoryIndex = []byte("idx_category")
+	bucketURLIndex      = []byte("idx_url")
+	bucketTagIndex      = []byte("idx_tag")
+
+	metaSchemaVersion = []byte("schema_version")
+	metaUpdatedAt     = []byte("updated_at")
+	metaCategories    = []byte("categories")
+	metaTrash         = []byte("trash")
+
+	dataBuckets = [][]byte{bucketBookmarks, bucketIDIndex, bucketCategoryIndex, bucketURLIndex, bucketTagIndex}
+)
+
+// BoltStorage is a Backend on top of an embedded bbolt database. Lookups by
+// ID, category, URL, and tag use indexes instead of decoding the whole
+// library, which keeps large imported libraries fast.
+//
+// The database is only open during an operation, under the same lock file
+// as the file backend, so other ubm processes can use the library in
+// between and are told who holds it when they cannot.
+type BoltStorage struct {
+	path        string
+	lockPath    string
+	lockTimeout time.Duration
+	journal     *Journal
+}
+
+func NewBolt(dir string) (*BoltStorage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	s := &BoltStorage{
+		path:        filepath.Join(dir, boltFileName),
+		lockPath:    filepath.Join(dir, lockfile.FileName),
+		lockTimeout: lockfile.DefaultTimeout,
+		journal:     newJournal(dir, nil),
+	}
+
+	// Most commands only read, so the database is only opened for writing
+	// when it is new or was written by an older version
+	current := false
+	if _, err := os.Stat(s.path); err == nil {
+		err := s.view(func(tx *bolt.Tx) error {
+			var err error
+			current, err = schemaCurrent(tx)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if current {
+		return s, nil
+	}
+
+	err := s.update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(bucketMeta); err != nil {
+			return err
+		}
+		for _, name := range dataBuckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		// Another process may have got here first
+		if current, err := schemaCurrent(tx); err != nil || current {
+			return err
+		}
+		return tx.Bucket(bucketMeta).Put(metaSchemaVersion, seqKey(CurrentSchemaVersion))
+	}, func() error { return nil })
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// schemaCurrent reports whether every bucket exists and the stored schema
+// version is the current one. A newer version is an error.
+func schemaCurrent(tx *bolt.Tx) (bool, error) {
+	meta := tx.Bucket(bucketMeta)
+	if meta == nil {
+		return false, nil
+	}
+	for _, name := range dataBuckets {
+		if tx.Bucket(name) == nil {
+			return false, nil
+		}
+	}
+
+	v := meta.Get(metaSchemaVersion)
+	if v == nil {
+		return false, nil
+	}
+	version := int(binary.BigEndian.Uint64(v))
+	if version > CurrentSchemaVersion {
+		return false, &UnsupportedSchemaError{Version: version}
+	}
+	return version == CurrentSchemaVersion, nil
+}
+
+// Path returns the location of the database file
+func (s *BoltStorage) Path() string {
+	return s.path
+}
+
+// Journal returns the log of changes made to the library
+func (s *BoltStorage) Journal() *Journal {
+	return s.journal
+}
+
+// SetLockTimeout sets how long to wait for another process to release the library
+func (s *BoltStorage) SetLockTimeout(timeout time.Duration) {
+	s.lockTimeout = timeout
+}
+
+// withDB opens the database for one operation while holding the library
+// lock, shared for reads. bbolt locks the file itself as well, but cannot
+// say who holds it.
+func (s *BoltStorage) withDB(writable bool, fn func(*bolt.DB) error) error {
+	lock, err := lockfile.Acquire(s.lockPath, writable, s.lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	db, err := bolt.Open(s.path, 0644, &bolt.Options{Timeout: s.lockTimeout, ReadOnly: !writable})
+	if err != nil {
+		if errors.Is(err, bolterrors.ErrTimeout) {
+			return &LockedError{Path: s.path}
+		}
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+
+	fnErr := fn(db)
+	if err := db.Close(); err != nil && fnErr == nil {
+		return err
+	}
+	return fnErr
+}
+
+func (s *BoltStorage) view(fn func(*bolt.Tx) error) error {
+	return s.withDB(false, func(db *bolt.DB) error {
+		return db.View(fn)
+	})
+}
+
+// update runs fn in a read-write transaction, then journal once it has
+// committed. Both happen under the library lock, so no other writer can slip
+// in between the commit and the append.
+func (s *BoltStorage) update(fn func(*bolt.Tx) error, journal func() error) error {
+	return s.withDB(true, func(db *bolt.DB) error {
+		if err := db.Update(fn); err != nil {
+			return err
+		}
+		return journal()
+	})
+}
+
+func (s *BoltStorage) Load() (*Data, error) {
+	var data *Data
+	err := s.view(func(tx *bolt.Tx) error {
+		var err error
+		data, err = readData(tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Save replaces the whole library with data
+func (s *BoltStorage) Save(data *Data) error {
+	var before *Data
+	return s.update(func(tx *bolt.Tx) error {
+		var err error
+		if before, err = readData(tx); err != nil {
+			return err
+		}
+		if tx.Bucket(bucketMeta).Get(metaUpdatedAt) != nil {
+			if err := checkRevision(data, before, s.journal); err != nil {
+				return err
+			}
+		}
+
+		for _, name := range dataBuckets {
+			if err := tx.DeleteBucket(name); err != nil && !errors.Is(err, bolterrors.ErrBucketNotFound) {
+				return err
+			}
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+
+		for _, b := range data.Bookmarks {
+			if err := insertBookmark(tx, b); err != nil {
+				return err
+			}
+		}
+
+		if err := writeTrash(tx, data.Trash); err != nil {
+			return err
+		}
+		return writeMeta(tx, data)
+	}, func() error { return s.record(diffData(before, data), txMeta{}) })
+}
+
+// record journals entries once their transaction has committed
+func (s *BoltStorage) record(entries []JournalEntry, meta txMeta) error {
+	if err := s.journal.append(entries, meta); err != nil {
+		return fmt.Errorf("bookmarks were saved but the journal was not updated: %w", err)
+	}
+	return nil
+}
+
+// Update runs fn inside a single read-write transaction and writes back only
+// the bookmarks that changed. If fn fails the transaction is rolled back.
+func (s *BoltStorage) Update(fn func(*Data) error) error {
+	return s.updateTx(func(data *Data, _ *txMeta) error {
+		return fn(data)
+	})
+}
+
+// updateTx is Update for callers that tag the journal entries, such as undo
+func (s *BoltStorage) updateTx(fn func(*Data, *txMeta) error) error {
+	var (
+		entries []JournalEntry
+		meta    txMeta
+	)
+	return s.update(func(tx *bolt.Tx) error {
+		data, err := readData(tx)
+		if err != nil {
+			return err
+		}
+		before := data.Clone()
+
+		if err := fn(data, &meta); err != nil {
+			return err
+		}
+
+		if err := applyChanges(tx, before, data); err != nil {
+			return err
+		}
+		entries = diffData(before, data)
+		if err := writeTrash(tx, data.Trash); err != nil {
+			return err
+		}
+		return writeMeta(tx, data)
+	}, func() error { return s.record(entries, meta) })
+}
+
+func (s *BoltStorage) AddBookmark(b *bookmark.Bookmark) error {
+	var entries []JournalEntry
+	return s.update(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketIDIndex).Get([]byte(b.ID)) != nil {
+			return fmt.Errorf("bookmark with ID %s already exists", b.ID)
+		}
+
+		// Check for duplicate URL in the same category
+		existing, err := lookupIndex(tx, bucketURLIndex, b.URL)
+		if err != nil {
+			return err
+		}
+		for _, e := range existing {
+			if e.Category == b.Category {
+				return &DuplicateError{URL: b.URL, Category: b.Category}
+			}
+		}
+
+		if err := insertBookmark(tx, b); err != nil {
+			return err
+		}
+
+		created, err := addCategory(tx, b.Category)
+		if err != nil {
+			return err
+		}
+		entries = append(created, JournalEntry{Op: OpAdd, BookmarkID: b.ID, Category: b.Category, After: cloneBookmark(b)})
+		return nil
+	}, func() error { return s.record(entries, txMeta{}) })
+}
+
+func (s *BoltStorage) UpdateBookmark(b *bookmark.Bookmark) error {
+	var entries []JournalEntry
+	return s.update(func(tx *bolt.Tx) error {
+		old, err := replaceBookmark(tx, b)
+		if err != nil {
+			return err
+		}
+		if op, changed := bookmarkChange(old, b); changed {
+			entries = append(entries, JournalEntry{Op: op, BookmarkID: b.ID, Category: b.Category, Before: old, After: cloneBookmark(b)})
+		}
+		return touchMeta(tx)
+	}, func() error { return s.record(entries, txMeta{}) })
+}
+
+// EditBookmark runs fn on the stored bookmark with id, or on nil if there is
+// none, and saves what fn leaves, all in one transaction that only touches
+// that bookmark and its index entries. If fn fails nothing is written.
+func (s *BoltStorage) EditBookmark(id string, fn func(*bookmark.Bookmark) error) error {
+	var entries []JournalEntry
+	return s.update(func(tx *bolt.Tx) error {
+		b, err := findBookmark(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := fn(b); err != nil {
+			return err
+		}
+		if b == nil {
+			return fmt.Errorf("bookmark with ID %s not found", id)
+		}
+
+		old, err := replaceBookmark(tx, b)
+		if err != nil {
+			return err
+		}
+		if entries, err = addCategory(tx, b.Category); err != nil {
+			return err
+		}
+		if op, changed := bookmarkChange(old, b); changed {
+			entries = append(entries, JournalEntry{Op: op, BookmarkID: b.ID, Category: b.Category, Before: old, After: cloneBookmark(b)})
+		}
+		return nil
+	}, func() error { return s.record(entries, txMeta{}) })
+}
+
+func (s *BoltStorage) DeleteBookmark(id string) error {
+	return s.DeleteBookmarkIf(id, func(*bookmark.Bookmark) error { return nil })
+}
+
+// DeleteBookmarkIf moves the bookmark with id to the trash unless check,
+// called with the stored bookmark or nil if there is none, returns an error
+func (s *BoltStorage) DeleteBookmarkIf(id string, check func(*bookmark.Bookmark) error) error {
+	var entries []JournalEntry
+	return s.update(func(tx *bolt.Tx) error {
+		current, err := findBookmark(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := check(current); err != nil {
+			return err
+		}
+
+		old, err := removeBookmark(tx, id)
+		if err != nil {
+			return err
+		}
+		trash, err := readTrash(tx)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		trash = append(trash, &TrashedBookmark{Bookmark: old, DeletedAt: now})
+		if err := writeTrash(tx, trash); err != nil {
+			return err
+		}
+
+		entries = append(entries, JournalEntry{Op: OpTrash, BookmarkID: id, Category: old.Category, Before: cloneBookmark(old), DeletedAt: &now})
+		return touchMeta(tx)
+	}, func() error { return s.record(entries, txMeta{}) })
+}
+
+func (s *BoltStorage) GetBookmark(id string) (*bookmark.Bookmark, error) {
+	var result *bookmark.Bookmark
+	err := s.view(func(tx *bolt.Tx) error {
+		var err error
+		if result, err = findBookmark(tx, id); err == nil && result == nil {
+			err = fmt.Errorf("bookmark with ID %s not found", id)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *BoltStorage) GetBookmarksByCategory(category string) ([]*bookmark.Bookmark, error) {
+	return s.lookup(bucketCategoryIndex, category)
+}
+
+// FindByURL returns every bookmark with exactly this URL, using the URL index
+func (s *BoltStorage) FindByURL(url string) ([]*bookmark.Bookmark, error) {
+	return s.lookup(bucketURLIndex, url)
+}
+
+// FindByTag returns every bookmark carrying the tag, using the tag index
+func (s *BoltStorage) FindByTag(tag string) ([]*bookmark.Bookmark, error) {
+	return s.lookup(bucketTagIndex, tag)
+}
+
+// SearchBookmarks matches substrings, which no index can answer, so it scans
+// the stored bookmarks without building a full Data
+func (s *BoltStorage) SearchBookmarks(query string) ([]*bookmark.Bookmark, error) {
+	bookmarks := []*bookmark.Bookmark{}
+	err := s.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketBookmarks).ForEach(func(k, v []byte) error {
+			var b bookmark.Bookmark
+			if err := json.Unmarshal(v, &b); err != nil {
+				return err
+			}
+			if matchesQuery(&b, query) {
+				bookmarks = append(bookmarks, &b)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bookmarks, nil
+}
+
+func (s *BoltStorage) lookup(index []byte, value string) ([]*bookmark.Bookmark, error) {
+	var bookmarks []*bookmark.Bookmark
+	err := s.view(func(tx *bolt.Tx) error {
+		var err error
+		bookmarks, err = lookupIndex(tx, index, value)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bookmarks, nil
+}
+
+func readData(tx *bolt.Tx) (*Data, error) {
+	data := &Data{
+		SchemaVersion: CurrentSchemaVersion,
+		Bookmarks:     []*bookmark.Bookmark{},
+		UpdatedAt:     time.Now(),
+	}
+
+	if v := tx.Bucket(bucketMeta).Get(metaUpdatedAt); v != nil {
+		if err := data.UpdatedAt.UnmarshalText(v); err != nil {
+			return nil, fmt.Errorf("failed to decode data: %w", err)
+		}
+	}
+
+	categories, err := readCategories(tx)
+	if err != nil {
+		return nil, err
+	}
+	data.Categories = categories
+
+	if data.Trash, err = readTrash(tx); err != nil {
+		return nil, err
+	}
+
+	err = tx.Bucket(bucketBookmarks).ForEach(func(k, v []byte) error {
+		var b bookmark.Bookmark
+		if err := json.Unmarshal(v, &b); err != nil {
+			return fmt.Errorf("failed to decode bookmark: %w", err)
+		}
+		data.Bookmarks = append(data.Bookmarks, &b)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func readCategories(tx *bolt.Tx) ([]string, error) {
+	categories := []string{}
+	if v := tx.Bucket(bucketMeta).Get(metaCategories); v != nil {
+		if err := json.Unmarshal(v, &categories); err != nil {
+			return nil, fmt.Errorf("failed to decode categories: %w", err)
+		}
+	}
+	return categories, nil
+}
+
+// The trash is small and only ever read as a whole, so it is kept as a
+// single JSON value in the meta bucket
+func readTrash(tx *bolt.Tx) ([]*TrashedBookmark, error) {
+	trash := []*TrashedBookmark{}
+	if v := tx.Bucket(bucketMeta).Get(metaTrash); v != nil {
+		if err := json.Unmarshal(v, &trash); err != nil {
+			return nil, fmt.Errorf("failed to decode trash: %w", err)
+		}
+	}
+	return trash, nil
+}
+
+func writeTrash(tx *bolt.Tx, trash []*TrashedBookmark) error {
+	if trash == nil {
+		trash = []*TrashedBookmark{}
+	}
+	encoded, err := json.Marshal(trash)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(bucketMeta).Put(metaTrash, encoded)
+}
+
+// writeMeta stores the categories and stamps the revision, like Storage.save
+func writeMeta(tx *bolt.Tx, data *Data) error {
+	data.SchemaVersion = CurrentSchemaVersion
+	data.UpdatedAt = time.Now()
+
+	categories := data.Categories
+	if categories == nil {
+		categories = []string{}
+	}
+	encoded, err := json.Marshal(categories)
+	if err != nil {
+		return err
+	}
+
+	meta := tx.Bucket(bucketMeta)
+	if err := meta.Put(metaCategories, encoded); err != nil {
+		return err
+	}
+	return putUpdatedAt(meta, data.UpdatedAt)
+}
+
+// addCategory registers category if it is new and stamps the revision. It
+// returns the journal entry for a new category.
+func addCategory(tx *bolt.Tx, category string) ([]JournalEntry, error) {
+	categories, err := readCategories(tx)
+	if err != nil {
+		return nil, err
+	}
+	data := &Data{Categories: categories}
+	if category == "" || data.HasCategory(category) {
+		return nil, touchMeta(tx)
+	}
+
+	data.AddCategory(category)
+	return []JournalEntry{{Op: OpCategoryCreate, Category: category}}, writeMeta(tx, data)
+}
+
+func touchMeta(tx *bolt.Tx) error {
+	return putUpdatedAt(tx.Bucket(bucketMeta), time.Now())
+}
+
+func putUpdatedAt(meta *bolt.Bucket, t time.Time) error {
+	stamp, err := t.MarshalText()
+	if err != nil {
+		return err
+	}
+	return meta.Put(metaUpdatedAt, stamp)
+}
+
+// applyChanges writes the difference between two snapshots of the library
+func applyChanges(tx *bolt.Tx, before, after *Data) error {
+	afterIDs := make(map[string]bool, len(after.Bookmarks))
+	for _, b := range after.Bookmarks {
+		afterIDs[b.ID] = true
+	}
+
+	beforeByID := make(map[string][]byte, len(before.Bookmarks))
+	for _, b := range before.Bookmarks {
+		if !afterIDs[b.ID] {
+			if _, err := removeBookmark(tx, b.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		encoded, err := json.Marshal(b)
+		if err != nil {
+			return err
+		}
+		beforeByID[b.ID] = encoded
+	}
+
+	for _, b := range after.Bookmarks {
+		old, existed := beforeByID[b.ID]
+		if !existed {
+			if err := insertBookmark(tx, b); err != nil {
+				return err
+			}
+			continue
+		}
+
+		encoded, err := json.Marshal(b)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(old, encoded) {
+			if _, err := replaceBookmark(tx, b); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func insertBookmark(tx *bolt.Tx, b *bookmark.Bookmark) error {
+	bucket := tx.Bucket(bucketBookmarks)
+	next, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+	seq := seqKey(next)
+
+	encoded, err := json.Marshal(b)
+	if err != nil {
+		return fmt.Errorf("failed to marshal bookmark: %w", err)
+	}
+	if err := bucket.Put(seq, encoded); err != nil {
+		return err
+	}
+	return putIndexes(tx, b, seq)
+}
+
+// replaceBookmark overwrites the stored bookmark with the same ID and
+// returns the previous version
+func replaceBookmark(tx *bolt.Tx, b *bookmark.Bookmark) (*bookmark.Bookmark, error) {
+	seq := tx.Bucket(bucketIDIndex).Get([]byte(b.ID))
+	if seq == nil {
+		return nil, fmt.Errorf("bookmark with ID %s not found", b.ID)
+	}
+	seq = append([]byte{}, seq...)
+
+	old, err := getBookmark(tx, seq)
+	if err != nil {
+		return nil, err
+	}
+	if err := deleteIndexes(tx, old, seq); err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal bookmark: %w", err)
+	}
+	if err := tx.Bucket(bucketBookmarks).Put(seq, encoded); err != nil {
+		return nil, err
+	}
+	return old, putIndexes(tx, b, seq)
+}
+
+// removeBookmark deletes the bookmark with the given ID and returns it
+func removeBookmark(tx *bolt.Tx, id string) (*bookmark.Bookmark, error) {
+	seq := tx.Bucket(bucketIDIndex).Get([]byte(id))
+	if seq == nil {
+		return nil, fmt.Errorf("bookmark with ID %s not found", id)
+	}
+	seq = append([]byte{}, seq...)
+
+	old, err := getBookmark(tx, seq)
+	if err != nil {
+		return nil, err
+	}
+	if err := deleteIndexes(tx, old, seq); err != nil {
+		return nil, err
+	}
+	return old, tx.Bucket(bucketBookmarks).Delete(seq)
+}
+
+// findBookmark looks a bookmark up through the ID index. It returns nil if
+// there is none with this ID.
+func findBookmark(tx *bolt.Tx, id string) (*bookmark.Bookmark, error) {
+	seq := tx.Bucket(bucketIDIndex).Get([]byte(id))
+	if seq == nil {
+		return nil, nil
+	}
+	return getBookmark(tx, seq)
+}
+
+func getBookmark(tx *bolt.Tx, seq []byte) (*bookmark.Bookmark, error) {
+	v := tx.Bucket(bucketBookmarks).Get(seq)
+	if v == nil {
+		return nil, fmt.Errorf("index points at missing bookmark %x", seq)
+	}
+	var b bookmark.Bookmark
+	if err := json.Unmarshal(v, &b); err != nil {
+		return nil, fmt.Errorf("failed to decode bookmark: %w", err)
+	}
+	return &b, nil
+}
+
+func putIndexes(tx *bolt.Tx, b *bookmark.Bookmark, seq []byte) error {
+	if err := tx.Bucket(bucketIDIndex).Put([]byte(b.ID), seq); err != nil {
+		return err
+	}
+	for _, index := range secondaryIndexes(b) {
+		for _, v := range index.values {
+			if err := tx.Bucket(index.bucket).Put(indexKey(v, seq), []byte{}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func deleteIndexes(tx *bolt.Tx, b *bookmark.Bookmark, seq []byte) error {
+	if err := tx.Bucket(bucketIDIndex).Delete([]byte(b.ID)); err != nil {
+		return err
+	}
+	for _, index := range secondaryIndexes(b) {
+		for _, v := range index.values {
+			if err := tx.Bucket(index.bucket).Delete(indexKey(v, seq)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type indexEntry struct {
+	bucket []byte
+	values []string
+}
+
+func secondaryIndexes(b *bookmark.Bookmark) []indexEntry {
+	return []indexEntry{
+		{bucket: bucketCategoryIndex, values: []string{b.Category}},
+		{bucket: bucketURLIndex, values: []string{b.URL}},
+		{bucket: bucketTagIndex, values: b.Tags},
+	}
+}
+
+func lookupIndex(tx *bolt.Tx, index []byte, value string) ([]*bookmark.Bookmark, error) {
+	bookmarks := []*bookmark.Bookmark{}
+	prefix := indexKey(value, nil)
+
+	cursor := tx.Bucket(index).Cursor()
+	for k, _ := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cursor.Next() {
+		b, err := getBookmark(tx, k[len(prefix):])
+		if err != nil {
+			return nil, err
+		}
+		bookmarks = append(bookmarks, b)
+	}
+
+	return bookmarks, nil
+}
+
+func indexKey(value string, seq []byte) []byte {
+	key := make([]byte, 0, len(value)+1+len(seq))
+	key = append(key, value...)
+	key = append(key, 0)
+	return append(key, seq...)
+}
+
+func seqKey(n uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, n)
+	return key
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/internal/testutil"
+)
+
+var benchmarkSizes = []int{1000, 10000, 20000}
+
+func benchmarkLibrary(size int) *Data {
+	data := &Data{Bookmarks: make([]*bookmark.Bookmark, 0, size), Categories: []string{}}
+	for i := 0; i < size; i++ {
+		category := fmt.Sprintf("category%d", i%100)
+		b := testutil.CreateTestBookmark(fmt.Sprintf("Bookmark %d", i), fmt.Sprintf("https://example.com/%d", i), category)
+		b.ID = fmt.Sprintf("id-%d", i)
+		b.Tags = []string{fmt.Sprintf("tag%d", i%50)}
+		data.Bookmarks = append(data.Bookmarks, b)
+		data.AddCategory(category)
+	}
+	return data
+}
+
+func benchmarkBackends(b *testing.B, size int) map[string]Backend {
+	b.Helper()
+
+	dir := b.TempDir()
+	file, err := New(dir)
+	if err != nil {
+		b.Fatalf("Failed to create storage: %v", err)
+	}
+	db, err := NewBolt(dir)
+	if err != nil {
+		b.Fatalf("Failed to open bolt storage: %v", err)
+	}
+
+	backends := map[string]Backend{"file": file, "bolt": db}
+	for name, backend := range backends {
+		if err := backend.Save(benchmarkLibrary(size)); err != nil {
+			b.Fatalf("Failed to seed %s backend: %v", name, err)
+		}
+	}
+	return backends
+}
+
+// The file backend decodes the whole document per lookup, so its cost grows
+// with the library; the bolt backend answers from its indexes.
+
+func BenchmarkGetBookmark(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		backends := benchmarkBackends(b, size)
+		for _, name := range []string{"file", "bolt"} {
+			backend := backends[name]
+			b.Run(fmt.Sprintf("%s/%d", name, size), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := backend.GetBookmark(fmt.Sprintf("id-%d", i%size)); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkGetBookmarksByCategory(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		backends := benchmarkBackends(b, size)
+		for _, name := range []string{"file", "bolt"} {
+			backend := backends[name]
+			b.Run(fmt.Sprintf("%s/%d", name, size), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := backend.GetBookmarksByCategory(fmt.Sprintf("category%d", i%100)); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkBoltFindByURL(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		db := benchmarkBackends(b, size)["bolt"].(*BoltStorage)
+		b.Run(fmt.Sprintf("%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := db.FindByURL(fmt.Sprintf("https://example.com/%d", i%size)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
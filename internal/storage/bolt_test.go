@@ -0,0 +1,271 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/internal/lockfile"
+	"github.com/tom-023/ubm/internal/testutil"
+)
+
+func newTestBolt(t *testing.T) *BoltStorage {
+	t.Helper()
+
+	dir, cleanup := testutil.TempDir(t)
+	s, err := NewBolt(dir)
+	if err != nil {
+		cleanup()
+		t.Fatalf("Failed to open bolt storage: %v", err)
+	}
+	t.Cleanup(cleanup)
+	return s
+}
+
+func TestBoltBackendConformance(t *testing.T) {
+	backendConformance(t, func(t *testing.T) Backend {
+		return newTestBolt(t)
+	})
+}
+
+func TestBoltStorage_IndexesFollowChanges(t *testing.T) {
+	s := newTestBolt(t)
+
+	b := testutil.CreateTestBookmark("Go", "https://go.dev", "programming")
+	b.Tags = []string{"lang"}
+	if err := s.AddBookmark(b); err != nil {
+		t.Fatalf("AddBookmark() error = %v", err)
+	}
+
+	// Move it, retag it, and change its URL through a generic transaction
+	err := s.Update(func(data *Data) error {
+		target := data.FindBookmark(b.ID)
+		target.Category = "golang"
+		target.URL = "https://golang.org"
+		target.Tags = []string{"docs"}
+		data.AddCategory("golang")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	checks := []struct {
+		name   string
+		lookup func() (int, error)
+		want   int
+	}{
+		{"old category", func() (int, error) { r, err := s.GetBookmarksByCategory("programming"); return len(r), err }, 0},
+		{"new category", func() (int, error) { r, err := s.GetBookmarksByCategory("golang"); return len(r), err }, 1},
+		{"old URL", func() (int, error) { r, err := s.FindByURL("https://go.dev"); return len(r), err }, 0},
+		{"new URL", func() (int, error) { r, err := s.FindByURL("https://golang.org"); return len(r), err }, 1},
+		{"old tag", func() (int, error) { r, err := s.FindByTag("lang"); return len(r), err }, 0},
+		{"new tag", func() (int, error) { r, err := s.FindByTag("docs"); return len(r), err }, 1},
+	}
+	for _, c := range checks {
+		got, err := c.lookup()
+		if err != nil {
+			t.Fatalf("%s lookup error = %v", c.name, err)
+		}
+		if got != c.want {
+			t.Errorf("%s lookup returned %d, want %d", c.name, got, c.want)
+		}
+	}
+
+	if err := s.DeleteBookmark(b.ID); err != nil {
+		t.Fatalf("DeleteBookmark() error = %v", err)
+	}
+	if r, _ := s.FindByURL("https://golang.org"); len(r) != 0 {
+		t.Error("URL index still points at a deleted bookmark")
+	}
+	if r, _ := s.GetBookmarksByCategory("golang"); len(r) != 0 {
+		t.Error("Category index still points at a deleted bookmark")
+	}
+}
+
+func TestBoltStorage_EditBookmark(t *testing.T) {
+	s := newTestBolt(t)
+
+	b := testutil.CreateTestBookmark("Go", "https://go.dev", "programming")
+	if err := s.AddBookmark(b); err != nil {
+		t.Fatalf("AddBookmark() error = %v", err)
+	}
+
+	// A failing fn writes nothing
+	refused := errors.New("refused")
+	err := s.EditBookmark(b.ID, func(current *bookmark.Bookmark) error {
+		current.Title = "Changed"
+		return refused
+	})
+	if !errors.Is(err, refused) {
+		t.Errorf("EditBookmark() error = %v, want the error of fn", err)
+	}
+	if got, _ := s.GetBookmark(b.ID); got.Title != "Go" {
+		t.Errorf("Title = %q after a failed edit, want Go", got.Title)
+	}
+
+	err = s.EditBookmark(b.ID, func(current *bookmark.Bookmark) error {
+		current.SetCategory("golang")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("EditBookmark() error = %v", err)
+	}
+	if r, _ := s.GetBookmarksByCategory("golang"); len(r) != 1 {
+		t.Error("Category index does not follow the edit")
+	}
+	data, _ := s.Load()
+	if !data.HasCategory("golang") {
+		t.Errorf("Categories = %v, want golang registered", data.Categories)
+	}
+	entries, _ := s.Journal().Entries(JournalFilter{})
+	if last := entries[len(entries)-1]; last.Op != OpMove || entries[len(entries)-2].Op != OpCategoryCreate {
+		t.Errorf("Journal ends with %s, want a new category and a move", last.Op)
+	}
+
+	var seen *bookmark.Bookmark
+	s.EditBookmark("missing", func(current *bookmark.Bookmark) error {
+		seen = current
+		return refused
+	})
+	if seen != nil {
+		t.Errorf("EditBookmark() of a missing ID passed %+v, want nil", seen)
+	}
+
+	// DeleteBookmarkIf leaves the bookmark alone when check fails
+	if err := s.DeleteBookmarkIf(b.ID, func(*bookmark.Bookmark) error { return refused }); !errors.Is(err, refused) {
+		t.Errorf("DeleteBookmarkIf() error = %v, want the error of check", err)
+	}
+	if err := s.DeleteBookmarkIf(b.ID, func(*bookmark.Bookmark) error { return nil }); err != nil {
+		t.Fatalf("DeleteBookmarkIf() error = %v", err)
+	}
+	if data, _ := s.Load(); len(data.Bookmarks) != 0 || len(data.Trash) != 1 {
+		t.Errorf("After delete: %d bookmarks, %d trashed, want 0 and 1", len(data.Bookmarks), len(data.Trash))
+	}
+}
+
+func TestBoltStorage_CategoryPrefixIsExact(t *testing.T) {
+	s := newTestBolt(t)
+
+	s.AddBookmark(testutil.CreateTestBookmark("Go", "https://go.dev", "programming/go"))
+	s.AddBookmark(testutil.CreateTestBookmark("Lang", "https://lang.dev", "programming"))
+
+	got, err := s.GetBookmarksByCategory("programming")
+	if err != nil {
+		t.Fatalf("GetBookmarksByCategory() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Title != "Lang" {
+		t.Errorf("GetBookmarksByCategory(programming) = %v, want only Lang", got)
+	}
+}
+
+func TestBoltStorage_PersistsAcrossReopen(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s, err := NewBolt(dir)
+	if err != nil {
+		t.Fatalf("NewBolt() error = %v", err)
+	}
+	bookmarks := testutil.CreateTestBookmarks()
+	if err := s.Save(&Data{Bookmarks: bookmarks, Categories: testutil.SampleCategories()}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// Reopening only reads, so it works while another process is reading
+	held, err := lockfile.Acquire(filepath.Join(dir, lockfile.FileName), false, time.Second)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	defer held.Release()
+	reopened, err := NewBolt(dir)
+	if err != nil {
+		t.Fatalf("NewBolt() reopen error = %v", err)
+	}
+
+	data, err := reopened.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(data.Bookmarks) != len(bookmarks) {
+		t.Fatalf("Loaded %d bookmarks, want %d", len(data.Bookmarks), len(bookmarks))
+	}
+
+	// Insertion order survives the round trip
+	for i, b := range data.Bookmarks {
+		if b.ID != bookmarks[i].ID {
+			t.Errorf("Bookmark[%d].ID = %v, want %v", i, b.ID, bookmarks[i].ID)
+		}
+	}
+}
+
+func TestBoltStorage_LockedByAnotherHandle(t *testing.T) {
+	s := newTestBolt(t)
+	s.SetLockTimeout(100 * time.Millisecond)
+	dir := filepath.Dir(s.Path())
+
+	// The database is only open during an operation, so another process can
+	// open the library while this one has it
+	other, err := NewBolt(dir)
+	if err != nil {
+		t.Fatalf("NewBolt() while another handle exists error = %v", err)
+	}
+	if err := other.AddBookmark(testutil.CreateTestBookmark("Go", "https://go.dev", "")); err != nil {
+		t.Fatalf("AddBookmark() on the second handle error = %v", err)
+	}
+
+	// Hold the lock through a separate file handle, as another process would
+	held, err := lockfile.Acquire(s.lockPath, true, time.Second)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	defer held.Release()
+
+	err = s.AddBookmark(testutil.CreateTestBookmark("Rust", "https://rust-lang.org", ""))
+	var lockedErr *LockedError
+	if !errors.As(err, &lockedErr) {
+		t.Fatalf("Expected *LockedError while the lock is held, got %v", err)
+	}
+	if lockedErr.PID != os.Getpid() {
+		t.Errorf("LockedError.PID = %d, want the holder's %d", lockedErr.PID, os.Getpid())
+	}
+}
+
+func TestCopy_FileToBolt(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	file, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	bookmarks := testutil.CreateTestBookmarks()
+	bookmarks[0].Tags = []string{"go"}
+	if err := file.Save(&Data{Bookmarks: bookmarks, Categories: testutil.SampleCategories()}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	db := newTestBolt(t)
+	if err := Copy(db, file); err != nil {
+		t.Fatalf("Copy() error = %v", err)
+	}
+
+	got, err := db.FindByTag("go")
+	if err != nil || len(got) != 1 || got[0].ID != bookmarks[0].ID {
+		t.Errorf("FindByTag(go) = %v, %v; want the migrated bookmark", got, err)
+	}
+
+	data, _ := db.Load()
+	if len(data.Bookmarks) != len(bookmarks) || len(data.Categories) != len(testutil.SampleCategories()) {
+		t.Errorf("Copy() lost data: %d bookmarks, %d categories", len(data.Bookmarks), len(data.Categories))
+	}
+
+	// Overwriting a library saved after the source is not a conflict
+	db.AddBookmark(testutil.CreateTestBookmark("Newer", "https://newer.example.com", ""))
+	if err := Copy(db, file); err != nil {
+		t.Errorf("Copy() over a newer library error = %v", err)
+	}
+}
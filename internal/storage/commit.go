@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Committer records a save of the library file, e.g. as a git commit
+type Committer interface {
+	Commit(path, message string) error
+}
+
+// commitMessage describes a save in the style of "add: <title>". The subject
+// names the first change; the body lists all of them when there are several.
+func commitMessage(changes []JournalEntry, meta txMeta) string {
+	// Bookmark changes say more than the categories they create
+	var lines []string
+	implied := make(map[string]bool)
+	for _, e := range changes {
+		if e.BookmarkID != "" {
+			lines = append(lines, describeChange(e))
+			if e.After != nil {
+				implied[e.After.Category] = true
+			}
+		}
+	}
+	for _, e := range changes {
+		if e.BookmarkID == "" && !(e.Op == OpCategoryCreate && implied[e.Category]) {
+			lines = append(lines, describeChange(e))
+		}
+	}
+
+	prefix := ""
+	switch {
+	case meta.undoes != "":
+		prefix = "undo "
+	case meta.redoes != "":
+		prefix = "redo "
+	}
+
+	switch len(lines) {
+	case 0:
+		return "update library"
+	case 1:
+		return prefix + lines[0]
+	default:
+		return fmt.Sprintf("%s%s (+%d more)\n\n%s\n", prefix, lines[0], len(lines)-1, strings.Join(lines, "\n"))
+	}
+}
+
+func describeChange(e JournalEntry) string {
+	switch e.Op {
+	case OpCategoryCreate, OpCategoryDelete:
+		return fmt.Sprintf("%s: %s", e.Op, e.Category)
+	}
+
+	b := e.After
+	if b == nil {
+		b = e.Before
+	}
+	if b == nil {
+		return fmt.Sprintf("%s: %s", e.Op, e.BookmarkID)
+	}
+	return fmt.Sprintf("%s: %s", e.Op, b.Title)
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tom-023/ubm/internal/testutil"
+)
+
+type recordingCommitter struct {
+	paths    []string
+	messages []string
+}
+
+func (c *recordingCommitter) Commit(path, message string) error {
+	c.paths = append(c.paths, path)
+	c.messages = append(c.messages, message)
+	return nil
+}
+
+func TestStorage_CommitsEverySave(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	committer := &recordingCommitter{}
+	opts := DefaultOptions()
+	opts.Committer = committer
+	s, err := NewWithOptions(dir, opts)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	b := testutil.CreateTestBookmark("Go", "https://go.dev", "programming")
+	if err := s.AddBookmark(b); err != nil {
+		t.Fatalf("AddBookmark() error = %v", err)
+	}
+
+	other := testutil.CreateTestBookmark("Rust", "https://rust-lang.org", "programming")
+	err = s.Update(func(data *Data) error {
+		if err := data.AddBookmark(other); err != nil {
+			return err
+		}
+		return data.DeleteBookmark(b.ID)
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if len(committer.messages) != 2 {
+		t.Fatalf("Committed %d times, want 2: %q", len(committer.messages), committer.messages)
+	}
+	if committer.paths[0] != s.Path() {
+		t.Errorf("Committed %s, want %s", committer.paths[0], s.Path())
+	}
+	if committer.messages[0] != "add: Go" {
+		t.Errorf("First message = %q, want %q", committer.messages[0], "add: Go")
+	}
+	subject, body, _ := strings.Cut(committer.messages[1], "\n\n")
+	if subject != "add: Rust (+1 more)" {
+		t.Errorf("Second subject = %q, want %q", subject, "add: Rust (+1 more)")
+	}
+	if !strings.Contains(body, "delete: Go") {
+		t.Errorf("Second body = %q, want it to list the deletion", body)
+	}
+}
+
+func TestCommitMessage(t *testing.T) {
+	b := testutil.CreateTestBookmark("Go", "https://go.dev", "programming")
+
+	tests := []struct {
+		name    string
+		changes []JournalEntry
+		meta    txMeta
+		want    string
+	}{
+		{"no changes", nil, txMeta{}, "update library"},
+		{"category of the bookmark is implied", []JournalEntry{
+			{Op: OpCategoryCreate, Category: "programming"},
+			{Op: OpAdd, BookmarkID: b.ID, After: b},
+		}, txMeta{}, "add: Go"},
+		{"other categories are listed", []JournalEntry{
+			{Op: OpCategoryCreate, Category: "tools"},
+			{Op: OpAdd, BookmarkID: b.ID, After: b},
+		}, txMeta{}, "add: Go (+1 more)\n\nadd: Go\ncategory_create: tools\n"},
+		{"move", []JournalEntry{{Op: OpMove, BookmarkID: b.ID, Before: b, After: b}}, txMeta{}, "move: Go"},
+		{"undo", []JournalEntry{{Op: OpTrash, BookmarkID: b.ID, Before: b}}, txMeta{undoes: "tx"}, "undo trash: Go"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := commitMessage(tt.changes, tt.meta); got != tt.want {
+				t.Errorf("commitMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
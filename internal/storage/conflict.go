@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrConflict is matched by errors.Is when a write was based on an outdated
+// revision of the library
+var ErrConflict = errors.New("library was changed by someone else")
+
+// ConflictError is returned when data loaded at Base is written back after
+// another process saved the library. Nothing is written; the caller decides
+// whether to reapply its change on top of Current, merge, or give up.
+type ConflictError struct {
+	// Base is the UpdatedAt of the revision the write was based on
+	Base time.Time
+	// Current is the library as it is now
+	Current *Data
+	// Changes describes what happened since Base, as far as it is known
+	Changes []JournalEntry
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("library was changed at %s, after it was loaded at %s",
+		e.Current.UpdatedAt.Local().Format("2006-01-02 15:04:05"), e.Base.Local().Format("2006-01-02 15:04:05"))
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// checkRevision returns a *ConflictError if data is based on a revision older
+// than current, the library as saved. A zero UpdatedAt means data was not
+// loaded from the library, e.g. a replacement built from scratch, and a nil
+// current means the saved library is unreadable; neither can conflict.
+func checkRevision(data, current *Data, journal *Journal) error {
+	if data.UpdatedAt.IsZero() || current == nil || !current.UpdatedAt.After(data.UpdatedAt) {
+		return nil
+	}
+
+	conflict := &ConflictError{Base: data.UpdatedAt, Current: current}
+	if journal != nil {
+		// Best effort: the conflict is reported whether or not the history can be read
+		entries, _ := journal.Entries(JournalFilter{Since: data.UpdatedAt})
+		for _, e := range entries {
+			if e.Time.After(data.UpdatedAt) {
+				conflict.Changes = append(conflict.Changes, e)
+			}
+		}
+	}
+	return conflict
+}
+
+// UpdateFrom is Update for a change prepared against base, e.g. while an
+// interactive command was waiting for input. If the library was changed since
+// base was loaded, fn is not run and a *ConflictError listing the other
+// changes is returned instead.
+func UpdateFrom(b Backend, base *Data, fn func(*Data) error) error {
+	return b.Update(func(current *Data) error {
+		if current.UpdatedAt.Equal(base.UpdatedAt) {
+			return fn(current)
+		}
+
+		// A library that was never saved gets a new UpdatedAt on every load
+		changes := diffData(base, current)
+		if len(changes) == 0 {
+			return fn(current)
+		}
+		for i := range changes {
+			changes[i].Time = current.UpdatedAt
+		}
+		return &ConflictError{Base: base.UpdatedAt, Current: current.Clone(), Changes: changes}
+	})
+}
@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tom-023/ubm/internal/bookmark"
+)
+
+// ErrDuplicate is matched by errors.Is when a bookmark with the same URL is
+// already in the same category
+var ErrDuplicate = errors.New("bookmark already exists")
+
+// DuplicateError is returned when adding a bookmark whose URL is already in
+// its category
+type DuplicateError struct {
+	URL      string
+	Category string
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("bookmark with URL %s already exists in category %s", e.URL, e.Category)
+}
+
+func (e *DuplicateError) Is(target error) bool {
+	return target == ErrDuplicate
+}
+
+// FindBookmark returns the bookmark with the given ID, or nil if there is none
+func (d *Data) FindBookmark(id string) *bookmark.Bookmark {
+	for _, b := range d.Bookmarks {
+		if b.ID == id {
+			return b
+		}
+	}
+	return nil
+}
+
+// GetBookmark is like FindBookmark but reports a missing bookmark as an error
+func (d *Data) GetBookmark(id string) (*bookmark.Bookmark, error) {
+	if b := d.FindBookmark(id); b != nil {
+		return b, nil
+	}
+	return nil, fmt.Errorf("bookmark with ID %s not found", id)
+}
+
+// BookmarksByCategory returns the bookmarks directly in the given category
+func (d *Data) BookmarksByCategory(category string) []*bookmark.Bookmark {
+	bookmarks := []*bookmark.Bookmark{}
+	for _, b := range d.Bookmarks {
+		if b.Category == category {
+			bookmarks = append(bookmarks, b)
+		}
+	}
+	return bookmarks
+}
+
+// Search returns bookmarks whose title, URL, description or keyword contains
+// the query, ignoring case
+func (d *Data) Search(query string) []*bookmark.Bookmark {
+	bookmarks := []*bookmark.Bookmark{}
+	for _, b := range d.Bookmarks {
+		if matchesQuery(b, query) {
+			bookmarks = append(bookmarks, b)
+		}
+	}
+	return bookmarks
+}
+
+func matchesQuery(b *bookmark.Bookmark, query string) bool {
+	return containsIgnoreCase(b.Title, query) || containsIgnoreCase(b.URL, query) || containsIgnoreCase(b.Description, query) ||
+		containsIgnoreCase(b.Keyword, query)
+}
+
+// AddBookmark appends a bookmark, registering its category if needed.
+// A bookmark with the same URL in the same category is rejected.
+func (d *Data) AddBookmark(b *bookmark.Bookmark) error {
+	// Check for duplicate URL in the same category
+	for _, existing := range d.Bookmarks {
+		if existing.URL == b.URL && existing.Category == b.Category {
+			return &DuplicateError{URL: b.URL, Category: b.Category}
+		}
+	}
+
+	d.Bookmarks = append(d.Bookmarks, b)
+	d.AddCategory(b.Category)
+
+	return nil
+}
+
+// UpdateBookmark replaces the bookmark that has the same ID as b
+func (d *Data) UpdateBookmark(b *bookmark.Bookmark) error {
+	for i, existing := range d.Bookmarks {
+		if existing.ID == b.ID {
+			d.Bookmarks[i] = b
+			return nil
+		}
+	}
+
+	return fmt.Errorf("bookmark with ID %s not found", b.ID)
+}
+
+// DeleteBookmark removes the bookmark with the given ID for good. Use
+// TrashBookmark for a delete that can be restored.
+func (d *Data) DeleteBookmark(id string) error {
+	bookmarks := []*bookmark.Bookmark{}
+	found := false
+	for _, b := range d.Bookmarks {
+		if b.ID != id {
+			bookmarks = append(bookmarks, b)
+		} else {
+			found = true
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("bookmark with ID %s not found", id)
+	}
+
+	d.Bookmarks = bookmarks
+	return nil
+}
+
+// HasCategory reports whether the category is registered
+func (d *Data) HasCategory(category string) bool {
+	for _, cat := range d.Categories {
+		if cat == category {
+			return true
+		}
+	}
+	return false
+}
+
+// AddCategory registers a category if it doesn't exist yet
+func (d *Data) AddCategory(category string) {
+	if category == "" || d.HasCategory(category) {
+		return
+	}
+	d.Categories = append(d.Categories, category)
+}
+
+// Clone returns a deep copy that shares no bookmarks or slices with d
+func (d *Data) Clone() *Data {
+	clone := &Data{
+		SchemaVersion: d.SchemaVersion,
+		Bookmarks:     make([]*bookmark.Bookmark, len(d.Bookmarks)),
+		Categories:    append([]string{}, d.Categories...),
+		Trash:         make([]*TrashedBookmark, len(d.Trash)),
+		UpdatedAt:     d.UpdatedAt,
+	}
+	for i, b := range d.Bookmarks {
+		clone.Bookmarks[i] = cloneBookmark(b)
+	}
+	for i, t := range d.Trash {
+		clone.Trash[i] = &TrashedBookmark{Bookmark: cloneBookmark(t.Bookmark), DeletedAt: t.DeletedAt}
+	}
+	return clone
+}
+
+func cloneBookmark(b *bookmark.Bookmark) *bookmark.Bookmark {
+	c := *b
+	if b.Tags != nil {
+		c.Tags = append([]string{}, b.Tags...)
+	}
+	return &c
+}
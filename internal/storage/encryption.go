@@ -0,0 +1,429 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Encrypted files start with this magic, followed by a format version, the
+// key kind, the key derivation parameters, a nonce, and the AES-256-GCM
+// ciphertext. Everything before the nonce is authenticated as associated data.
+var encryptionMagic = []byte("UBMENC")
+
+const (
+	encryptionVersion = 1
+	keySize           = 32
+	saltSize          = 16
+
+	// AES-GCM's standard nonce and tag sizes, as used by cipher.NewGCM
+	nonceSize = 12
+	tagSize   = 16
+
+	// argon2id parameters for new files, following RFC 9106's second
+	// recommended option. They are stored per file so they can be raised later.
+	argonTime    = 3
+	argonMemory  = 64 * 1024
+	argonThreads = 4
+
+	// Files carrying parameters beyond these are refused before deriving a
+	// key: the header is only authenticated once a key exists, so a damaged
+	// or crafted file must not be able to ask for unbounded time or memory.
+	maxArgonTime   = 64
+	maxArgonMemory = 1024 * 1024 // 1 GiB in KiB
+)
+
+type keyKind byte
+
+const (
+	keyKindPassphrase keyKind = 1
+	keyKindFile       keyKind = 2
+)
+
+func (k keyKind) String() string {
+	if k == keyKindFile {
+		return "a key file"
+	}
+	return "a passphrase"
+}
+
+var (
+	// ErrEncrypted is returned when an encrypted library is opened without a key
+	ErrEncrypted = errors.New("library is encrypted; configure a passphrase or key file")
+	// ErrDecrypt means the key is wrong or the file was tampered with
+	ErrDecrypt = errors.New("failed to decrypt: wrong passphrase or key, or the file was modified")
+	// ErrNotEncrypted is returned for a plain file where encryption is
+	// configured; ubm never writes one, so it was replaced from outside
+	ErrNotEncrypted = errors.New("file is stored in plain text although encryption is configured")
+
+	// errEnvelope marks a file whose envelope is damaged, as opposed to one
+	// that is intact but does not decrypt with the key
+	errEnvelope = errors.New("damaged encrypted file")
+)
+
+// Encryption seals the library and every file derived from it. Keys come
+// either from a passphrase through argon2id or from a key file.
+type Encryption struct {
+	kind keyKind
+
+	// key is the key file content for keyKindFile
+	key []byte
+
+	// getPassphrase is called at most once, the first time a key is needed
+	getPassphrase func() ([]byte, error)
+
+	mu         sync.Mutex
+	passphrase []byte
+	// The last derived key is reused for sealing so a save does not pay for
+	// another argon2 run; rekeying creates a new Encryption and a new salt.
+	params  []byte
+	derived []byte
+}
+
+// NewPassphraseEncryption derives keys from a passphrase. get is only called
+// when the library is actually read or written, so commands that don't touch
+// it never prompt.
+func NewPassphraseEncryption(get func() ([]byte, error)) *Encryption {
+	return &Encryption{kind: keyKindPassphrase, getPassphrase: get}
+}
+
+// NewKeyFileEncryption uses the base64-encoded 256-bit key in path
+func NewKeyFileEncryption(path string) (*Encryption, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil || len(key) != keySize {
+		return nil, fmt.Errorf("key file %s must contain a base64-encoded %d-byte key", path, keySize)
+	}
+	return &Encryption{kind: keyKindFile, key: key}, nil
+}
+
+// GenerateKeyFile writes a new random key to path, readable only by the owner.
+// It never overwrites an existing file.
+func GenerateKeyFile(path string) error {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create key file: %w", err)
+	}
+	if _, err := f.WriteString(base64.StdEncoding.EncodeToString(key) + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// isEncrypted reports whether raw is an encrypted envelope
+func isEncrypted(raw []byte) bool {
+	return bytes.HasPrefix(raw, encryptionMagic)
+}
+
+func (e *Encryption) seal(plaintext []byte) ([]byte, error) {
+	header, key, err := e.sealingKey()
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := append([]byte{}, header...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plaintext, header), nil
+}
+
+func (e *Encryption) open(raw []byte) ([]byte, error) {
+	header, rest, err := splitHeader(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	kind := keyKind(header[len(encryptionMagic)+1])
+	if kind != e.kind {
+		return nil, fmt.Errorf("library is encrypted with %s, but %s is configured", kind, e.kind)
+	}
+
+	key, err := e.openingKey(header)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, rest[:nonceSize], rest[nonceSize:], header)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// splitHeader separates the authenticated header from nonce and ciphertext.
+// A file too short to hold them or of an unknown version is reported as
+// errEnvelope; this needs no key.
+func splitHeader(raw []byte) (header, rest []byte, err error) {
+	prefix := len(encryptionMagic) + 2
+	if !isEncrypted(raw) || len(raw) < prefix {
+		return nil, nil, fmt.Errorf("%w: not an encrypted file", errEnvelope)
+	}
+	if raw[len(encryptionMagic)] != encryptionVersion {
+		return nil, nil, fmt.Errorf("%w: unsupported encryption version %d", errEnvelope, raw[len(encryptionMagic)])
+	}
+
+	size := prefix
+	switch keyKind(raw[len(encryptionMagic)+1]) {
+	case keyKindPassphrase:
+		size += saltSize + 4 + 4 + 1
+	case keyKindFile:
+	default:
+		return nil, nil, fmt.Errorf("%w: unknown key kind %d", errEnvelope, raw[len(encryptionMagic)+1])
+	}
+	if len(raw) < size+nonceSize+tagSize {
+		return nil, nil, fmt.Errorf("%w: file is truncated", errEnvelope)
+	}
+	if size > prefix && !validArgonParams(raw[prefix:size]) {
+		return nil, nil, ErrDecrypt
+	}
+	return raw[:size], raw[size:], nil
+}
+
+// validArgonParams reports whether the cost parameters stored after the salt
+// are within the bounds argon2 accepts and ubm is willing to spend
+func validArgonParams(params []byte) bool {
+	time := binary.BigEndian.Uint32(params[saltSize:])
+	memory := binary.BigEndian.Uint32(params[saltSize+4:])
+	threads := params[saltSize+8]
+	return time >= 1 && time <= maxArgonTime &&
+		threads >= 1 &&
+		memory >= 8*uint32(threads) && memory <= maxArgonMemory
+}
+
+func (e *Encryption) sealingKey() ([]byte, []byte, error) {
+	prefix := append(append([]byte{}, encryptionMagic...), encryptionVersion, byte(e.kind))
+	if e.kind == keyKindFile {
+		return prefix, e.key, nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.derived == nil {
+		salt := make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, nil, err
+		}
+		params := make([]byte, 0, saltSize+9)
+		params = append(params, salt...)
+		params = binary.BigEndian.AppendUint32(params, argonTime)
+		params = binary.BigEndian.AppendUint32(params, argonMemory)
+		params = append(params, argonThreads)
+
+		key, err := e.derive(params)
+		if err != nil {
+			return nil, nil, err
+		}
+		e.params, e.derived = params, key
+	}
+	return append(prefix, e.params...), e.derived, nil
+}
+
+func (e *Encryption) openingKey(header []byte) ([]byte, error) {
+	if e.kind == keyKindFile {
+		return e.key, nil
+	}
+
+	params := header[len(encryptionMagic)+2:]
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.derived != nil && bytes.Equal(params, e.params) {
+		return e.derived, nil
+	}
+	key, err := e.derive(params)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep sealing with the file's own salt so the next save is cheap
+	e.params, e.derived = append([]byte{}, params...), key
+	return key, nil
+}
+
+// derive runs argon2id with the salt and cost parameters in params. The
+// caller holds e.mu.
+func (e *Encryption) derive(params []byte) ([]byte, error) {
+	if e.passphrase == nil {
+		passphrase, err := e.getPassphrase()
+		if err != nil {
+			return nil, err
+		}
+		if len(passphrase) == 0 {
+			return nil, fmt.Errorf("passphrase must not be empty")
+		}
+		e.passphrase = passphrase
+	}
+
+	salt := params[:saltSize]
+	time := binary.BigEndian.Uint32(params[saltSize:])
+	memory := binary.BigEndian.Uint32(params[saltSize+4:])
+	threads := params[saltSize+8]
+	return argon2.IDKey(e.passphrase, salt, time, memory, threads, keySize), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// decryptFile returns the plaintext of a file read from disk. Once encryption
+// is configured plain files are refused, since anyone able to write the file
+// could otherwise bypass it; Reencrypt is the only way to convert them.
+func decryptFile(enc *Encryption, raw []byte) ([]byte, error) {
+	if !isEncrypted(raw) {
+		if enc != nil {
+			return nil, ErrNotEncrypted
+		}
+		return raw, nil
+	}
+	if enc == nil {
+		return nil, ErrEncrypted
+	}
+	return enc.open(raw)
+}
+
+// encryptFile seals plaintext if encryption is configured
+func encryptFile(enc *Encryption, plaintext []byte) ([]byte, error) {
+	if enc == nil {
+		return plaintext, nil
+	}
+	return enc.seal(plaintext)
+}
+
+// fileMode keeps encrypted files private as well; a passphrase is only as
+// strong as the attacker's inability to run offline guesses against it
+func fileMode(enc *Encryption) os.FileMode {
+	if enc != nil {
+		return 0600
+	}
+	return 0644
+}
+
+// Reencrypt rewrites the library, its backups, and the journal with enc, or
+// in plain text if enc is nil. Files are rewritten one at a time, the library
+// last; a file that already opens with enc is left alone, so an interrupted
+// run can simply be repeated.
+func (s *Storage) Reencrypt(enc *Encryption) error {
+	return s.withLock(true, func() error {
+		reseal := func(raw []byte) ([]byte, error) {
+			// Plain files are expected when encrypting, and when decrypting
+			// once an interrupted run has already converted them
+			if !isEncrypted(raw) && (s.enc == nil || enc == nil) {
+				return encryptFile(enc, raw)
+			}
+
+			plaintext, err := decryptFile(s.enc, raw)
+			if err != nil {
+				if enc != nil && isEncrypted(raw) {
+					if _, newErr := enc.open(raw); newErr == nil {
+						return raw, nil
+					}
+				}
+				return nil, err
+			}
+			return encryptFile(enc, plaintext)
+		}
+
+		entries, err := os.ReadDir(s.backupDir)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read backup directory: %w", err)
+		}
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() {
+				continue
+			}
+			path := filepath.Join(s.backupDir, entry.Name())
+			if err := rewriteFile(path, fileMode(enc), reseal); err != nil {
+				return fmt.Errorf("failed to rewrite backup %s: %w", entry.Name(), err)
+			}
+		}
+
+		if err := s.journal.reencrypt(enc); err != nil {
+			return err
+		}
+
+		// UpdatedAt is left alone: the content has not changed
+		if err := rewriteFile(s.filePath, fileMode(enc), reseal); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rewrite library: %w", err)
+		}
+
+		s.enc = enc
+		return nil
+	})
+}
+
+// SealedAsLibrary reports whether raw, a copy of the library such as one
+// kept on a sync server, is stored the way the library is now: in plain text
+// if it is not encrypted, or sealed with its current key if it is. A copy
+// that is not needs to be replaced after Reencrypt.
+func (s *Storage) SealedAsLibrary(raw []byte) bool {
+	if s.enc == nil {
+		return !isEncrypted(raw)
+	}
+	if !isEncrypted(raw) {
+		return false
+	}
+	_, err := s.enc.open(raw)
+	return err == nil
+}
+
+// rewriteFile atomically replaces path with fn applied to its content
+func rewriteFile(path string, mode os.FileMode, fn func([]byte) ([]byte, error)) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	out, err := fn(raw)
+	if err != nil {
+		return err
+	}
+
+	tmpFile := path + ".tmp"
+	if err := os.WriteFile(tmpFile, out, mode); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of an existing file
+	if err := os.Chmod(tmpFile, mode); err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+	return os.Rename(tmpFile, path)
+}
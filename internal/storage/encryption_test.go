@@ -0,0 +1,301 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tom-023/ubm/internal/testutil"
+)
+
+func passphrase(p string) *Encryption {
+	return NewPassphraseEncryption(func() ([]byte, error) {
+		return []byte(p), nil
+	})
+}
+
+func newEncryptedStorage(t *testing.T, dir string, enc *Encryption) *Storage {
+	t.Helper()
+	s, err := NewWithOptions(dir, Options{AutoBackup: true, MaxBackups: 5, Encryption: enc})
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	return s
+}
+
+// storedFiles returns every file ubm keeps for the library in dir
+func storedFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files := []string{}
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && filepath.Ext(path) != ".lock" {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to list %s: %v", dir, err)
+	}
+	return files
+}
+
+// assertNoPlaintext fails if secret appears in any stored file
+func assertNoPlaintext(t *testing.T, dir, secret string) {
+	t.Helper()
+	for _, path := range storedFiles(t, dir) {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", path, err)
+		}
+		if bytes.Contains(raw, []byte(secret)) {
+			t.Errorf("%s contains %q in plain text", filepath.Base(path), secret)
+		}
+	}
+}
+
+func TestEncryption_RoundTrip(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s := newEncryptedStorage(t, dir, passphrase("correct horse"))
+	b := testutil.CreateTestBookmark("Secret", "https://secret.example.com", "private")
+	if err := s.AddBookmark(b); err != nil {
+		t.Fatalf("AddBookmark() error = %v", err)
+	}
+	s.AddBookmark(testutil.CreateTestBookmark("Other", "https://other.example.com", "private"))
+
+	raw, _ := os.ReadFile(s.Path())
+	if !isEncrypted(raw) {
+		t.Fatal("Library should be encrypted")
+	}
+	if backups, _ := s.ListBackups(); len(backups) == 0 {
+		t.Fatal("Expected a backup generation")
+	}
+	assertNoPlaintext(t, dir, "secret.example.com")
+
+	for _, path := range storedFiles(t, dir) {
+		info, _ := os.Stat(path)
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("%s mode = %v, want 0600", filepath.Base(path), info.Mode().Perm())
+		}
+	}
+
+	// A separate run with the same passphrase reads everything back
+	reopened := newEncryptedStorage(t, dir, passphrase("correct horse"))
+	got, err := reopened.GetBookmark(b.ID)
+	if err != nil || got.URL != b.URL {
+		t.Fatalf("GetBookmark() = %+v, %v", got, err)
+	}
+	if _, err := reopened.LoadBackup(1); err != nil {
+		t.Errorf("LoadBackup() error = %v", err)
+	}
+	entries, err := reopened.Journal().Entries(JournalFilter{})
+	if err != nil {
+		t.Fatalf("Entries() error = %v", err)
+	}
+	if len(entries) != 3 || entries[1].After.URL != b.URL {
+		t.Errorf("Journal entries = %+v", entries)
+	}
+}
+
+func TestEncryption_WrongKey(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s := newEncryptedStorage(t, dir, passphrase("correct horse"))
+	s.AddBookmark(testutil.CreateTestBookmark("Secret", "https://secret.example.com", ""))
+
+	if _, err := newEncryptedStorage(t, dir, passphrase("wrong")).Load(); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Load() with wrong passphrase error = %v, want ErrDecrypt", err)
+	}
+	if _, err := newEncryptedStorage(t, dir, nil).Load(); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("Load() without key error = %v, want ErrEncrypted", err)
+	}
+	if _, err := newEncryptedStorage(t, dir, nil).Journal().Entries(JournalFilter{}); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("Entries() without key error = %v, want ErrEncrypted", err)
+	}
+
+	keyPath := filepath.Join(dir, "ubm.key")
+	if err := GenerateKeyFile(keyPath); err != nil {
+		t.Fatalf("GenerateKeyFile() error = %v", err)
+	}
+	enc, err := NewKeyFileEncryption(keyPath)
+	if err != nil {
+		t.Fatalf("NewKeyFileEncryption() error = %v", err)
+	}
+	if _, err := newEncryptedStorage(t, dir, enc).Load(); err == nil {
+		t.Error("Load() with a key file should fail for a passphrase-encrypted library")
+	}
+
+	// Saving with the wrong key must not replace the library
+	wrong := newEncryptedStorage(t, dir, passphrase("wrong"))
+	if err := wrong.Save(&Data{}); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Save() with wrong passphrase error = %v, want ErrDecrypt", err)
+	}
+	if data, err := s.Load(); err != nil || len(data.Bookmarks) != 1 {
+		t.Errorf("Library was changed by a failed save: %v", err)
+	}
+}
+
+func TestEncryption_DetectsTampering(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s := newEncryptedStorage(t, dir, passphrase("correct horse"))
+	s.AddBookmark(testutil.CreateTestBookmark("Secret", "https://secret.example.com", ""))
+
+	raw, _ := os.ReadFile(s.Path())
+	for _, offset := range []int{len(encryptionMagic) + 3, len(raw) - 1} {
+		tampered := append([]byte{}, raw...)
+		tampered[offset] ^= 0x01
+		if err := os.WriteFile(s.Path(), tampered, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Load(); !errors.Is(err, ErrDecrypt) {
+			t.Errorf("Load() with byte %d flipped error = %v, want ErrDecrypt", offset, err)
+		}
+	}
+}
+
+func TestEncryption_RejectsBadParameters(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s := newEncryptedStorage(t, dir, passphrase("correct horse"))
+	s.AddBookmark(testutil.CreateTestBookmark("Secret", "https://secret.example.com", ""))
+
+	raw, _ := os.ReadFile(s.Path())
+	params := len(encryptionMagic) + 2 + saltSize
+	tests := []struct {
+		name   string
+		offset int
+		value  []byte
+	}{
+		{"zero time", params, []byte{0, 0, 0, 0}},
+		{"huge time", params, []byte{0xff, 0xff, 0xff, 0xff}},
+		{"huge memory", params + 4, []byte{0xff, 0xff, 0xff, 0xff}},
+		{"too little memory", params + 4, []byte{0, 0, 0, 1}},
+		{"zero threads", params + 8, []byte{0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered := append([]byte{}, raw...)
+			copy(tampered[tt.offset:], tt.value)
+			if err := os.WriteFile(s.Path(), tampered, 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := newEncryptedStorage(t, dir, passphrase("correct horse")).Load(); !errors.Is(err, ErrDecrypt) {
+				t.Errorf("Load() error = %v, want ErrDecrypt", err)
+			}
+		})
+	}
+}
+
+func TestEncryption_RejectsPlaintext(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s := newEncryptedStorage(t, dir, passphrase("correct horse"))
+	s.AddBookmark(testutil.CreateTestBookmark("Secret", "https://secret.example.com", ""))
+
+	// A plain library put in place of the encrypted one
+	plain := newEncryptedStorage(t, t.TempDir(), nil)
+	plain.AddBookmark(testutil.CreateTestBookmark("Planted", "https://planted.example.com", ""))
+	raw, _ := os.ReadFile(plain.Path())
+	if err := os.WriteFile(s.Path(), raw, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Load(); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("Load() error = %v, want ErrNotEncrypted", err)
+	}
+	if err := s.Save(&Data{}); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("Save() error = %v, want ErrNotEncrypted", err)
+	}
+	if _, err := s.Decode(raw); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("Decode() error = %v, want ErrNotEncrypted", err)
+	}
+
+	// The same goes for plain lines in the history
+	journal, _ := os.ReadFile(plain.Journal().Path())
+	f, err := os.OpenFile(s.Journal().Path(), os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(journal)
+	f.Close()
+	if _, err := s.Journal().Entries(JournalFilter{}); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("Entries() error = %v, want ErrNotEncrypted", err)
+	}
+}
+
+func TestStorage_Reencrypt(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	plain := newEncryptedStorage(t, dir, nil)
+	b := testutil.CreateTestBookmark("Secret", "https://secret.example.com", "private")
+	plain.AddBookmark(b)
+	plain.AddBookmark(testutil.CreateTestBookmark("Other", "https://other.example.com", ""))
+	before, _ := plain.Load()
+
+	// Encrypt an existing plain library, including backups and history
+	enc := passphrase("correct horse")
+	if err := plain.Reencrypt(enc); err != nil {
+		t.Fatalf("Reencrypt() error = %v", err)
+	}
+	assertNoPlaintext(t, dir, "secret.example.com")
+
+	s := newEncryptedStorage(t, dir, passphrase("correct horse"))
+	data, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() after encrypt error = %v", err)
+	}
+	if !data.UpdatedAt.Equal(before.UpdatedAt) {
+		t.Errorf("UpdatedAt changed from %v to %v", before.UpdatedAt, data.UpdatedAt)
+	}
+
+	// Rekey to a key file
+	keyPath := filepath.Join(t.TempDir(), "ubm.key")
+	GenerateKeyFile(keyPath)
+	keyEnc, _ := NewKeyFileEncryption(keyPath)
+	if err := s.Reencrypt(keyEnc); err != nil {
+		t.Fatalf("Reencrypt() rekey error = %v", err)
+	}
+	if _, err := newEncryptedStorage(t, dir, passphrase("correct horse")).Load(); err == nil {
+		t.Error("Old passphrase should no longer open the library")
+	}
+
+	rekeyed := newEncryptedStorage(t, dir, keyEnc)
+	if _, err := rekeyed.LoadBackup(1); err != nil {
+		t.Errorf("LoadBackup() after rekey error = %v", err)
+	}
+	if entries, err := rekeyed.Journal().Entries(JournalFilter{}); err != nil || len(entries) != 3 {
+		t.Errorf("Entries() after rekey = %d, %v", len(entries), err)
+	}
+
+	// Decrypt everything again, after an interrupted run got as far as the
+	// library
+	if err := rewriteFile(rekeyed.Path(), 0644, keyEnc.open); err != nil {
+		t.Fatal(err)
+	}
+	if err := rekeyed.Reencrypt(nil); err != nil {
+		t.Fatalf("Reencrypt(nil) error = %v", err)
+	}
+	for _, path := range storedFiles(t, dir) {
+		raw, _ := os.ReadFile(path)
+		if bytes.Contains(raw, encryptionMagic) {
+			t.Errorf("%s is still encrypted", filepath.Base(path))
+		}
+	}
+	got, err := newEncryptedStorage(t, dir, nil).GetBookmark(b.ID)
+	if err != nil || got.URL != b.URL {
+		t.Errorf("GetBookmark() after decrypt = %+v, %v", got, err)
+	}
+}
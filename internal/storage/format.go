@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the on-disk encoding of the bookmark file
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+)
+
+// ParseFormat validates a format name as used in config.yaml and on the command line
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "json":
+		return FormatJSON, nil
+	case "yaml", "yml":
+		return FormatYAML, nil
+	default:
+		return "", fmt.Errorf("unsupported storage format %q (expected json or yaml)", name)
+	}
+}
+
+func (f Format) fileName() string {
+	return "bookmarks." + string(f)
+}
+
+func (f Format) marshal(v interface{}) ([]byte, error) {
+	switch f {
+	case FormatYAML:
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(v); err != nil {
+			return nil, err
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return json.MarshalIndent(v, "", "  ")
+	}
+}
+
+func (f Format) unmarshal(raw []byte, v interface{}) error {
+	switch f {
+	case FormatYAML:
+		return yaml.Unmarshal(raw, v)
+	default:
+		return json.Unmarshal(raw, v)
+	}
+}
+
+// detectFormat sniffs the encoding from the content rather than trusting the
+// file name: a JSON document always starts with '{'.
+func detectFormat(raw []byte) Format {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return FormatJSON
+	}
+	return FormatYAML
+}
+
+// Convert rewrites the library in another format without changing its
+// content. The old file is kept as a backup generation when auto backup is on.
+func (s *Storage) Convert(to Format) error {
+	return s.withLock(true, func() error {
+		if to == s.format {
+			return fmt.Errorf("library is already stored as %s", to)
+		}
+
+		data, err := s.load()
+		if err != nil {
+			return err
+		}
+
+		oldPath := s.filePath
+		_, statErr := os.Stat(oldPath)
+		exists := statErr == nil
+
+		if exists && s.autoBackup {
+			if err := s.createBackup(); err != nil {
+				return fmt.Errorf("failed to create backup: %w", err)
+			}
+		}
+
+		oldFormat := s.format
+		s.format = to
+		s.filePath = filepath.Join(filepath.Dir(oldPath), to.fileName())
+
+		// UpdatedAt is left alone: the content has not changed
+		data.SchemaVersion = CurrentSchemaVersion
+		if err := s.writeFile(data); err != nil {
+			s.format = oldFormat
+			s.filePath = oldPath
+			return err
+		}
+
+		if exists {
+			if err := os.Remove(oldPath); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", filepath.Base(oldPath), err)
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tom-023/ubm/internal/testutil"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Format
+		wantErr bool
+	}{
+		{"", FormatJSON, false},
+		{"json", FormatJSON, false},
+		{"YAML", FormatYAML, false},
+		{"yml", FormatYAML, false},
+		{"toml", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFormat(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFormat(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseFormat(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStorage_YAMLFormat(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	opts := DefaultOptions()
+	opts.Format = FormatYAML
+	s, err := NewWithOptions(dir, opts)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	b := testutil.CreateTestBookmark("Go", "https://go.dev", "programming/go")
+	b.Tags = []string{"lang", "docs"}
+	b.Description = "The Go website"
+	if err := s.AddBookmark(b); err != nil {
+		t.Fatalf("AddBookmark() error = %v", err)
+	}
+
+	if filepath.Base(s.filePath) != "bookmarks.yaml" {
+		t.Errorf("filePath = %v, want bookmarks.yaml", s.filePath)
+	}
+
+	raw, err := os.ReadFile(s.filePath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	// Keys follow struct order so diffs stay small and predictable
+	content := string(raw)
+	if !strings.HasPrefix(content, "schema_version: ") {
+		t.Errorf("YAML should start with schema_version, got:\n%s", content)
+	}
+	order := []string{"id:", "title:", "url:", "category:", "created_at:", "updated_at:", "tags:", "description:"}
+	last := -1
+	for _, key := range order {
+		idx := strings.Index(content, key)
+		if idx <= last {
+			t.Errorf("Key %s is out of order in:\n%s", key, content)
+		}
+		last = idx
+	}
+
+	loaded, err := s.GetBookmark(b.ID)
+	if err != nil {
+		t.Fatalf("GetBookmark() error = %v", err)
+	}
+	if loaded.Description != b.Description || len(loaded.Tags) != 2 || !loaded.CreatedAt.Equal(b.CreatedAt) {
+		t.Errorf("Loaded bookmark = %+v, want %+v", loaded, b)
+	}
+}
+
+func TestStorage_DetectsExistingFormat(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	yamlDoc := "schema_version: 2\nbookmarks:\n  - id: y1\n    title: From YAML\n    url: https://yaml.org\n    category: \"\"\ncategories: []\n"
+	if err := os.WriteFile(filepath.Join(dir, "bookmarks.yaml"), []byte(yamlDoc), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	// The preferred format only applies to new libraries
+	s, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if s.Format() != FormatYAML {
+		t.Errorf("Format() = %v, want yaml", s.Format())
+	}
+
+	data, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(data.Bookmarks) != 1 || data.Bookmarks[0].Title != "From YAML" {
+		t.Errorf("Unexpected bookmarks: %+v", data.Bookmarks)
+	}
+}
+
+func TestStorage_LoadMigratesLegacyYAML(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	// Written by hand, before schema_version existed
+	legacy := "bookmarks:\n  - id: y1\n    title: Legacy\n    url: https://yaml.org\n    category: docs\ncategories:\n  - docs\n"
+	if err := os.WriteFile(filepath.Join(dir, "bookmarks.yaml"), []byte(legacy), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	s, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	data, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(data.Bookmarks) != 1 || data.Bookmarks[0].Title != "Legacy" || data.Bookmarks[0].Tags == nil {
+		t.Errorf("Unexpected bookmarks: %+v", data.Bookmarks)
+	}
+	if data.SchemaVersion != CurrentSchemaVersion {
+		t.Errorf("SchemaVersion = %d, want %d", data.SchemaVersion, CurrentSchemaVersion)
+	}
+}
+
+func TestStorage_ConvertRoundTrip(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	bookmarks := testutil.CreateTestBookmarks()
+	bookmarks[0].Tags = []string{"go", "docs"}
+	bookmarks[1].Description = "multi\nline: description"
+	if err := s.Save(&Data{Bookmarks: bookmarks, Categories: testutil.SampleCategories()}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	original, _ := s.Load()
+	want, _ := json.Marshal(original)
+
+	if err := s.Convert(FormatYAML); err != nil {
+		t.Fatalf("Convert(yaml) error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "bookmarks.json")); !os.IsNotExist(err) {
+		t.Error("bookmarks.json should be removed after converting to YAML")
+	}
+
+	asYAML, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() after convert error = %v", err)
+	}
+	if got, _ := json.Marshal(asYAML); string(got) != string(want) {
+		t.Errorf("YAML conversion is lossy:\n got %s\nwant %s", got, want)
+	}
+
+	// A fresh Storage picks the converted file up
+	reopened, _ := New(dir)
+	if reopened.Format() != FormatYAML {
+		t.Errorf("Reopened Format() = %v, want yaml", reopened.Format())
+	}
+
+	if err := reopened.Convert(FormatJSON); err != nil {
+		t.Fatalf("Convert(json) error = %v", err)
+	}
+	back, err := reopened.Load()
+	if err != nil {
+		t.Fatalf("Load() after convert back error = %v", err)
+	}
+	if got, _ := json.Marshal(back); string(got) != string(want) {
+		t.Errorf("Round trip is lossy:\n got %s\nwant %s", got, want)
+	}
+
+	if err := reopened.Convert(FormatJSON); err == nil {
+		t.Error("Expected error when converting to the current format")
+	}
+}
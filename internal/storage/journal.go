@@ -0,0 +1,454 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tom-023/ubm/internal/bookmark"
+)
+
+const journalFileName = "journal.jsonl"
+
+// Operation is the kind of change recorded in the journal
+type Operation string
+
+const (
+	OpAdd     Operation = "add"
+	OpUpdate  Operation = "update"
+	OpMove    Operation = "move"
+	OpDelete  Operation = "delete"
+	OpTrash   Operation = "trash"
+	OpRestore Operation = "restore"
+	// OpPurge removes a bookmark from the trash for good (Before) or, when
+	// undone or copied in, puts it back into the trash (After)
+	OpPurge          Operation = "purge"
+	OpCategoryCreate Operation = "category_create"
+	OpCategoryDelete Operation = "category_delete"
+)
+
+// JournalEntry is one line of the journal. Entries written by the same save
+// share a Tx so they can be grouped back into a single operation.
+type JournalEntry struct {
+	Time       time.Time          `json:"time"`
+	Tx         string             `json:"tx"`
+	User       string             `json:"user,omitempty"`
+	Op         Operation          `json:"op"`
+	BookmarkID string             `json:"bookmark_id,omitempty"`
+	Category   string             `json:"category,omitempty"`
+	Before     *bookmark.Bookmark `json:"before,omitempty"`
+	After      *bookmark.Bookmark `json:"after,omitempty"`
+	// DeletedAt is when a trashed, restored, or purged bookmark was trashed
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Undoes and Redoes name the transaction an undo or redo reverted
+	Undoes string `json:"undoes,omitempty"`
+	Redoes string `json:"redoes,omitempty"`
+	// Automatic marks a change ubm made on its own, such as purging expired
+	// trash, which undo and redo skip
+	Automatic bool `json:"automatic,omitempty"`
+}
+
+// txMeta is stamped on every entry of a transaction
+type txMeta struct {
+	undoes    string
+	redoes    string
+	automatic bool
+}
+
+// JournalFilter narrows down the entries returned by Journal.Entries
+type JournalFilter struct {
+	Since      time.Time
+	BookmarkID string
+}
+
+func (f JournalFilter) match(e JournalEntry) bool {
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if f.BookmarkID != "" && e.BookmarkID != f.BookmarkID {
+		return false
+	}
+	return true
+}
+
+// Journal is an append-only JSON Lines log of every change to the library.
+// Callers append while holding the library lock, so entries from different
+// processes never interleave. With encryption each line is sealed on its own
+// and stored as base64, so appending never rewrites earlier lines.
+type Journal struct {
+	path string
+	enc  *Encryption
+}
+
+// Journaled is implemented by backends that record their changes
+type Journaled interface {
+	Journal() *Journal
+}
+
+func newJournal(dir string, enc *Encryption) *Journal {
+	return &Journal{path: filepath.Join(dir, journalFileName), enc: enc}
+}
+
+// Path returns the location of the journal file
+func (j *Journal) Path() string {
+	return j.path
+}
+
+// Record journals the changes between two versions of the library that were
+// made outside ubm, e.g. by pulling from git
+func (j *Journal) Record(before, after *Data) error {
+	return j.record(before, after, txMeta{})
+}
+
+// record appends the changes between two snapshots as one transaction
+func (j *Journal) record(before, after *Data, meta txMeta) error {
+	return j.append(diffData(before, after), meta)
+}
+
+func (j *Journal) append(entries []JournalEntry, meta txMeta) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	tx := uuid.New().String()
+	now := time.Now()
+	who := currentUser()
+
+	var buf []byte
+	for _, e := range entries {
+		e.Tx = tx
+		e.Time = now
+		e.User = who
+		e.Undoes = meta.undoes
+		e.Redoes = meta.redoes
+		e.Automatic = meta.automatic
+		line, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to encode journal entry: %w", err)
+		}
+		line, err = j.sealLine(line)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt journal entry: %w", err)
+		}
+		buf = append(buf, line...)
+		buf = append(buf, '\n')
+	}
+
+	f, err := os.OpenFile(j.path, os.O_APPEND|os.O_CREATE|os.O_RDWR, fileMode(j.enc))
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+
+	if err := trimTornTail(f); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to repair journal: %w", err)
+	}
+
+	// A single write keeps a transaction together even if we crash midway
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return f.Close()
+}
+
+// trimTornTail drops a partial last line left behind by an interrupted
+// append, so the next transaction starts on a line of its own
+func trimTornTail(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	end := info.Size()
+	buf := make([]byte, 4096)
+	for offset := end; offset > 0; {
+		n := int64(len(buf))
+		if offset < n {
+			n = offset
+		}
+		offset -= n
+		if _, err := f.ReadAt(buf[:n], offset); err != nil {
+			return err
+		}
+		for i := n - 1; i >= 0; i-- {
+			if buf[i] == '\n' {
+				if offset+i+1 == end {
+					return nil
+				}
+				return f.Truncate(offset + i + 1)
+			}
+		}
+	}
+	return f.Truncate(0)
+}
+
+// Entries returns the recorded entries that match filter, oldest first
+func (j *Journal) Entries(filter JournalFilter) ([]JournalEntry, error) {
+	f, err := os.Open(j.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []JournalEntry{}, nil
+		}
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer f.Close()
+
+	entries := []JournalEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	var pending error
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		// Only the last line may be torn by an interrupted write
+		if pending != nil {
+			return nil, pending
+		}
+
+		raw, err := j.openLine(scanner.Bytes())
+		if err != nil {
+			if errors.Is(err, ErrEncrypted) || errors.Is(err, ErrDecrypt) || errors.Is(err, ErrNotEncrypted) {
+				return nil, err
+			}
+			pending = fmt.Errorf("failed to decode journal line %d: %w", line, err)
+			continue
+		}
+
+		var e JournalEntry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			pending = fmt.Errorf("failed to decode journal line %d: %w", line, err)
+			continue
+		}
+		if filter.match(e) {
+			entries = append(entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	return entries, nil
+}
+
+// sealLine encrypts one encoded entry if the journal is encrypted
+func (j *Journal) sealLine(line []byte) ([]byte, error) {
+	if j.enc == nil {
+		return line, nil
+	}
+	sealed, err := j.enc.seal(line)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// openLine returns the JSON of one journal line. Plain lines always start
+// with '{', which never begins a base64-encoded envelope.
+func (j *Journal) openLine(line []byte) ([]byte, error) {
+	if len(line) > 0 && line[0] == '{' {
+		if j.enc != nil {
+			return nil, ErrNotEncrypted
+		}
+		return line, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(string(line))
+	if err != nil {
+		return nil, err
+	}
+	if !isEncrypted(sealed) {
+		return nil, fmt.Errorf("not a journal entry")
+	}
+	return decryptFile(j.enc, sealed)
+}
+
+// reencrypt rewrites every line with enc. A torn last line is dropped.
+func (j *Journal) reencrypt(enc *Encryption) error {
+	raw, err := os.ReadFile(j.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			j.enc = enc
+			return nil
+		}
+		return fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	next := &Journal{path: j.path, enc: enc}
+	lines := bytes.Split(raw, []byte("\n"))
+	var buf []byte
+	for i, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+
+		plain, err := j.openLine(line)
+		if err != nil {
+			// Already rewritten by an interrupted earlier run
+			if _, newErr := next.openLine(line); newErr == nil {
+				buf = append(append(buf, line...), '\n')
+				continue
+			}
+		}
+		if err != nil {
+			if i == len(lines)-1 {
+				break
+			}
+			return fmt.Errorf("failed to decode journal line %d: %w", i+1, err)
+		}
+
+		sealed, err := next.sealLine(plain)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt journal: %w", err)
+		}
+		buf = append(append(buf, sealed...), '\n')
+	}
+
+	if err := rewriteFile(j.path, fileMode(enc), func([]byte) ([]byte, error) { return buf, nil }); err != nil {
+		return fmt.Errorf("failed to rewrite journal: %w", err)
+	}
+	j.enc = enc
+	return nil
+}
+
+// Diff lists the changes that turn before into after, as they would be
+// recorded in the journal but without times or users, e.g. to preview a merge
+func Diff(before, after *Data) []JournalEntry {
+	return diffData(before, after)
+}
+
+// diffData describes how after differs from before as journal entries:
+// category creations first, then bookmark changes in library order, then
+// trash changes and category deletions.
+func diffData(before, after *Data) []JournalEntry {
+	var entries []JournalEntry
+
+	beforeCategories := make(map[string]bool, len(before.Categories))
+	for _, c := range before.Categories {
+		beforeCategories[c] = true
+	}
+	afterCategories := make(map[string]bool, len(after.Categories))
+	for _, c := range after.Categories {
+		afterCategories[c] = true
+		if !beforeCategories[c] {
+			entries = append(entries, JournalEntry{Op: OpCategoryCreate, Category: c})
+		}
+	}
+
+	beforeTrash := trashByID(before)
+	afterTrash := trashByID(after)
+
+	// With duplicate IDs, which doctor repairs, the first bookmark is the one
+	// the rest of ubm sees
+	beforeByID := make(map[string]*bookmark.Bookmark, len(before.Bookmarks))
+	for _, b := range before.Bookmarks {
+		if _, ok := beforeByID[b.ID]; !ok {
+			beforeByID[b.ID] = b
+		}
+	}
+	afterIDs := make(map[string]bool, len(after.Bookmarks))
+	for _, b := range after.Bookmarks {
+		afterIDs[b.ID] = true
+		old, existed := beforeByID[b.ID]
+		if !existed {
+			if t, wasTrashed := beforeTrash[b.ID]; wasTrashed && afterTrash[b.ID] == nil {
+				entries = append(entries, JournalEntry{Op: OpRestore, BookmarkID: b.ID, Category: b.Category, After: cloneBookmark(b), DeletedAt: timePtr(t.DeletedAt)})
+				continue
+			}
+			entries = append(entries, JournalEntry{Op: OpAdd, BookmarkID: b.ID, Category: b.Category, After: cloneBookmark(b)})
+			continue
+		}
+		if op, changed := bookmarkChange(old, b); changed {
+			entries = append(entries, JournalEntry{Op: op, BookmarkID: b.ID, Category: b.Category, Before: cloneBookmark(old), After: cloneBookmark(b)})
+		}
+	}
+	for _, b := range before.Bookmarks {
+		if afterIDs[b.ID] {
+			continue
+		}
+		if t, trashed := afterTrash[b.ID]; trashed && beforeTrash[b.ID] == nil {
+			entries = append(entries, JournalEntry{Op: OpTrash, BookmarkID: b.ID, Category: b.Category, Before: cloneBookmark(b), DeletedAt: timePtr(t.DeletedAt)})
+			continue
+		}
+		entries = append(entries, JournalEntry{Op: OpDelete, BookmarkID: b.ID, Category: b.Category, Before: cloneBookmark(b)})
+	}
+
+	for _, t := range before.Trash {
+		id := t.Bookmark.ID
+		if afterTrash[id] == nil && !afterIDs[id] {
+			entries = append(entries, JournalEntry{Op: OpPurge, BookmarkID: id, Category: t.Bookmark.Category, Before: cloneBookmark(t.Bookmark), DeletedAt: timePtr(t.DeletedAt)})
+		}
+	}
+	for _, t := range after.Trash {
+		id := t.Bookmark.ID
+		if beforeTrash[id] == nil && beforeByID[id] == nil {
+			entries = append(entries, JournalEntry{Op: OpPurge, BookmarkID: id, Category: t.Bookmark.Category, After: cloneBookmark(t.Bookmark), DeletedAt: timePtr(t.DeletedAt)})
+		}
+	}
+
+	var removed []string
+	for _, c := range before.Categories {
+		if !afterCategories[c] {
+			removed = append(removed, c)
+		}
+	}
+	sort.Strings(removed)
+	for _, c := range removed {
+		entries = append(entries, JournalEntry{Op: OpCategoryDelete, Category: c})
+	}
+
+	return entries
+}
+
+func trashByID(data *Data) map[string]*TrashedBookmark {
+	trash := make(map[string]*TrashedBookmark, len(data.Trash))
+	for _, t := range data.Trash {
+		trash[t.Bookmark.ID] = t
+	}
+	return trash
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+// bookmarkChange reports whether b differs from old, and whether the change
+// is only a move to another category
+func bookmarkChange(old, b *bookmark.Bookmark) (Operation, bool) {
+	if sameBookmark(old, b) {
+		return "", false
+	}
+
+	if old.Category != b.Category {
+		moved := cloneBookmark(b)
+		moved.Category = old.Category
+		moved.UpdatedAt = old.UpdatedAt
+		if sameBookmark(moved, old) {
+			return OpMove, true
+		}
+	}
+	return OpUpdate, true
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/tom-023/ubm/internal/testutil"
+)
+
+type journaledBackend interface {
+	Backend
+	Journaled
+}
+
+func TestJournal_RecordsOperations(t *testing.T) {
+	backends := map[string]func(t *testing.T) journaledBackend{
+		"file": func(t *testing.T) journaledBackend {
+			dir, cleanup := testutil.TempDir(t)
+			t.Cleanup(cleanup)
+			s, err := New(dir)
+			if err != nil {
+				t.Fatalf("Failed to create storage: %v", err)
+			}
+			return s
+		},
+		"bolt": func(t *testing.T) journaledBackend {
+			return newTestBolt(t)
+		},
+	}
+
+	for name, newBackend := range backends {
+		t.Run(name, func(t *testing.T) {
+			s := newBackend(t)
+
+			b := testutil.CreateTestBookmark("Go", "https://go.dev", "programming")
+			if err := s.AddBookmark(b); err != nil {
+				t.Fatalf("AddBookmark() error = %v", err)
+			}
+
+			edited := *b
+			edited.URL = "https://golang.org"
+			if err := s.UpdateBookmark(&edited); err != nil {
+				t.Fatalf("UpdateBookmark() error = %v", err)
+			}
+
+			err := s.Update(func(data *Data) error {
+				data.FindBookmark(b.ID).Category = "golang"
+				data.AddCategory("golang")
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+
+			if err := s.DeleteBookmark(b.ID); err != nil {
+				t.Fatalf("DeleteBookmark() error = %v", err)
+			}
+
+			err = s.Update(func(data *Data) error {
+				data.Categories = []string{"programming"}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+
+			entries, err := s.Journal().Entries(JournalFilter{})
+			if err != nil {
+				t.Fatalf("Entries() error = %v", err)
+			}
+
+			want := []Operation{OpCategoryCreate, OpAdd, OpUpdate, OpCategoryCreate, OpMove, OpTrash, OpCategoryDelete}
+			if len(entries) != len(want) {
+				t.Fatalf("Got %d entries, want %d: %+v", len(entries), len(want), entries)
+			}
+			for i, op := range want {
+				if entries[i].Op != op {
+					t.Errorf("Entry[%d].Op = %v, want %v", i, entries[i].Op, op)
+				}
+			}
+
+			update := entries[2]
+			if update.Before.URL != "https://go.dev" || update.After.URL != "https://golang.org" {
+				t.Errorf("Update entry before/after = %v/%v", update.Before.URL, update.After.URL)
+			}
+			if entries[5].Before == nil || entries[5].After != nil || entries[5].DeletedAt == nil {
+				t.Error("Trash entry should carry the removed bookmark and its deletion time")
+			}
+			if entries[6].Category != "golang" {
+				t.Errorf("Category delete entry = %v, want golang", entries[6].Category)
+			}
+
+			// Entries from one save share a transaction
+			if entries[3].Tx != entries[4].Tx || entries[2].Tx == entries[3].Tx {
+				t.Error("Entries should be grouped by transaction")
+			}
+		})
+	}
+}
+
+func TestJournal_RolledBackUpdateIsNotRecorded(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	s.Update(func(data *Data) error {
+		data.AddCategory("temp")
+		return os.ErrInvalid
+	})
+
+	entries, err := s.Journal().Entries(JournalFilter{})
+	if err != nil {
+		t.Fatalf("Entries() error = %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Rolled back update was journaled: %+v", entries)
+	}
+}
+
+func TestJournal_Filter(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	first := testutil.CreateTestBookmark("First", "https://first.example", "")
+	second := testutil.CreateTestBookmark("Second", "https://second.example", "")
+	s.AddBookmark(first)
+	time.Sleep(10 * time.Millisecond)
+	since := time.Now()
+	s.AddBookmark(second)
+	s.DeleteBookmark(first.ID)
+
+	byID, _ := s.Journal().Entries(JournalFilter{BookmarkID: first.ID})
+	if len(byID) != 2 || byID[0].Op != OpAdd || byID[1].Op != OpTrash {
+		t.Errorf("Filter by bookmark returned %+v", byID)
+	}
+
+	recent, _ := s.Journal().Entries(JournalFilter{Since: since})
+	if len(recent) != 2 || recent[0].BookmarkID != second.ID {
+		t.Errorf("Filter by time returned %+v", recent)
+	}
+}
+
+func TestJournal_TornLastLine(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	s.AddBookmark(testutil.CreateTestBookmark("Go", "https://go.dev", ""))
+
+	// Simulate a crash in the middle of an append
+	f, err := os.OpenFile(s.Journal().Path(), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	f.WriteString(`{"time":"2024-01-01T00:00:00Z","op":"ad`)
+	f.Close()
+
+	entries, err := s.Journal().Entries(JournalFilter{})
+	if err != nil {
+		t.Fatalf("Entries() should ignore a torn last line, got %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Got %d entries, want 1", len(entries))
+	}
+
+	// The next append replaces the torn line instead of gluing onto it
+	s.AddBookmark(testutil.CreateTestBookmark("Rust", "https://rust-lang.org", ""))
+	entries, err = s.Journal().Entries(JournalFilter{})
+	if err != nil {
+		t.Fatalf("Entries() after append error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("Got %d entries after append, want 2", len(entries))
+	}
+}
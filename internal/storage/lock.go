@@ -0,0 +1,9 @@
+package storage
+
+import "github.com/tom-023/ubm/internal/lockfile"
+
+// ErrLocked is matched by errors.Is when the library lock could not be acquired
+var ErrLocked = lockfile.ErrLocked
+
+// LockedError is returned when the library lock is still held after the timeout
+type LockedError = lockfile.LockedError
@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tom-023/ubm/internal/lockfile"
+	"github.com/tom-023/ubm/internal/testutil"
+)
+
+const (
+	writerDirEnv   = "UBM_TEST_WRITER_DIR"
+	writerIDEnv    = "UBM_TEST_WRITER_ID"
+	writerCountEnv = "UBM_TEST_WRITER_COUNT"
+)
+
+// TestHelperWriterProcess is not a real test. It is re-executed as a child
+// process by TestStorage_MultiProcessWriters to act as an independent writer.
+func TestHelperWriterProcess(t *testing.T) {
+	dir := os.Getenv(writerDirEnv)
+	if dir == "" {
+		t.Skip("helper process only")
+	}
+
+	count, _ := strconv.Atoi(os.Getenv(writerCountEnv))
+	writerID := os.Getenv(writerIDEnv)
+
+	s, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	s.SetLockTimeout(30 * time.Second)
+
+	for i := 0; i < count; i++ {
+		title := fmt.Sprintf("proc%s-%d", writerID, i)
+		b := testutil.CreateTestBookmark(title, "https://example.com/"+title, "multi")
+		if err := s.AddBookmark(b); err != nil {
+			t.Fatalf("AddBookmark() error = %v", err)
+		}
+	}
+}
+
+func TestStorage_MultiProcessWriters(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping multi-process test in short mode")
+	}
+
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	const writers = 4
+	const perWriter = 15
+
+	cmds := make([]*exec.Cmd, writers)
+	for i := range cmds {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestHelperWriterProcess$")
+		cmd.Env = append(os.Environ(),
+			writerDirEnv+"="+dir,
+			writerIDEnv+"="+strconv.Itoa(i),
+			writerCountEnv+"="+strconv.Itoa(perWriter),
+		)
+		cmds[i] = cmd
+	}
+
+	// Start every writer before waiting on any of them so they overlap
+	for _, cmd := range cmds {
+		if err := cmd.Start(); err != nil {
+			t.Fatalf("Failed to start writer: %v", err)
+		}
+	}
+	for i, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Fatalf("Writer %d failed: %v", i, err)
+		}
+	}
+
+	s, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	data, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if len(data.Bookmarks) != writers*perWriter {
+		t.Errorf("Expected %d bookmarks, got %d (writes were lost)", writers*perWriter, len(data.Bookmarks))
+	}
+}
+
+func TestStorage_ConcurrentWritersSeparateInstances(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	const writers = 8
+	const perWriter = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*perWriter)
+
+	for w := 0; w < writers; w++ {
+		// Each writer gets its own Storage, so only the file lock serializes them
+		s, err := New(dir)
+		if err != nil {
+			t.Fatalf("Failed to create storage: %v", err)
+		}
+
+		wg.Add(1)
+		go func(w int, s *Storage) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				title := fmt.Sprintf("writer%d-%d", w, i)
+				b := testutil.CreateTestBookmark(title, "https://example.com/"+title, "concurrent")
+				if err := s.AddBookmark(b); err != nil {
+					errs <- err
+				}
+			}
+		}(w, s)
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("AddBookmark() error = %v", err)
+	}
+
+	s, _ := New(dir)
+	data, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(data.Bookmarks) != writers*perWriter {
+		t.Errorf("Expected %d bookmarks, got %d (writes were lost)", writers*perWriter, len(data.Bookmarks))
+	}
+}
+
+func TestStorage_LockTimeout(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	s.SetLockTimeout(100 * time.Millisecond)
+
+	// Hold the lock through a separate file handle, as another process would
+	held, err := lockfile.Acquire(s.lockPath, true, time.Second)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	b := testutil.CreateTestBookmark("Blocked", "https://blocked.com", "test")
+	err = s.AddBookmark(b)
+	if err == nil {
+		t.Fatal("Expected AddBookmark() to fail while the lock is held")
+	}
+
+	if !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked, got %v", err)
+	}
+
+	var lockedErr *LockedError
+	if !errors.As(err, &lockedErr) {
+		t.Fatalf("Expected *LockedError, got %T", err)
+	}
+	if lockedErr.PID != os.Getpid() {
+		t.Errorf("LockedError.PID = %d, want %d", lockedErr.PID, os.Getpid())
+	}
+
+	// Readers are blocked by an exclusive holder as well
+	if _, err := s.Load(); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected Load() to report ErrLocked, got %v", err)
+	}
+
+	if err := held.Release(); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+
+	if err := s.AddBookmark(b); err != nil {
+		t.Errorf("AddBookmark() after release error = %v", err)
+	}
+}
+
+func TestStorage_SharedReadLocks(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	s.SetLockTimeout(100 * time.Millisecond)
+
+	reader, err := lockfile.Acquire(s.lockPath, false, time.Second)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	defer reader.Release()
+
+	// Another reader can proceed alongside
+	if _, err := s.Load(); err != nil {
+		t.Errorf("Load() with concurrent reader error = %v", err)
+	}
+
+	// A writer has to wait for the reader
+	b := testutil.CreateTestBookmark("Writer", "https://writer.com", "test")
+	if err := s.AddBookmark(b); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected AddBookmark() to report ErrLocked, got %v", err)
+	}
+}
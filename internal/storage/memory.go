@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tom-023/ubm/internal/bookmark"
+)
+
+// MemoryStorage is a Backend that keeps the library in memory. It is meant for
+// tests and for embedding ubm on top of another persistence layer. Load and
+// Save copy the data, so callers get the same isolation as with the file store.
+type MemoryStorage struct {
+	data *Data
+	mu   sync.RWMutex
+}
+
+func NewMemory() *MemoryStorage {
+	return &MemoryStorage{
+		data: &Data{
+			SchemaVersion: CurrentSchemaVersion,
+			Bookmarks:     []*bookmark.Bookmark{},
+			Categories:    []string{},
+			Trash:         []*TrashedBookmark{},
+			UpdatedAt:     time.Now(),
+		},
+	}
+}
+
+func (m *MemoryStorage) Load() (*Data, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.data.Clone(), nil
+}
+
+func (m *MemoryStorage) Save(data *Data) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := checkRevision(data, m.data.Clone(), nil); err != nil {
+		return err
+	}
+	m.save(data)
+	return nil
+}
+
+func (m *MemoryStorage) save(data *Data) {
+	data.SchemaVersion = CurrentSchemaVersion
+	data.UpdatedAt = time.Now()
+	if data.Trash == nil {
+		data.Trash = []*TrashedBookmark{}
+	}
+	m.data = data.Clone()
+}
+
+func (m *MemoryStorage) Update(fn func(*Data) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data := m.data.Clone()
+	if err := fn(data); err != nil {
+		return err
+	}
+
+	m.save(data)
+	return nil
+}
+
+func (m *MemoryStorage) AddBookmark(b *bookmark.Bookmark) error {
+	return m.Update(func(data *Data) error {
+		return data.AddBookmark(b)
+	})
+}
+
+func (m *MemoryStorage) UpdateBookmark(b *bookmark.Bookmark) error {
+	return m.Update(func(data *Data) error {
+		return data.UpdateBookmark(b)
+	})
+}
+
+// DeleteBookmark moves the bookmark to the trash
+func (m *MemoryStorage) DeleteBookmark(id string) error {
+	return m.Update(func(data *Data) error {
+		return data.TrashBookmark(id, time.Now())
+	})
+}
+
+func (m *MemoryStorage) GetBookmark(id string) (*bookmark.Bookmark, error) {
+	data, _ := m.Load()
+	return data.GetBookmark(id)
+}
+
+func (m *MemoryStorage) GetBookmarksByCategory(category string) ([]*bookmark.Bookmark, error) {
+	data, _ := m.Load()
+	return data.BookmarksByCategory(category), nil
+}
+
+func (m *MemoryStorage) SearchBookmarks(query string) ([]*bookmark.Bookmark, error) {
+	data, _ := m.Load()
+	return data.Search(query), nil
+}
@@ -0,0 +1,346 @@
+package storage
+
+import (
+	"strings"
+	"time"
+
+	"github.com/tom-023/ubm/internal/bookmark"
+)
+
+// MergeConflict is a bookmark that both sides changed in ways that cannot be
+// combined: the same field was changed to different values, or one side
+// deleted the bookmark while the other changed it
+type MergeConflict struct {
+	ID string
+	// Base, Ours and Theirs are nil where the bookmark does not exist or is
+	// in the trash
+	Base   *bookmark.Bookmark
+	Ours   *bookmark.Bookmark
+	Theirs *bookmark.Bookmark
+	// Fields names the fields both sides changed differently; it is empty
+	// when one side deleted the bookmark
+	Fields []string
+	// Resolved is the version kept in the merged library, nil if none. It
+	// holds every change that did not conflict.
+	Resolved *bookmark.Bookmark
+}
+
+// Take sets field of the resolved version to its value in b
+func (c *MergeConflict) Take(field string, b *bookmark.Bookmark) {
+	for _, f := range mergeFields {
+		if f.name == field {
+			f.copy(c.Resolved, b)
+		}
+	}
+}
+
+// ConflictResolver decides a merge conflict by changing c.Resolved, which
+// holds the default choice when it is called. Returning an error stops the
+// merge.
+type ConflictResolver func(c *MergeConflict) error
+
+// MergeResult is the outcome of Merge
+type MergeResult struct {
+	Data      *Data
+	Conflicts []MergeConflict
+}
+
+// mergeField is a field of a bookmark that is merged on its own
+type mergeField struct {
+	name string
+	get  func(b *bookmark.Bookmark) string
+	copy func(dst, src *bookmark.Bookmark)
+}
+
+var mergeFields = []mergeField{
+	{"title", func(b *bookmark.Bookmark) string { return b.Title },
+		func(dst, src *bookmark.Bookmark) { dst.Title = src.Title }},
+	{"URL", func(b *bookmark.Bookmark) string { return b.URL },
+		func(dst, src *bookmark.Bookmark) { dst.URL = src.URL }},
+	{"category", func(b *bookmark.Bookmark) string { return b.Category },
+		func(dst, src *bookmark.Bookmark) { dst.Category = src.Category }},
+	{"tags", func(b *bookmark.Bookmark) string { return strings.Join(b.Tags, ", ") },
+		func(dst, src *bookmark.Bookmark) { dst.Tags = append([]string{}, src.Tags...) }},
+	{"description", func(b *bookmark.Bookmark) string { return b.Description },
+		func(dst, src *bookmark.Bookmark) { dst.Description = src.Description }},
+	{"keyword", func(b *bookmark.Bookmark) string { return b.Keyword },
+		func(dst, src *bookmark.Bookmark) { dst.Keyword = src.Keyword }},
+}
+
+// FieldValue returns a field named in MergeConflict.Fields as text
+func FieldValue(b *bookmark.Bookmark, field string) string {
+	for _, f := range mergeFields {
+		if f.name == field {
+			return f.get(b)
+		}
+	}
+	return ""
+}
+
+// mergeEntry is a bookmark on one side of a merge; deletedAt is set if it is
+// in the trash
+type mergeEntry struct {
+	b         *bookmark.Bookmark
+	deletedAt *time.Time
+}
+
+func (e *mergeEntry) live() *bookmark.Bookmark {
+	if e == nil || e.deletedAt != nil {
+		return nil
+	}
+	return e.b
+}
+
+func sameEntry(a, b *mergeEntry) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if (a.deletedAt == nil) != (b.deletedAt == nil) {
+		return false
+	}
+	if a.deletedAt != nil && !a.deletedAt.Equal(*b.deletedAt) {
+		return false
+	}
+	return sameBookmark(a.b, b.b)
+}
+
+func mergeEntries(data *Data) map[string]*mergeEntry {
+	entries := make(map[string]*mergeEntry, len(data.Bookmarks)+len(data.Trash))
+	for _, t := range data.Trash {
+		entries[t.Bookmark.ID] = &mergeEntry{b: t.Bookmark, deletedAt: timePtr(t.DeletedAt)}
+	}
+	for _, b := range data.Bookmarks {
+		if _, ok := entries[b.ID]; !ok || entries[b.ID].deletedAt != nil {
+			entries[b.ID] = &mergeEntry{b: b}
+		}
+	}
+	return entries
+}
+
+// Merge combines two libraries that were both changed since base, bookmark by
+// bookmark and field by field rather than line by line, so changes to
+// different bookmarks or different fields never conflict. Where both sides
+// changed the same field, the newer bookmark's value wins, and a bookmark
+// that still exists beats a deletion; each such case is reported in
+// Conflicts. None of the inputs is modified.
+func Merge(base, ours, theirs *Data) *MergeResult {
+	result, _ := MergeWith(base, ours, theirs, nil)
+	return result
+}
+
+// MergeWith is Merge with conflicts decided by resolve instead of by the
+// default rules. Bookmarks are matched by ID, or by URL for bookmarks that
+// only one side and not base has under their ID, as happens when the same
+// page was added on both sides. An empty base makes every difference between
+// the sides a conflict.
+func MergeWith(base, ours, theirs *Data, resolve ConflictResolver) (*MergeResult, error) {
+	theirs = matchByURL(base, ours, theirs)
+
+	baseEntries := mergeEntries(base)
+	ourEntries := mergeEntries(ours)
+	theirEntries := mergeEntries(theirs)
+
+	result := &MergeResult{}
+	merged := make(map[string]*mergeEntry)
+
+	mergeOne := func(id string) error {
+		if _, done := merged[id]; done {
+			return nil
+		}
+		b, o, t := baseEntries[id], ourEntries[id], theirEntries[id]
+
+		var c MergeConflict
+		switch {
+		case sameEntry(o, t), sameEntry(b, t):
+			merged[id] = o
+			return nil
+		case sameEntry(b, o):
+			merged[id] = t
+			return nil
+		case o.live() != nil && t.live() != nil:
+			resolved, fields := mergeBookmarks(b.live(), o.b, t.b)
+			if len(fields) == 0 {
+				merged[id] = &mergeEntry{b: resolved}
+				return nil
+			}
+			c = MergeConflict{Fields: fields, Resolved: resolved}
+		case o.live() == nil && t.live() == nil:
+			// Deleted on both sides; whether it is still in the trash
+			// is not worth asking about
+			merged[id] = o
+			if o == nil {
+				merged[id] = t
+			}
+			return nil
+		default:
+			edited := o.live()
+			if edited == nil {
+				edited = t.live()
+			}
+			c = MergeConflict{Resolved: cloneBookmark(edited)}
+		}
+
+		c.ID, c.Base, c.Ours, c.Theirs = id, b.live(), o.live(), t.live()
+		if resolve != nil {
+			if err := resolve(&c); err != nil {
+				return err
+			}
+		}
+		result.Conflicts = append(result.Conflicts, c)
+
+		switch {
+		case c.Resolved != nil:
+			merged[id] = &mergeEntry{b: c.Resolved}
+		case o.live() == nil:
+			merged[id] = o
+		default:
+			merged[id] = t
+		}
+		return nil
+	}
+
+	for _, data := range []*Data{ours, theirs, base} {
+		for _, b := range data.Bookmarks {
+			if err := mergeOne(b.ID); err != nil {
+				return nil, err
+			}
+		}
+		for _, t := range data.Trash {
+			if err := mergeOne(t.Bookmark.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	out := &Data{
+		SchemaVersion: CurrentSchemaVersion,
+		Bookmarks:     []*bookmark.Bookmark{},
+		Categories:    mergeCategories(base.Categories, ours.Categories, theirs.Categories),
+		Trash:         []*TrashedBookmark{},
+		UpdatedAt:     ours.UpdatedAt,
+	}
+
+	// Keep the order of ours, then add what only theirs has
+	placed := make(map[string]bool)
+	for _, data := range []*Data{ours, theirs} {
+		for _, b := range data.Bookmarks {
+			e := merged[b.ID]
+			if placed[b.ID] || e.live() == nil {
+				continue
+			}
+			placed[b.ID] = true
+			out.Bookmarks = append(out.Bookmarks, cloneBookmark(e.b))
+			out.AddCategory(e.b.Category)
+		}
+		for _, t := range data.Trash {
+			e := merged[t.Bookmark.ID]
+			if placed[t.Bookmark.ID] || e == nil || e.deletedAt == nil {
+				continue
+			}
+			placed[t.Bookmark.ID] = true
+			out.Trash = append(out.Trash, &TrashedBookmark{Bookmark: cloneBookmark(e.b), DeletedAt: *e.deletedAt})
+		}
+	}
+
+	result.Data = out
+	return result, nil
+}
+
+// mergeBookmarks combines two changed versions of a bookmark field by field.
+// Fields only one side changed since base take that side's value; fields
+// both changed differently are returned, and take the value of the more
+// recently updated side. Without a base every differing field conflicts.
+func mergeBookmarks(base, ours, theirs *bookmark.Bookmark) (*bookmark.Bookmark, []string) {
+	merged := cloneBookmark(ours)
+	newer := ours
+	if theirs.UpdatedAt.After(ours.UpdatedAt) {
+		newer = theirs
+		merged.UpdatedAt = theirs.UpdatedAt
+	}
+
+	var conflicts []string
+	for _, f := range mergeFields {
+		o, t := f.get(ours), f.get(theirs)
+		switch {
+		case o == t:
+		case base != nil && f.get(base) == o:
+			f.copy(merged, theirs)
+		case base != nil && f.get(base) == t:
+		default:
+			f.copy(merged, newer)
+			conflicts = append(conflicts, f.name)
+		}
+	}
+	return merged, conflicts
+}
+
+// matchByURL returns theirs with bookmarks that neither ours nor base knows
+// by ID renamed to the ID of a bookmark in ours with the same URL, if that
+// one is likewise unknown to theirs
+func matchByURL(base, ours, theirs *Data) *Data {
+	baseEntries := mergeEntries(base)
+	ourEntries := mergeEntries(ours)
+	theirEntries := mergeEntries(theirs)
+
+	byURL := make(map[string]string)
+	for _, b := range ours.Bookmarks {
+		if _, ok := theirEntries[b.ID]; ok {
+			continue
+		}
+		if _, ok := byURL[b.URL]; !ok {
+			byURL[b.URL] = b.ID
+		}
+	}
+
+	ids := make(map[string]string)
+	for _, b := range theirs.Bookmarks {
+		if ourEntries[b.ID] != nil || baseEntries[b.ID] != nil {
+			continue
+		}
+		if id, ok := byURL[b.URL]; ok {
+			ids[b.ID] = id
+			delete(byURL, b.URL)
+		}
+	}
+	if len(ids) == 0 {
+		return theirs
+	}
+
+	theirs = theirs.Clone()
+	for _, b := range theirs.Bookmarks {
+		if id, ok := ids[b.ID]; ok {
+			b.ID = id
+		}
+	}
+	return theirs
+}
+
+// mergeCategories keeps the categories both sides have, plus those either
+// side added since base
+func mergeCategories(base, ours, theirs []string) []string {
+	inBase := make(map[string]bool, len(base))
+	for _, c := range base {
+		inBase[c] = true
+	}
+	inOurs := make(map[string]bool, len(ours))
+	for _, c := range ours {
+		inOurs[c] = true
+	}
+	inTheirs := make(map[string]bool, len(theirs))
+	for _, c := range theirs {
+		inTheirs[c] = true
+	}
+
+	categories := []string{}
+	seen := make(map[string]bool)
+	for _, c := range append(append([]string{}, ours...), theirs...) {
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+		if (inOurs[c] && inTheirs[c]) || !inBase[c] {
+			categories = append(categories, c)
+		}
+	}
+	return categories
+}
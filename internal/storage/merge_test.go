@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tom-023/ubm/internal/bookmark"
+)
+
+func mergeBookmark(id, title, category string, updated time.Time) *bookmark.Bookmark {
+	return &bookmark.Bookmark{
+		ID:        id,
+		Title:     title,
+		URL:       "https://example.com/" + id,
+		Category:  category,
+		CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		UpdatedAt: updated,
+	}
+}
+
+func titles(bookmarks []*bookmark.Bookmark) []string {
+	var result []string
+	for _, b := range bookmarks {
+		result = append(result, b.Title)
+	}
+	return result
+}
+
+func TestMerge(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Hour)
+	t2 := t0.Add(2 * time.Hour)
+
+	base := &Data{
+		Bookmarks: []*bookmark.Bookmark{
+			mergeBookmark("keep", "Keep", "dev", t0),
+			mergeBookmark("edit-ours", "Edit", "dev", t0),
+			mergeBookmark("edit-theirs", "Edit", "dev", t0),
+			mergeBookmark("both", "Both", "dev", t0),
+			mergeBookmark("gone", "Gone", "old", t0),
+			mergeBookmark("edit-vs-delete", "Edit vs delete", "dev", t0),
+		},
+		Categories: []string{"dev", "old"},
+	}
+
+	ours := base.Clone()
+	ours.Bookmarks[1].Title = "Edited by us"
+	ours.Bookmarks[3].Title = "Both: ours"
+	ours.Bookmarks[3].UpdatedAt = t1
+	ours.Bookmarks[4] = mergeBookmark("ours-new", "Ours new", "mine", t1)
+	if err := ours.TrashBookmark("edit-vs-delete", t1); err != nil {
+		t.Fatal(err)
+	}
+	ours.Categories = []string{"dev", "mine"}
+
+	theirs := base.Clone()
+	theirs.Bookmarks[2].Title = "Edited by them"
+	theirs.Bookmarks[3].Title = "Both: theirs"
+	theirs.Bookmarks[3].UpdatedAt = t2
+	theirs.Bookmarks[5].Title = "Edit vs delete: edited"
+	theirs.Bookmarks[5].UpdatedAt = t2
+	theirs.Bookmarks = append(theirs.Bookmarks[:4], theirs.Bookmarks[5], mergeBookmark("theirs-new", "Theirs new", "theirs", t1))
+	theirs.Categories = []string{"dev", "theirs"}
+
+	oursBefore := ours.Clone()
+	result := Merge(base, ours, theirs)
+	if !reflect.DeepEqual(ours, oursBefore) {
+		t.Error("Merge() must not modify its inputs")
+	}
+
+	want := []string{"Keep", "Edited by us", "Edited by them", "Both: theirs", "Ours new", "Edit vs delete: edited", "Theirs new"}
+	if got := titles(result.Data.Bookmarks); !reflect.DeepEqual(got, want) {
+		t.Errorf("Bookmarks = %v, want %v", got, want)
+	}
+	if len(result.Data.Trash) != 0 {
+		t.Errorf("Trash = %v, want the edited bookmark kept instead", result.Data.Trash)
+	}
+	if want := []string{"dev", "mine", "theirs"}; !reflect.DeepEqual(result.Data.Categories, want) {
+		t.Errorf("Categories = %v, want %v", result.Data.Categories, want)
+	}
+
+	conflicts := map[string]MergeConflict{}
+	for _, c := range result.Conflicts {
+		conflicts[c.ID] = c
+	}
+	if len(conflicts) != 2 {
+		t.Fatalf("Conflicts = %+v, want 2", result.Conflicts)
+	}
+	if c := conflicts["both"]; c.Resolved == nil || c.Resolved.Title != "Both: theirs" || c.Ours.Title != "Both: ours" {
+		t.Errorf("both = %+v, want the newer version kept", c)
+	}
+	if c := conflicts["edit-vs-delete"]; c.Ours != nil || c.Resolved == nil {
+		t.Errorf("edit-vs-delete = %+v, want the edit kept over the deletion", c)
+	}
+}
+
+func TestMerge_Trash(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	base := &Data{Bookmarks: []*bookmark.Bookmark{mergeBookmark("a", "A", "", t0), mergeBookmark("b", "B", "", t0)}}
+
+	ours := base.Clone()
+	if err := ours.TrashBookmark("a", t0.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	theirs := base.Clone()
+	theirs.Bookmarks = append(theirs.Bookmarks, mergeBookmark("c", "C", "", t0))
+
+	result := Merge(base, ours, theirs)
+	if got := titles(result.Data.Bookmarks); !reflect.DeepEqual(got, []string{"B", "C"}) {
+		t.Errorf("Bookmarks = %v, want [B C]", got)
+	}
+	if len(result.Data.Trash) != 1 || result.Data.Trash[0].Bookmark.ID != "a" {
+		t.Errorf("Trash = %v, want bookmark a", result.Data.Trash)
+	}
+	if len(result.Conflicts) != 0 {
+		t.Errorf("Conflicts = %+v, want none", result.Conflicts)
+	}
+}
+
+func TestMerge_SameChangeOnBothSides(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	base := &Data{}
+
+	ours := &Data{Bookmarks: []*bookmark.Bookmark{mergeBookmark("a", "A", "", t0)}}
+	theirs := ours.Clone()
+
+	result := Merge(base, ours, theirs)
+	if len(result.Data.Bookmarks) != 1 || len(result.Conflicts) != 0 {
+		t.Errorf("Merge() = %v with conflicts %+v, want A once without conflicts", titles(result.Data.Bookmarks), result.Conflicts)
+	}
+}
+
+func TestMerge_Fields(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	base := &Data{Bookmarks: []*bookmark.Bookmark{mergeBookmark("a", "A", "dev", t0)}}
+
+	ours := base.Clone()
+	ours.Bookmarks[0].Title = "A (renamed)"
+	ours.Bookmarks[0].Description = "ours"
+	ours.Bookmarks[0].UpdatedAt = t0.Add(2 * time.Hour)
+
+	theirs := base.Clone()
+	theirs.Bookmarks[0].Category = "tools"
+	theirs.Bookmarks[0].Tags = []string{"go"}
+	theirs.Bookmarks[0].Description = "theirs"
+	theirs.Bookmarks[0].UpdatedAt = t0.Add(time.Hour)
+
+	result := Merge(base, ours, theirs)
+	if len(result.Data.Bookmarks) != 1 {
+		t.Fatalf("Bookmarks = %v, want one", titles(result.Data.Bookmarks))
+	}
+	got := result.Data.Bookmarks[0]
+	if got.Title != "A (renamed)" || got.Category != "tools" || !reflect.DeepEqual(got.Tags, []string{"go"}) {
+		t.Errorf("Merged bookmark = %+v, want both sides' changes", got)
+	}
+	if got.Description != "ours" {
+		t.Errorf("Description = %q, want the newer side's value", got.Description)
+	}
+	if !got.UpdatedAt.Equal(ours.Bookmarks[0].UpdatedAt) {
+		t.Errorf("UpdatedAt = %v, want the newer of both", got.UpdatedAt)
+	}
+	if len(result.Conflicts) != 1 || !reflect.DeepEqual(result.Conflicts[0].Fields, []string{"description"}) {
+		t.Errorf("Conflicts = %+v, want only the description", result.Conflicts)
+	}
+	if want := []string{"tools"}; !reflect.DeepEqual(result.Data.Categories, want) {
+		t.Errorf("Categories = %v, want %v", result.Data.Categories, want)
+	}
+}
+
+func TestMergeWith_Resolver(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	base := &Data{Bookmarks: []*bookmark.Bookmark{
+		mergeBookmark("a", "A", "", t0),
+		mergeBookmark("b", "B", "", t0),
+	}}
+
+	ours := base.Clone()
+	ours.Bookmarks[0].Title = "A: ours"
+	ours.Bookmarks[0].UpdatedAt = t0.Add(time.Hour)
+	ours.Bookmarks[1].Title = "B: edited"
+
+	theirs := base.Clone()
+	theirs.Bookmarks[0].Title = "A: theirs"
+	theirs.Bookmarks[0].UpdatedAt = t0.Add(2 * time.Hour)
+	if err := theirs.TrashBookmark("b", t0.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	var asked []string
+	result, err := MergeWith(base, ours, theirs, func(c *MergeConflict) error {
+		asked = append(asked, c.ID)
+		switch c.ID {
+		case "a":
+			c.Take("title", c.Ours)
+		case "b":
+			c.Resolved = nil
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("MergeWith() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(asked, []string{"a", "b"}) {
+		t.Errorf("Resolver was asked about %v, want [a b]", asked)
+	}
+	if got := titles(result.Data.Bookmarks); !reflect.DeepEqual(got, []string{"A: ours"}) {
+		t.Errorf("Bookmarks = %v, want [A: ours]", got)
+	}
+	if len(result.Data.Trash) != 1 || result.Data.Trash[0].Bookmark.ID != "b" {
+		t.Errorf("Trash = %v, want the deletion kept", result.Data.Trash)
+	}
+
+	stop := errors.New("stop")
+	if _, err := MergeWith(base, ours, theirs, func(*MergeConflict) error { return stop }); err != stop {
+		t.Errorf("MergeWith() error = %v, want the resolver's error", err)
+	}
+}
+
+func TestMerge_MatchByURL(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// The same page added on two machines gets two IDs
+	mine := mergeBookmark("mine", "Go", "dev", t0)
+	mine.URL = "https://go.dev"
+	other := mergeBookmark("other", "The Go language", "dev", t0.Add(time.Hour))
+	other.URL = "https://go.dev"
+	other.Tags = []string{"lang"}
+
+	ours := &Data{Bookmarks: []*bookmark.Bookmark{mine}, Categories: []string{"dev"}}
+	theirs := &Data{Bookmarks: []*bookmark.Bookmark{other, mergeBookmark("new", "New", "dev", t0)}, Categories: []string{"dev"}}
+
+	result := Merge(&Data{}, ours, theirs)
+	if got := titles(result.Data.Bookmarks); !reflect.DeepEqual(got, []string{"The Go language", "New"}) {
+		t.Fatalf("Bookmarks = %v, want the pages matched by URL", got)
+	}
+	got := result.Data.Bookmarks[0]
+	if got.ID != "mine" {
+		t.Errorf("ID = %q, want our ID kept", got.ID)
+	}
+	if len(result.Conflicts) != 1 || !reflect.DeepEqual(result.Conflicts[0].Fields, []string{"title", "tags"}) {
+		t.Errorf("Conflicts = %+v, want title and tags without a base", result.Conflicts)
+	}
+	if theirs.Bookmarks[0].ID != "other" {
+		t.Error("Merge() must not modify its inputs")
+	}
+}
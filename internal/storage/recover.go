@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrCorrupt is matched by errors.Is when the library file cannot be decoded
+var ErrCorrupt = errors.New("bookmark file is corrupt")
+
+// ErrNoValidBackup is returned by Recover when no backup can be read either
+var ErrNoValidBackup = errors.New("no readable backup to recover from")
+
+// CorruptError is returned when a library file exists but cannot be decoded,
+// e.g. because it was truncated or broken by hand
+type CorruptError struct {
+	Path string
+	Err  error
+}
+
+func (e *CorruptError) Error() string {
+	return fmt.Sprintf("failed to decode data: %v", e.Err)
+}
+
+func (e *CorruptError) Unwrap() error {
+	return e.Err
+}
+
+func (e *CorruptError) Is(target error) bool {
+	return target == ErrCorrupt
+}
+
+// Check returns a *CorruptError if the library file cannot be decoded.
+// Unlike Load it never asks for a passphrase: of an encrypted file only the
+// envelope is checked, and damage inside is left to the decryption of
+// whatever reads it.
+func (s *Storage) Check() error {
+	return s.withLock(false, func() error {
+		raw, err := os.ReadFile(s.filePath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return fmt.Errorf("failed to open file: %w", err)
+		}
+		if isEncrypted(raw) {
+			if _, _, err := splitHeader(raw); errors.Is(err, errEnvelope) {
+				return &CorruptError{Path: s.filePath, Err: err}
+			}
+			return nil
+		}
+		_, err = s.decode(s.filePath, raw)
+		return err
+	})
+}
+
+// Recovery describes what Recover did
+type Recovery struct {
+	// Quarantined is where the corrupt file was moved
+	Quarantined string
+	// Backup is the generation the library was restored from
+	Backup Backup
+	// Skipped counts newer backups that were unreadable as well
+	Skipped int
+}
+
+// Recover replaces a corrupt library with the newest backup that can be read.
+// The corrupt file is kept next to the library under a timestamped name
+// rather than deleted, so nothing is lost if the backup turns out to be too
+// old. A library that loads fine is left alone.
+func (s *Storage) Recover() (*Recovery, error) {
+	var result *Recovery
+	err := s.withLock(true, func() error {
+		_, err := s.load()
+		if err == nil {
+			return fmt.Errorf("library is not corrupt")
+		}
+		if !errors.Is(err, ErrCorrupt) {
+			return err
+		}
+
+		backups, err := s.ListBackups()
+		if err != nil {
+			return err
+		}
+
+		result = &Recovery{}
+		var data *Data
+		for _, backup := range backups {
+			data, err = s.loadFile(backup.Path)
+			if err == nil {
+				result.Backup = backup
+				break
+			}
+			result.Skipped++
+		}
+		if data == nil {
+			result = nil
+			return ErrNoValidBackup
+		}
+
+		quarantined, err := s.quarantine(time.Now())
+		if err != nil {
+			result = nil
+			return fmt.Errorf("failed to quarantine corrupt file: %w", err)
+		}
+		result.Quarantined = quarantined
+
+		// UpdatedAt is left as in the backup: that is the state being restored
+		if err := s.writeFile(data); err != nil {
+			return fmt.Errorf("corrupt file was moved to %s, but restoring the backup failed: %w", quarantined, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// quarantine moves the library file aside as bookmarks.corrupt-<time>.<ext>
+func (s *Storage) quarantine(now time.Time) (string, error) {
+	ext := filepath.Ext(s.filePath)
+	base := strings.TrimSuffix(filepath.Base(s.filePath), ext)
+
+	now = now.UTC()
+	for {
+		path := filepath.Join(filepath.Dir(s.filePath), base+".corrupt-"+now.Format(backupTimeFormat)+ext)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path, os.Rename(s.filePath, path)
+		}
+		now = now.Add(time.Nanosecond)
+	}
+}
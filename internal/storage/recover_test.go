@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tom-023/ubm/internal/testutil"
+)
+
+func TestStorage_LoadDetectsCorruption(t *testing.T) {
+	tests := map[string]string{
+		"truncated":  `{"schema_version": 3, "bookmarks": [{"id": "1", "ti`,
+		"empty":      "  \n",
+		"wrong type": `{"schema_version": 3, "bookmarks": "nope"}`,
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir, cleanup := testutil.TempDir(t)
+			defer cleanup()
+
+			s, _ := New(dir)
+			os.WriteFile(s.Path(), []byte(content), 0644)
+
+			_, err := s.Load()
+			if !errors.Is(err, ErrCorrupt) {
+				t.Fatalf("Load() error = %v, want ErrCorrupt", err)
+			}
+			var corrupt *CorruptError
+			if !errors.As(err, &corrupt) || corrupt.Path != s.Path() {
+				t.Errorf("Load() error = %#v, want CorruptError for %s", err, s.Path())
+			}
+		})
+	}
+}
+
+func TestStorage_Check(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s, _ := New(dir)
+	if err := s.Check(); err != nil {
+		t.Errorf("Check() without a library error = %v", err)
+	}
+	s.AddBookmark(testutil.CreateTestBookmark("Go", "https://go.dev", ""))
+	if err := s.Check(); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+	if err := os.WriteFile(s.Path(), []byte(`{"bookmarks": [`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Check(); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Check() of a broken file error = %v, want ErrCorrupt", err)
+	}
+
+	// An encrypted library is not decrypted, so no passphrase is asked for
+	encryptedDir, cleanupEncrypted := testutil.TempDir(t)
+	defer cleanupEncrypted()
+	encrypted := newEncryptedStorage(t, encryptedDir, passphrase("secret"))
+	encrypted.AddBookmark(testutil.CreateTestBookmark("Go", "https://go.dev", ""))
+	locked := newEncryptedStorage(t, encryptedDir, NewPassphraseEncryption(func() ([]byte, error) {
+		t.Error("Check() asked for the passphrase")
+		return nil, errors.New("no passphrase")
+	}))
+	if err := locked.Check(); err != nil {
+		t.Errorf("Check() of an encrypted library error = %v", err)
+	}
+}
+
+func TestStorage_Recover(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s, _ := NewWithOptions(dir, Options{AutoBackup: true, MaxBackups: 5})
+	saveGenerations(t, s, 3)
+
+	if _, err := s.Recover(); err == nil {
+		t.Error("Recover() should refuse to touch a healthy library")
+	}
+
+	// The newest backup is broken as well, so Gen1 is the newest valid one
+	backups, _ := s.ListBackups()
+	os.WriteFile(backups[0].Path, []byte("{"), 0644)
+	bad := []byte(`{"schema_version": 3, "bookmarks": [`)
+	os.WriteFile(s.Path(), bad, 0644)
+
+	result, err := s.Recover()
+	if err != nil {
+		t.Fatalf("Recover() error = %v", err)
+	}
+	if result.Skipped != 1 || result.Backup.Path != backups[1].Path {
+		t.Errorf("Recover() = %+v, want backup %s with 1 skipped", result, backups[1].Path)
+	}
+
+	data, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() after recovery error = %v", err)
+	}
+	if data.Bookmarks[0].Title != "Gen1" {
+		t.Errorf("Recovered title = %v, want Gen1", data.Bookmarks[0].Title)
+	}
+
+	if filepath.Dir(result.Quarantined) != dir {
+		t.Errorf("Quarantined file %s should sit next to the library", result.Quarantined)
+	}
+	if kept, _ := os.ReadFile(result.Quarantined); !bytes.Equal(kept, bad) {
+		t.Errorf("Quarantined file = %q, want the corrupt content", kept)
+	}
+}
+
+func TestStorage_RecoverWithoutBackup(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s, _ := New(dir)
+	bad := []byte("not json")
+	os.WriteFile(s.Path(), bad, 0644)
+
+	if _, err := s.Recover(); !errors.Is(err, ErrNoValidBackup) {
+		t.Errorf("Recover() error = %v, want ErrNoValidBackup", err)
+	}
+	if raw, _ := os.ReadFile(s.Path()); !bytes.Equal(raw, bad) {
+		t.Error("Failed recovery must leave the library in place")
+	}
+}
+
+func TestStorage_RecoverEncrypted(t *testing.T) {
+	tests := map[string]func(raw []byte) []byte{
+		"truncated": func(raw []byte) []byte { return raw[:len(encryptionMagic)+10] },
+		"bad magic": func(raw []byte) []byte {
+			raw[0] ^= 0xff
+			return raw
+		},
+		"bad version": func(raw []byte) []byte {
+			raw[len(encryptionMagic)] = 99
+			return raw
+		},
+	}
+
+	for name, damage := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir, cleanup := testutil.TempDir(t)
+			defer cleanup()
+
+			s := newEncryptedStorage(t, dir, passphrase("secret"))
+			saveGenerations(t, s, 3)
+			raw, _ := os.ReadFile(s.Path())
+			bad := damage(raw)
+			if err := os.WriteFile(s.Path(), bad, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := s.Check(); !errors.Is(err, ErrCorrupt) {
+				t.Errorf("Check() error = %v, want ErrCorrupt", err)
+			}
+			result, err := s.Recover()
+			if err != nil {
+				t.Fatalf("Recover() error = %v", err)
+			}
+			if kept, _ := os.ReadFile(result.Quarantined); !bytes.Equal(kept, bad) {
+				t.Errorf("Quarantined file = %q, want the damaged content", kept)
+			}
+
+			data, err := s.Load()
+			if err != nil {
+				t.Fatalf("Load() after recovery error = %v", err)
+			}
+			if data.Bookmarks[0].Title != "Gen2" {
+				t.Errorf("Recovered title = %v, want Gen2", data.Bookmarks[0].Title)
+			}
+			if raw, _ := os.ReadFile(s.Path()); !isEncrypted(raw) {
+				t.Error("Recovered library should still be encrypted")
+			}
+		})
+	}
+}
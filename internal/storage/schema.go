@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// CurrentSchemaVersion is the schema version written by this build
+const CurrentSchemaVersion = 4
+
+// Files written before schema versioning was introduced carry no
+// schema_version field and are treated as version 1.
+const legacySchemaVersion = 1
+
+// ErrUnsupportedSchema is matched by errors.Is when a file is newer than this build
+var ErrUnsupportedSchema = errors.New("unsupported schema version")
+
+// UnsupportedSchemaError is returned when the library was written by a newer
+// version of ubm. Loading it would silently drop fields on the next save.
+type UnsupportedSchemaError struct {
+	Version int
+}
+
+func (e *UnsupportedSchemaError) Error() string {
+	return fmt.Sprintf("bookmark file has schema version %d, but this version of ubm supports up to %d; please upgrade ubm",
+		e.Version, CurrentSchemaVersion)
+}
+
+func (e *UnsupportedSchemaError) Is(target error) bool {
+	return target == ErrUnsupportedSchema
+}
+
+// document is the generic form of a bookmark file that migrations operate on
+type document map[string]interface{}
+
+// migration upgrades a document from version `from` to `from+1`
+type migration struct {
+	from        int
+	description string
+	apply       func(doc document) error
+}
+
+// migrations must form a contiguous chain ending at CurrentSchemaVersion.
+// To change the file format, bump CurrentSchemaVersion and append a step.
+var migrations = []migration{
+	{
+		from:        1,
+		description: "introduce schema_version and normalize empty lists",
+		apply:       migrateV1ToV2,
+	},
+	{
+		from:        2,
+		description: "add the trash",
+		apply:       migrateV2ToV3,
+	},
+	{
+		from:        3,
+		description: "add bookmark keywords",
+		apply:       migrateV3ToV4,
+	},
+}
+
+func migrateV1ToV2(doc document) error {
+	for _, key := range []string{"bookmarks", "categories"} {
+		if doc[key] == nil {
+			doc[key] = []interface{}{}
+		}
+	}
+
+	bookmarks, ok := doc["bookmarks"].([]interface{})
+	if !ok {
+		return fmt.Errorf("bookmarks is not a list")
+	}
+	for _, item := range bookmarks {
+		// YAML decodes nested mappings into the type of the document
+		b, ok := item.(map[string]interface{})
+		if d, isDoc := item.(document); isDoc {
+			b, ok = d, true
+		}
+		if !ok {
+			return fmt.Errorf("bookmark entry is not an object")
+		}
+		if b["tags"] == nil {
+			b["tags"] = []interface{}{}
+		}
+	}
+
+	return nil
+}
+
+func migrateV2ToV3(doc document) error {
+	if doc["trash"] == nil {
+		doc["trash"] = []interface{}{}
+	}
+	return nil
+}
+
+// migrateV3ToV4 changes nothing: keywords are optional. The version bump
+// makes older builds refuse files that may hold keywords instead of dropping
+// them on the next save.
+func migrateV3ToV4(doc document) error {
+	return nil
+}
+
+// detectSchemaVersion reads only the schema_version field of a raw document
+func detectSchemaVersion(raw []byte, format Format) (int, error) {
+	var header struct {
+		SchemaVersion *int `json:"schema_version" yaml:"schema_version"`
+	}
+	if err := format.unmarshal(raw, &header); err != nil {
+		return 0, err
+	}
+
+	if header.SchemaVersion == nil {
+		return legacySchemaVersion, nil
+	}
+	return *header.SchemaVersion, nil
+}
+
+// migrate upgrades a raw document step by step from the given version to
+// CurrentSchemaVersion. The result is encoded in the same format.
+func migrate(raw []byte, from int, format Format) ([]byte, error) {
+	var doc document
+	if format == FormatJSON {
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+		if err := decoder.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("failed to decode data: %w", err)
+		}
+	} else if err := format.unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode data: %w", err)
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("failed to decode data: document is empty")
+	}
+
+	version := from
+	for _, m := range migrations {
+		if m.from != version {
+			continue
+		}
+		if err := m.apply(doc); err != nil {
+			return nil, fmt.Errorf("failed to migrate schema v%d to v%d (%s): %w", m.from, m.from+1, m.description, err)
+		}
+		version = m.from + 1
+		doc["schema_version"] = version
+	}
+
+	if version != CurrentSchemaVersion {
+		return nil, fmt.Errorf("no migration path from schema v%d to v%d", from, CurrentSchemaVersion)
+	}
+
+	return format.marshal(doc)
+}
+
+// keepPreMigrationCopy stores the untouched original of a file that is about
+// to be migrated. The first copy for a given version is never overwritten.
+func (s *Storage) keepPreMigrationCopy(raw []byte, version int) error {
+	if err := os.MkdirAll(s.backupDir, backupDirFileMode); err != nil {
+		return err
+	}
+
+	raw, err := s.sealPlain(raw)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("bookmarks.pre-migration-v%d%s", version, filepath.Ext(s.filePath))
+	file, err := os.OpenFile(filepath.Join(s.backupDir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, fileMode(s.enc))
+	if err != nil {
+		if os.IsExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if _, err := file.Write(raw); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
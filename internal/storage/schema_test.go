@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tom-023/ubm/internal/testutil"
+)
+
+const legacyDocument = `{
+  "bookmarks": [
+    {
+      "id": "legacy-1",
+      "title": "Legacy",
+      "url": "https://legacy.example.com",
+      "category": "old",
+      "created_at": "2024-01-01T00:00:00Z",
+      "updated_at": "2024-01-01T00:00:00Z",
+      "tags": null
+    }
+  ],
+  "categories": null,
+  "updated_at": "2024-01-01T00:00:00Z"
+}`
+
+func TestMigrationsFormContiguousChain(t *testing.T) {
+	version := legacySchemaVersion
+	for _, m := range migrations {
+		if m.from != version {
+			t.Fatalf("migration from v%d found where v%d was expected", m.from, version)
+		}
+		version++
+	}
+	if version != CurrentSchemaVersion {
+		t.Errorf("migrations end at v%d, want v%d", version, CurrentSchemaVersion)
+	}
+}
+
+func TestStorage_LoadMigratesLegacyFile(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	if err := os.WriteFile(s.filePath, []byte(legacyDocument), 0644); err != nil {
+		t.Fatalf("Failed to write legacy file: %v", err)
+	}
+
+	data, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if data.SchemaVersion != CurrentSchemaVersion {
+		t.Errorf("SchemaVersion = %d, want %d", data.SchemaVersion, CurrentSchemaVersion)
+	}
+	if len(data.Bookmarks) != 1 || data.Bookmarks[0].ID != "legacy-1" {
+		t.Fatalf("Bookmarks not preserved: %+v", data.Bookmarks)
+	}
+	if data.Bookmarks[0].Tags == nil || data.Categories == nil {
+		t.Error("Migration should normalize null lists to empty lists")
+	}
+
+	// The untouched original is kept next to the backups
+	copyPath := filepath.Join(s.backupDir, "bookmarks.pre-migration-v1.json")
+	original, err := os.ReadFile(copyPath)
+	if err != nil {
+		t.Fatalf("Pre-migration copy missing: %v", err)
+	}
+	if string(original) != legacyDocument {
+		t.Error("Pre-migration copy does not match the original file")
+	}
+
+	// Saving writes the current version
+	if err := s.Save(data); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	raw, _ := os.ReadFile(s.filePath)
+	var header struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		t.Fatalf("Failed to decode saved file: %v", err)
+	}
+	if header.SchemaVersion != CurrentSchemaVersion {
+		t.Errorf("Saved schema_version = %d, want %d", header.SchemaVersion, CurrentSchemaVersion)
+	}
+}
+
+func TestStorage_RejectsNewerSchema(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	future := `{"schema_version": 999, "bookmarks": [], "categories": [], "future_field": true}`
+	if err := os.WriteFile(s.filePath, []byte(future), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	_, err = s.Load()
+	if !errors.Is(err, ErrUnsupportedSchema) {
+		t.Errorf("Load() error = %v, want ErrUnsupportedSchema", err)
+	}
+
+	var schemaErr *UnsupportedSchemaError
+	if !errors.As(err, &schemaErr) || schemaErr.Version != 999 {
+		t.Errorf("Expected UnsupportedSchemaError for version 999, got %v", err)
+	}
+
+	// A blind save must not clobber the newer file either
+	if err := s.Save(&Data{}); !errors.Is(err, ErrUnsupportedSchema) {
+		t.Errorf("Save() error = %v, want ErrUnsupportedSchema", err)
+	}
+
+	raw, _ := os.ReadFile(s.filePath)
+	if string(raw) != future {
+		t.Error("Newer file was modified")
+	}
+}
+
+func TestStorage_KeywordsNeedV4(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	v3 := `{"schema_version": 3, "bookmarks": [{"id": "b1", "title": "Go", "url": "https://go.dev", "category": "", "tags": []}], "categories": [], "trash": []}`
+	if err := os.WriteFile(s.filePath, []byte(v3), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	data, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if data.SchemaVersion != 4 || len(data.Bookmarks) != 1 {
+		t.Errorf("Load() = v%d with %d bookmarks, want v4 with 1", data.SchemaVersion, len(data.Bookmarks))
+	}
+
+	data.Bookmarks[0].Keyword = "go"
+	if err := s.Save(data); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := s.Load()
+	if err != nil || loaded.Bookmarks[0].Keyword != "go" {
+		t.Fatalf("Load() = %+v, %v, want the keyword kept", loaded, err)
+	}
+
+	// The version after this one is refused, as v4 files are by v3 builds
+	next := fmt.Sprintf(`{"schema_version": %d, "bookmarks": [], "categories": [], "trash": []}`, CurrentSchemaVersion+1)
+	if err := os.WriteFile(s.filePath, []byte(next), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	var schemaErr *UnsupportedSchemaError
+	if _, err := s.Load(); !errors.As(err, &schemaErr) || schemaErr.Version != CurrentSchemaVersion+1 {
+		t.Errorf("Load() error = %v, want UnsupportedSchemaError", err)
+	}
+}
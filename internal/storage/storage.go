@@ -1,7 +1,8 @@
 package storage
 
 import (
-	"encoding/json"
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,180 +11,420 @@ import (
 	"time"
 
 	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/internal/lockfile"
 )
 
+// Storage is the file backend. It keeps the library in a single JSON or
+// YAML document next to its lock file and backups.
 type Storage struct {
-	filePath   string
-	backupPath string
-	mu         sync.RWMutex
+	filePath    string
+	format      Format
+	backupDir   string
+	lockPath    string
+	lockTimeout time.Duration
+	autoBackup  bool
+	maxBackups  int
+	enc         *Encryption
+	journal     *Journal
+	committer   Committer
+	mu          sync.RWMutex
+}
+
+// Options controls optional storage behaviour
+type Options struct {
+	// AutoBackup keeps a copy of the previous file on every save
+	AutoBackup bool
+	// MaxBackups is the number of backup generations to keep
+	MaxBackups int
+	// Format is used when creating a new library; an existing file keeps its format
+	Format Format
+	// Encryption, if set, encrypts the library, its backups, and the journal
+	Encryption *Encryption
+	// Committer, if set, records every save of the library, e.g. in git
+	Committer Committer
+}
+
+// DefaultOptions returns the options used by New
+func DefaultOptions() Options {
+	return Options{
+		AutoBackup: true,
+		MaxBackups: defaultMaxBackups,
+		Format:     FormatJSON,
+	}
 }
 
 type Data struct {
-	Bookmarks  []*bookmark.Bookmark `json:"bookmarks"`
-	Categories []string            `json:"categories"`
-	UpdatedAt  time.Time           `json:"updated_at"`
+	SchemaVersion int                  `json:"schema_version" yaml:"schema_version"`
+	Bookmarks     []*bookmark.Bookmark `json:"bookmarks" yaml:"bookmarks"`
+	Categories    []string             `json:"categories" yaml:"categories"`
+	Trash         []*TrashedBookmark   `json:"trash" yaml:"trash"`
+	UpdatedAt     time.Time            `json:"updated_at" yaml:"updated_at"`
 }
 
 func New(configDir string) (*Storage, error) {
+	return NewWithOptions(configDir, DefaultOptions())
+}
+
+func NewWithOptions(configDir string, opts Options) (*Storage, error) {
 	if err := os.MkdirAll(configDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create config directory: %w", err)
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	if opts.MaxBackups <= 0 {
+		opts.MaxBackups = defaultMaxBackups
+	}
+	if opts.Format == "" {
+		opts.Format = FormatJSON
 	}
 
+	format := resolveFormat(configDir, opts.Format)
+
 	return &Storage{
-		filePath:   filepath.Join(configDir, "bookmarks.json"),
-		backupPath: filepath.Join(configDir, "bookmarks.backup.json"),
+		filePath:    filepath.Join(configDir, format.fileName()),
+		format:      format,
+		backupDir:   filepath.Join(configDir, "backups"),
+		lockPath:    filepath.Join(configDir, lockfile.FileName),
+		lockTimeout: lockfile.DefaultTimeout,
+		autoBackup:  opts.AutoBackup,
+		maxBackups:  opts.MaxBackups,
+		enc:         opts.Encryption,
+		journal:     newJournal(configDir, opts.Encryption),
+		committer:   opts.Committer,
 	}, nil
 }
 
+// resolveFormat picks the format of an existing library file, preferring the
+// requested one if both exist, and falls back to the requested format
+func resolveFormat(dir string, preferred Format) Format {
+	for _, format := range []Format{preferred, FormatJSON, FormatYAML} {
+		if _, err := os.Stat(filepath.Join(dir, format.fileName())); err == nil {
+			return format
+		}
+	}
+	return preferred
+}
+
+// Path returns the location of the bookmark file
+func (s *Storage) Path() string {
+	return s.filePath
+}
+
+// Format returns the encoding the library is saved in
+func (s *Storage) Format() Format {
+	return s.format
+}
+
+// Journal returns the log of changes made to the library
+func (s *Storage) Journal() *Journal {
+	return s.journal
+}
+
+// SetLockTimeout sets how long to wait for another process to release the library
+func (s *Storage) SetLockTimeout(timeout time.Duration) {
+	s.lockTimeout = timeout
+}
+
+// withLock runs fn while holding both the in-process mutex and the OS-level
+// file lock, so a whole read-modify-write cycle is atomic across processes.
+func (s *Storage) withLock(exclusive bool, fn func() error) error {
+	if exclusive {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	} else {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+
+	lock, err := lockfile.Acquire(s.lockPath, exclusive, s.lockTimeout)
+	if err != nil {
+		return err
+	}
+
+	fnErr := fn()
+	if err := lock.Release(); err != nil && fnErr == nil {
+		return err
+	}
+	return fnErr
+}
+
 func (s *Storage) Load() (*Data, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	var data *Data
+	err := s.withLock(false, func() error {
+		var err error
+		data, err = s.load()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *Storage) load() (*Data, error) {
+	return s.loadFile(s.filePath)
+}
 
-	file, err := os.Open(s.filePath)
+func (s *Storage) loadFile(path string) (*Data, error) {
+	raw, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return &Data{
-				Bookmarks:  []*bookmark.Bookmark{},
-				Categories: []string{},
-				UpdatedAt:  time.Now(),
+				SchemaVersion: CurrentSchemaVersion,
+				Bookmarks:     []*bookmark.Bookmark{},
+				Categories:    []string{},
+				Trash:         []*TrashedBookmark{},
+				UpdatedAt:     time.Now(),
 			}, nil
 		}
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
-	defer file.Close()
 
-	var data Data
-	if err := json.NewDecoder(file).Decode(&data); err != nil {
-		return nil, fmt.Errorf("failed to decode data: %w", err)
-	}
-
-	return &data, nil
+	return s.decode(path, raw)
 }
 
-func (s *Storage) Save(data *Data) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// Decode reads a library from the contents of a file, e.g. an older revision
+// kept in git. It is decrypted with the storage's key and migrated in memory.
+func (s *Storage) Decode(raw []byte) (*Data, error) {
+	return s.decode("", raw)
+}
 
-	data.UpdatedAt = time.Now()
+// ReadRaw returns the library file as it is stored, encrypted or not. Like
+// Load it reads under the library lock, so it never sees a save of another
+// process half done.
+func (s *Storage) ReadRaw() ([]byte, error) {
+	var raw []byte
+	err := s.withLock(false, func() error {
+		var err error
+		raw, err = os.ReadFile(s.filePath)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
 
-	// Create backup if original file exists
-	if _, err := os.Stat(s.filePath); err == nil {
-		if err := s.createBackup(); err != nil {
-			return fmt.Errorf("failed to create backup: %w", err)
-		}
+func (s *Storage) decode(path string, raw []byte) (*Data, error) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, &CorruptError{Path: path, Err: errors.New("file is empty")}
 	}
 
-	// Marshal data
-	jsonData, err := json.MarshalIndent(data, "", "  ")
+	plaintext, err := decryptFile(s.enc, raw)
+	if errors.Is(err, errEnvelope) || errors.Is(err, ErrNotEncrypted) {
+		return nil, &CorruptError{Path: path, Err: err}
+	}
 	if err != nil {
-		return fmt.Errorf("failed to marshal data: %w", err)
+		return nil, err
 	}
 
-	// Write to temporary file first
-	tmpFile := s.filePath + ".tmp"
-	if err := os.WriteFile(tmpFile, jsonData, 0644); err != nil {
-		return fmt.Errorf("failed to write temporary file: %w", err)
+	format := detectFormat(plaintext)
+	version, err := detectSchemaVersion(plaintext, format)
+	if err != nil {
+		return nil, &CorruptError{Path: path, Err: err}
 	}
 
-	// Rename temporary file to actual file
-	if err := os.Rename(tmpFile, s.filePath); err != nil {
-		return fmt.Errorf("failed to save file: %w", err)
+	if version > CurrentSchemaVersion {
+		return nil, &UnsupportedSchemaError{Version: version}
 	}
 
-	return nil
-}
+	if version < CurrentSchemaVersion {
+		// Keep the original around before the migrated form is ever saved
+		if path != "" && path == s.filePath {
+			if err := s.keepPreMigrationCopy(raw, version); err != nil {
+				return nil, fmt.Errorf("failed to keep pre-migration copy: %w", err)
+			}
+		}
 
-func (s *Storage) createBackup() error {
-	src, err := os.Open(s.filePath)
-	if err != nil {
-		return err
+		plaintext, err = migrate(plaintext, version, format)
+		if err != nil {
+			return nil, err
+		}
 	}
-	defer src.Close()
 
-	dst, err := os.Create(s.backupPath)
-	if err != nil {
-		return err
+	var data Data
+	if err := format.unmarshal(plaintext, &data); err != nil {
+		return nil, &CorruptError{Path: path, Err: err}
 	}
-	defer dst.Close()
+	data.dropEmptyEntries()
 
-	_, err = dst.ReadFrom(src)
-	return err
+	return &data, nil
 }
 
-func (s *Storage) AddBookmark(b *bookmark.Bookmark) error {
-	data, err := s.Load()
-	if err != nil {
-		return err
+// ReadFile loads a library file that ubm must not write to, such as a shared
+// team library. It takes no lock, and an older schema is migrated in memory
+// only.
+func ReadFile(path string) (*Data, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
+	return (&Storage{}).loadFile(path)
+}
 
-	// Check for duplicate URL in the same category
-	for _, existing := range data.Bookmarks {
-		if existing.URL == b.URL && existing.Category == b.Category {
-			return fmt.Errorf("bookmark with URL %s already exists in category %s", b.URL, b.Category)
+// dropEmptyEntries removes null list entries left behind by hand edits. They
+// carry no data, and everything else assumes bookmarks are never nil.
+func (d *Data) dropEmptyEntries() {
+	bookmarks := d.Bookmarks[:0]
+	for _, b := range d.Bookmarks {
+		if b != nil {
+			bookmarks = append(bookmarks, b)
 		}
 	}
+	d.Bookmarks = bookmarks
 
-	data.Bookmarks = append(data.Bookmarks, b)
-	
-	// Add category if it doesn't exist
-	categoryExists := false
-	for _, cat := range data.Categories {
-		if cat == b.Category {
-			categoryExists = true
-			break
+	trash := d.Trash[:0]
+	for _, t := range d.Trash {
+		if t != nil && t.Bookmark != nil {
+			trash = append(trash, t)
 		}
 	}
-	if !categoryExists && b.Category != "" {
-		data.Categories = append(data.Categories, b.Category)
-	}
+	d.Trash = trash
+}
 
-	return s.Save(data)
+// Save replaces the library with data. If data was loaded from the library
+// and someone else saved since, a *ConflictError is returned instead.
+func (s *Storage) Save(data *Data) error {
+	return s.withLock(true, func() error {
+		before := s.previous()
+		if _, err := os.Stat(s.filePath); err == nil {
+			if err := checkRevision(data, before, s.journal); err != nil {
+				return err
+			}
+		}
+		return s.save(before, data, txMeta{})
+	})
 }
 
-func (s *Storage) UpdateBookmark(b *bookmark.Bookmark) error {
-	data, err := s.Load()
+// previous returns the library as currently saved, or nil if it cannot be
+// read. It is only used to describe a full replace in the journal.
+func (s *Storage) previous() *Data {
+	data, err := s.load()
 	if err != nil {
-		return err
+		return nil
 	}
+	return data
+}
 
-	found := false
-	for i, existing := range data.Bookmarks {
-		if existing.ID == b.ID {
-			data.Bookmarks[i] = b
-			found = true
-			break
+// save writes data and journals how it differs from before. A nil before
+// skips the journal, e.g. when the file being replaced was unreadable.
+func (s *Storage) save(before, data *Data, meta txMeta) error {
+	// Never overwrite a file written by a newer version of ubm, or an
+	// encrypted one the configured key cannot open
+	if raw, err := os.ReadFile(s.filePath); err == nil {
+		plaintext, err := decryptFile(s.enc, raw)
+		if err != nil {
+			return err
+		}
+		if version, err := detectSchemaVersion(plaintext, detectFormat(plaintext)); err == nil && version > CurrentSchemaVersion {
+			return &UnsupportedSchemaError{Version: version}
 		}
 	}
 
-	if !found {
-		return fmt.Errorf("bookmark with ID %s not found", b.ID)
+	data.SchemaVersion = CurrentSchemaVersion
+	data.UpdatedAt = time.Now()
+	if data.Trash == nil {
+		data.Trash = []*TrashedBookmark{}
 	}
 
-	return s.Save(data)
-}
+	// Create backup if original file exists
+	if _, err := os.Stat(s.filePath); err == nil && s.autoBackup {
+		if err := s.createBackup(); err != nil {
+			return fmt.Errorf("failed to create backup: %w", err)
+		}
+	}
 
-func (s *Storage) DeleteBookmark(id string) error {
-	data, err := s.Load()
-	if err != nil {
+	if err := s.writeFile(data); err != nil {
 		return err
 	}
 
-	bookmarks := []*bookmark.Bookmark{}
-	found := false
-	for _, b := range data.Bookmarks {
-		if b.ID != id {
-			bookmarks = append(bookmarks, b)
-		} else {
-			found = true
+	if before != nil {
+		if err := s.journal.record(before, data, meta); err != nil {
+			return fmt.Errorf("bookmarks were saved but the journal was not updated: %w", err)
 		}
 	}
 
-	if !found {
-		return fmt.Errorf("bookmark with ID %s not found", id)
+	if s.committer != nil {
+		if before == nil {
+			before = &Data{}
+		}
+		if err := s.committer.Commit(s.filePath, commitMessage(diffData(before, data), meta)); err != nil {
+			return fmt.Errorf("bookmarks were saved but not committed: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeFile atomically replaces the library file with data in s.format
+func (s *Storage) writeFile(data *Data) error {
+	// Marshal data
+	encoded, err := s.format.marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data: %w", err)
+	}
+
+	encoded, err = encryptFile(s.enc, encoded)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt data: %w", err)
+	}
+
+	// Write to temporary file first
+	tmpFile := s.filePath + ".tmp"
+	if err := os.WriteFile(tmpFile, encoded, fileMode(s.enc)); err != nil {
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	// Rename temporary file to actual file
+	if err := os.Rename(tmpFile, s.filePath); err != nil {
+		return fmt.Errorf("failed to save file: %w", err)
 	}
 
-	data.Bookmarks = bookmarks
-	return s.Save(data)
+	return nil
+}
+
+// Update runs fn against freshly loaded data and saves the result, all while
+// holding the library lock. If fn returns an error nothing is written, so any
+// changes it made are rolled back.
+func (s *Storage) Update(fn func(*Data) error) error {
+	return s.updateTx(func(data *Data, _ *txMeta) error {
+		return fn(data)
+	})
+}
+
+// updateTx is Update for callers that tag the journal entries, such as undo
+func (s *Storage) updateTx(fn func(*Data, *txMeta) error) error {
+	return s.withLock(true, func() error {
+		data, err := s.load()
+		if err != nil {
+			return err
+		}
+
+		before := data.Clone()
+
+		var meta txMeta
+		if err := fn(data, &meta); err != nil {
+			return err
+		}
+
+		return s.save(before, data, meta)
+	})
+}
+
+func (s *Storage) AddBookmark(b *bookmark.Bookmark) error {
+	return s.Update(func(data *Data) error {
+		return data.AddBookmark(b)
+	})
+}
+
+func (s *Storage) UpdateBookmark(b *bookmark.Bookmark) error {
+	return s.Update(func(data *Data) error {
+		return data.UpdateBookmark(b)
+	})
+}
+
+// DeleteBookmark moves the bookmark to the trash
+func (s *Storage) DeleteBookmark(id string) error {
+	return s.Update(func(data *Data) error {
+		return data.TrashBookmark(id, time.Now())
+	})
 }
 
 func (s *Storage) GetBookmark(id string) (*bookmark.Bookmark, error) {
@@ -192,13 +433,7 @@ func (s *Storage) GetBookmark(id string) (*bookmark.Bookmark, error) {
 		return nil, err
 	}
 
-	for _, b := range data.Bookmarks {
-		if b.ID == id {
-			return b, nil
-		}
-	}
-
-	return nil, fmt.Errorf("bookmark with ID %s not found", id)
+	return data.GetBookmark(id)
 }
 
 func (s *Storage) GetBookmarksByCategory(category string) ([]*bookmark.Bookmark, error) {
@@ -207,14 +442,7 @@ func (s *Storage) GetBookmarksByCategory(category string) ([]*bookmark.Bookmark,
 		return nil, err
 	}
 
-	bookmarks := []*bookmark.Bookmark{}
-	for _, b := range data.Bookmarks {
-		if b.Category == category {
-			bookmarks = append(bookmarks, b)
-		}
-	}
-
-	return bookmarks, nil
+	return data.BookmarksByCategory(category), nil
 }
 
 func (s *Storage) SearchBookmarks(query string) ([]*bookmark.Bookmark, error) {
@@ -223,16 +451,9 @@ func (s *Storage) SearchBookmarks(query string) ([]*bookmark.Bookmark, error) {
 		return nil, err
 	}
 
-	bookmarks := []*bookmark.Bookmark{}
-	for _, b := range data.Bookmarks {
-		if containsIgnoreCase(b.Title, query) || containsIgnoreCase(b.URL, query) || containsIgnoreCase(b.Description, query) {
-			bookmarks = append(bookmarks, b)
-		}
-	}
-
-	return bookmarks, nil
+	return data.Search(query), nil
 }
 
 func containsIgnoreCase(s, substr string) bool {
 	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
-}
\ No newline at end of file
+}
@@ -2,6 +2,8 @@ package storage
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -32,9 +34,9 @@ func TestNew(t *testing.T) {
 		t.Errorf("filePath = %v, want %v", s.filePath, expectedPath)
 	}
 
-	expectedBackupPath := filepath.Join(configDir, "bookmarks.backup.json")
-	if s.backupPath != expectedBackupPath {
-		t.Errorf("backupPath = %v, want %v", s.backupPath, expectedBackupPath)
+	expectedBackupDir := filepath.Join(configDir, "backups")
+	if s.backupDir != expectedBackupDir {
+		t.Errorf("backupDir = %v, want %v", s.backupDir, expectedBackupDir)
 	}
 
 	// Check that directory was created
@@ -155,7 +157,12 @@ func TestStorage_Backup(t *testing.T) {
 	}
 
 	// Check backup file exists and contains original data
-	backupData, err := os.ReadFile(s.backupPath)
+	backups, err := s.ListBackups()
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("Expected 1 backup, got %d (err = %v)", len(backups), err)
+	}
+
+	backupData, err := os.ReadFile(backups[0].Path)
 	if err != nil {
 		t.Fatalf("Failed to read backup file: %v", err)
 	}
@@ -468,6 +475,120 @@ func TestStorage_SearchBookmarks(t *testing.T) {
 	}
 }
 
+func TestStorage_Update(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	b := testutil.CreateTestBookmark("Test", "https://test.com", "old")
+	if err := s.AddBookmark(b); err != nil {
+		t.Fatalf("AddBookmark() error = %v", err)
+	}
+
+	// Move the bookmark and register its new category in one transaction
+	err = s.Update(func(data *Data) error {
+		target := data.FindBookmark(b.ID)
+		if target == nil {
+			t.Fatal("FindBookmark() returned nil inside Update")
+		}
+		target.Category = "new"
+		data.AddCategory("new")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	data, _ := s.Load()
+	if data.Bookmarks[0].Category != "new" {
+		t.Errorf("Category = %v, want new", data.Bookmarks[0].Category)
+	}
+	if !reflect.DeepEqual(data.Categories, []string{"old", "new"}) {
+		t.Errorf("Categories = %v, want [old new]", data.Categories)
+	}
+}
+
+func TestStorage_UpdateRollback(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	b := testutil.CreateTestBookmark("Test", "https://test.com", "test")
+	if err := s.AddBookmark(b); err != nil {
+		t.Fatalf("AddBookmark() error = %v", err)
+	}
+
+	before, err := os.ReadFile(s.filePath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	wantErr := errors.New("abort")
+	err = s.Update(func(data *Data) error {
+		data.Bookmarks[0].Title = "Changed"
+		data.Bookmarks = append(data.Bookmarks, testutil.CreateTestBookmark("Extra", "https://extra.com", "test"))
+		return wantErr
+	})
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("Update() error = %v, want %v", err, wantErr)
+	}
+
+	after, err := os.ReadFile(s.filePath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if string(before) != string(after) {
+		t.Error("File was modified by a failed Update()")
+	}
+}
+
+func TestStorage_UpdateConcurrent(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	const workers = 10
+
+	done := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		s, err := New(dir)
+		if err != nil {
+			t.Fatalf("Failed to create storage: %v", err)
+		}
+		go func(i int) {
+			// Each transaction reads the current count, so lost updates would show up
+			done <- s.Update(func(data *Data) error {
+				data.Categories = append(data.Categories, fmt.Sprintf("cat%d", len(data.Categories)))
+				return nil
+			})
+		}(i)
+	}
+
+	for i := 0; i < workers; i++ {
+		if err := <-done; err != nil {
+			t.Errorf("Update() error = %v", err)
+		}
+	}
+
+	s, _ := New(dir)
+	data, _ := s.Load()
+	if len(data.Categories) != workers {
+		t.Fatalf("Expected %d categories, got %d", workers, len(data.Categories))
+	}
+	for i, cat := range data.Categories {
+		if want := fmt.Sprintf("cat%d", i); cat != want {
+			t.Errorf("Categories[%d] = %v, want %v", i, cat, want)
+		}
+	}
+}
+
 func TestStorage_ConcurrentAccess(t *testing.T) {
 	dir, cleanup := testutil.TempDir(t)
 	defer cleanup()
@@ -541,4 +662,26 @@ func TestContainsIgnoreCase(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestStorage_LoadDropsNullEntries(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s, _ := New(dir)
+	content := `{"schema_version": 3, "bookmarks": [null, {"id": "1", "title": "Go", "url": "https://go.dev"}], "categories": [], "trash": [null, {"deleted_at": "2024-01-01T00:00:00Z"}]}`
+	if err := os.WriteFile(s.Path(), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(data.Bookmarks) != 1 || data.Bookmarks[0].ID != "1" {
+		t.Errorf("Bookmarks = %v, want only the non-null entry", data.Bookmarks)
+	}
+	if len(data.Trash) != 0 {
+		t.Errorf("Trash = %v, want entries without a bookmark dropped", data.Trash)
+	}
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tom-023/ubm/internal/bookmark"
+)
+
+// TrashedBookmark is a deleted bookmark waiting in the trash. The bookmark
+// keeps its original category so it can be restored to where it was.
+type TrashedBookmark struct {
+	Bookmark  *bookmark.Bookmark `json:"bookmark" yaml:"bookmark"`
+	DeletedAt time.Time          `json:"deleted_at" yaml:"deleted_at"`
+}
+
+// TrashBookmark moves the bookmark with the given ID to the trash
+func (d *Data) TrashBookmark(id string, at time.Time) error {
+	b := d.FindBookmark(id)
+	if b == nil {
+		return fmt.Errorf("bookmark with ID %s not found", id)
+	}
+
+	if err := d.DeleteBookmark(id); err != nil {
+		return err
+	}
+	d.Trash = append(d.Trash, &TrashedBookmark{Bookmark: b, DeletedAt: at})
+	return nil
+}
+
+// FindTrashed returns the trashed bookmark with the given ID, or nil
+func (d *Data) FindTrashed(id string) *TrashedBookmark {
+	for _, t := range d.Trash {
+		if t.Bookmark.ID == id {
+			return t
+		}
+	}
+	return nil
+}
+
+// RestoreBookmark moves a bookmark out of the trash back into its original
+// category, recreating the category if it was deleted in the meantime
+func (d *Data) RestoreBookmark(id string) (*bookmark.Bookmark, error) {
+	t := d.FindTrashed(id)
+	if t == nil {
+		return nil, fmt.Errorf("bookmark with ID %s is not in the trash", id)
+	}
+
+	if err := d.AddBookmark(t.Bookmark); err != nil {
+		return nil, err
+	}
+	d.removeTrashed(id)
+	return t.Bookmark, nil
+}
+
+// PurgeTrash permanently removes trashed bookmarks deleted before cutoff and
+// returns how many were removed. A zero cutoff empties the trash.
+func (d *Data) PurgeTrash(cutoff time.Time) int {
+	kept := []*TrashedBookmark{}
+	if !cutoff.IsZero() {
+		for _, t := range d.Trash {
+			if !t.DeletedAt.Before(cutoff) {
+				kept = append(kept, t)
+			}
+		}
+	}
+	removed := len(d.Trash) - len(kept)
+	d.Trash = kept
+	return removed
+}
+
+// SearchTrash is like Search for the trashed bookmarks
+func (d *Data) SearchTrash(query string) []*TrashedBookmark {
+	trashed := []*TrashedBookmark{}
+	for _, t := range d.Trash {
+		if matchesQuery(t.Bookmark, query) {
+			trashed = append(trashed, t)
+		}
+	}
+	return trashed
+}
+
+func (d *Data) removeTrashed(id string) bool {
+	for i, t := range d.Trash {
+		if t.Bookmark.ID == id {
+			d.Trash = append(d.Trash[:i:i], d.Trash[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// PurgeExpiredTrash removes bookmarks that have been in the trash for longer
+// than maxAge. The library is only rewritten when something expired. The
+// purge is journaled as an automatic change, so undo skips it instead of
+// reverting it in place of the user's last command.
+func PurgeExpiredTrash(b Backend, maxAge time.Duration) (int, error) {
+	cutoff := time.Now().Add(-maxAge)
+
+	data, err := b.Load()
+	if err != nil {
+		return 0, err
+	}
+	expired := false
+	for _, t := range data.Trash {
+		if t.DeletedAt.Before(cutoff) {
+			expired = true
+			break
+		}
+	}
+	if !expired {
+		return 0, nil
+	}
+
+	var removed int
+	purge := func(data *Data, meta *txMeta) error {
+		meta.automatic = true
+		removed = data.PurgeTrash(cutoff)
+		return nil
+	}
+	if updater, ok := b.(historyUpdater); ok {
+		err = updater.updateTx(purge)
+	} else {
+		err = b.Update(func(data *Data) error { return purge(data, &txMeta{}) })
+	}
+	return removed, err
+}
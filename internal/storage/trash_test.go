@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tom-023/ubm/internal/testutil"
+)
+
+func TestData_RestoreBookmark(t *testing.T) {
+	data := &Data{Categories: []string{}}
+	b := testutil.CreateTestBookmark("Go", "https://go.dev", "programming")
+	data.AddBookmark(b)
+
+	if err := data.TrashBookmark(b.ID, time.Now()); err != nil {
+		t.Fatalf("TrashBookmark() error = %v", err)
+	}
+	// The category goes away while the bookmark is in the trash
+	data.Categories = []string{}
+
+	restored, err := data.RestoreBookmark(b.ID)
+	if err != nil {
+		t.Fatalf("RestoreBookmark() error = %v", err)
+	}
+	if restored.Category != "programming" || !data.HasCategory("programming") {
+		t.Error("RestoreBookmark() should put the bookmark back into its original, recreated category")
+	}
+
+	// A bookmark with the same URL was added in the meantime
+	data.TrashBookmark(b.ID, time.Now())
+	data.AddBookmark(testutil.CreateTestBookmark("Go again", "https://go.dev", "programming"))
+	if _, err := data.RestoreBookmark(b.ID); err == nil {
+		t.Error("RestoreBookmark() should refuse a duplicate URL")
+	}
+	if data.FindTrashed(b.ID) == nil {
+		t.Error("A failed restore must leave the bookmark in the trash")
+	}
+}
+
+func TestData_PurgeTrash(t *testing.T) {
+	now := time.Now()
+	data := &Data{Categories: []string{}}
+	for i, age := range []time.Duration{time.Hour, 48 * time.Hour, 72 * time.Hour} {
+		b := testutil.CreateTestBookmark("B", "https://example.com/"+string(rune('a'+i)), "")
+		data.AddBookmark(b)
+		data.TrashBookmark(b.ID, now.Add(-age))
+	}
+
+	if removed := data.PurgeTrash(now.Add(-24 * time.Hour)); removed != 2 {
+		t.Errorf("PurgeTrash(24h) removed %d, want 2", removed)
+	}
+	if len(data.Trash) != 1 {
+		t.Errorf("Trash has %d entries, want 1", len(data.Trash))
+	}
+	if removed := data.PurgeTrash(time.Time{}); removed != 1 || len(data.Trash) != 0 {
+		t.Errorf("PurgeTrash(zero) should empty the trash, removed %d", removed)
+	}
+}
+
+func TestPurgeExpiredTrash(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	old := testutil.CreateTestBookmark("Old", "https://old.example", "")
+	recent := testutil.CreateTestBookmark("Recent", "https://recent.example", "")
+	s.AddBookmark(old)
+	s.AddBookmark(recent)
+	s.Update(func(data *Data) error {
+		data.TrashBookmark(old.ID, time.Now().Add(-40*24*time.Hour))
+		return data.TrashBookmark(recent.ID, time.Now())
+	})
+
+	removed, err := PurgeExpiredTrash(s, 30*24*time.Hour)
+	if err != nil {
+		t.Fatalf("PurgeExpiredTrash() error = %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("PurgeExpiredTrash() removed %d, want 1", removed)
+	}
+
+	// Nothing else has expired, so the file must not be rewritten
+	before, _ := os.Stat(s.filePath)
+	backups, _ := s.ListBackups()
+	if removed, _ := PurgeExpiredTrash(s, 30*24*time.Hour); removed != 0 {
+		t.Errorf("Second PurgeExpiredTrash() removed %d, want 0", removed)
+	}
+	after, _ := os.Stat(s.filePath)
+	backupsAfter, _ := s.ListBackups()
+	if !after.ModTime().Equal(before.ModTime()) || len(backupsAfter) != len(backups) {
+		t.Error("PurgeExpiredTrash() rewrote the library although nothing expired")
+	}
+}
+
+func TestTrash_JournalAndUndo(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	b := testutil.CreateTestBookmark("Go", "https://go.dev", "")
+	s.AddBookmark(b)
+	s.DeleteBookmark(b.ID)
+	s.Update(func(data *Data) error {
+		data.PurgeTrash(time.Time{})
+		return nil
+	})
+
+	entries, _ := s.Journal().Entries(JournalFilter{BookmarkID: b.ID})
+	if len(entries) != 3 || entries[2].Op != OpPurge {
+		t.Fatalf("Journal = %+v, want add, trash, purge", entries)
+	}
+
+	// Undoing the purge puts the bookmark back into the trash with its
+	// original deletion time, undoing the trash puts it back in the library
+	if _, err := Undo(s, ""); err != nil {
+		t.Fatalf("Undo(purge) error = %v", err)
+	}
+	data, _ := s.Load()
+	trashed := data.FindTrashed(b.ID)
+	if trashed == nil || !trashed.DeletedAt.Equal(*entries[1].DeletedAt) {
+		t.Fatalf("Undo(purge) should restore the trash entry, got %+v", trashed)
+	}
+
+	if _, err := Undo(s, ""); err != nil {
+		t.Fatalf("Undo(trash) error = %v", err)
+	}
+	if _, err := s.GetBookmark(b.ID); err != nil {
+		t.Errorf("Undo(trash) should restore the bookmark: %v", err)
+	}
+}
+
+func TestMigrateV2AddsTrash(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	v2 := `{"schema_version": 2, "bookmarks": [], "categories": [], "updated_at": "2024-01-01T00:00:00Z"}`
+	if err := os.WriteFile(filepath.Join(dir, "bookmarks.json"), []byte(v2), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	s, _ := New(dir)
+	data, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if data.Trash == nil || data.SchemaVersion != CurrentSchemaVersion {
+		t.Errorf("Migrated data = %+v, want an empty trash at v%d", data, CurrentSchemaVersion)
+	}
+}
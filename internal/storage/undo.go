@@ -0,0 +1,291 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tom-023/ubm/internal/bookmark"
+)
+
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+	// ErrHistoryChanged means another change was made between previewing and
+	// applying an undo or redo
+	ErrHistoryChanged = errors.New("history changed since the preview")
+)
+
+// Step is one transaction from the journal, i.e. what a single command
+// changed. Undo and redo always move by whole steps.
+type Step struct {
+	Tx      string
+	Time    time.Time
+	User    string
+	Entries []JournalEntry
+}
+
+// History is the undo and redo stacks rebuilt from the journal. The last
+// element of each slice is the step that undo or redo applies next.
+type History struct {
+	Undo []Step
+	Redo []Step
+}
+
+// historyUpdater is implemented by the journaled backends
+type historyUpdater interface {
+	Journaled
+	updateTx(fn func(*Data, *txMeta) error) error
+}
+
+// LoadHistory rebuilds the undo and redo stacks of a backend. Because the
+// stacks live in the journal, they are shared by every ubm invocation.
+func LoadHistory(b Backend) (*History, error) {
+	journaled, ok := b.(Journaled)
+	if !ok {
+		return nil, fmt.Errorf("this storage backend does not keep a journal")
+	}
+	return journaled.Journal().History()
+}
+
+// History replays the journal: a regular change is pushed on the undo stack
+// and clears the redo stack, an undo moves its step to the redo stack, and a
+// redo moves it back. Automatic changes are on neither stack.
+func (j *Journal) History() (*History, error) {
+	entries, err := j.Entries(JournalFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	h := &History{Undo: []Step{}, Redo: []Step{}}
+	for _, step := range groupSteps(entries) {
+		first := step.Entries[0]
+		switch {
+		case first.Automatic:
+			continue
+		case first.Undoes != "":
+			if original, ok := takeStep(&h.Undo, first.Undoes); ok {
+				h.Redo = append(h.Redo, original)
+			}
+		case first.Redoes != "":
+			if original, ok := takeStep(&h.Redo, first.Redoes); ok {
+				h.Undo = append(h.Undo, original)
+			}
+		default:
+			h.Undo = append(h.Undo, step)
+			h.Redo = h.Redo[:0]
+		}
+	}
+	return h, nil
+}
+
+func groupSteps(entries []JournalEntry) []Step {
+	var steps []Step
+	for _, e := range entries {
+		if n := len(steps); n > 0 && steps[n-1].Tx == e.Tx {
+			steps[n-1].Entries = append(steps[n-1].Entries, e)
+			continue
+		}
+		steps = append(steps, Step{Tx: e.Tx, Time: e.Time, User: e.User, Entries: []JournalEntry{e}})
+	}
+	return steps
+}
+
+// takeStep removes the step with the given transaction from stack
+func takeStep(stack *[]Step, tx string) (Step, bool) {
+	for i := len(*stack) - 1; i >= 0; i-- {
+		if (*stack)[i].Tx == tx {
+			step := (*stack)[i]
+			*stack = append((*stack)[:i], (*stack)[i+1:]...)
+			return step, true
+		}
+	}
+	return Step{}, false
+}
+
+// Undo reverts the most recent step. If expect is not empty the step must be
+// the one with that transaction, so a preview shown to the user is exactly
+// what gets reverted.
+func Undo(b Backend, expect string) (*Step, error) {
+	return moveStep(b, expect, false)
+}
+
+// Redo re-applies the most recently undone step. expect works as for Undo.
+func Redo(b Backend, expect string) (*Step, error) {
+	return moveStep(b, expect, true)
+}
+
+func moveStep(b Backend, expect string, redo bool) (*Step, error) {
+	updater, ok := b.(historyUpdater)
+	if !ok {
+		return nil, fmt.Errorf("this storage backend does not keep a journal")
+	}
+
+	var applied Step
+	err := updater.updateTx(func(data *Data, meta *txMeta) error {
+		// Read the history under the library lock so nobody changes it
+		// between choosing the step and writing its revert
+		h, err := updater.Journal().History()
+		if err != nil {
+			return err
+		}
+
+		stack, empty := h.Undo, ErrNothingToUndo
+		if redo {
+			stack, empty = h.Redo, ErrNothingToRedo
+		}
+		if len(stack) == 0 {
+			return empty
+		}
+		step := stack[len(stack)-1]
+		if expect != "" && step.Tx != expect {
+			return ErrHistoryChanged
+		}
+
+		if redo {
+			meta.redoes = step.Tx
+			err = step.apply(data)
+		} else {
+			meta.undoes = step.Tx
+			err = step.revert(data)
+		}
+		if err != nil {
+			return err
+		}
+
+		applied = step
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &applied, nil
+}
+
+// revert undoes the entries of the step, last one first
+func (s Step) revert(data *Data) error {
+	for i := len(s.Entries) - 1; i >= 0; i-- {
+		e := s.Entries[i]
+		if err := applyEntry(data, e, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply performs the entries of the step again
+func (s Step) apply(data *Data) error {
+	for _, e := range s.Entries {
+		if err := applyEntry(data, e, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyEntry performs a single entry, or its inverse if reverse is set.
+// It refuses to touch a bookmark that no longer looks like it did when the
+// entry was written, because then the change was overwritten by something
+// that is not in the journal.
+func applyEntry(data *Data, e JournalEntry, reverse bool) error {
+	from, to := e.Before, e.After
+	if reverse {
+		from, to = to, from
+	}
+
+	switch e.Op {
+	case OpCategoryCreate, OpCategoryDelete:
+		if (e.Op == OpCategoryCreate) == reverse {
+			removeCategory(data, e.Category)
+		} else {
+			data.AddCategory(e.Category)
+		}
+		return nil
+
+	case OpTrash, OpRestore:
+		b := e.Before
+		if e.Op == OpRestore {
+			b = e.After
+		}
+		if (e.Op == OpTrash) != reverse {
+			return moveToTrash(data, b, e.deletedAt())
+		}
+		return moveFromTrash(data, b)
+
+	case OpPurge:
+		if to != nil {
+			if data.FindTrashed(e.BookmarkID) != nil {
+				return fmt.Errorf("bookmark %s is already in the trash", e.BookmarkID)
+			}
+			data.Trash = append(data.Trash, &TrashedBookmark{Bookmark: cloneBookmark(to), DeletedAt: e.deletedAt()})
+			return nil
+		}
+		if !data.removeTrashed(e.BookmarkID) {
+			return fmt.Errorf("bookmark %s is no longer in the trash", e.BookmarkID)
+		}
+		return nil
+	}
+
+	id := e.BookmarkID
+	current := data.FindBookmark(id)
+	if from == nil {
+		if current != nil {
+			return fmt.Errorf("bookmark %s already exists", id)
+		}
+		data.Bookmarks = append(data.Bookmarks, cloneBookmark(to))
+		return nil
+	}
+
+	if current == nil || !sameBookmark(current, from) {
+		return fmt.Errorf("bookmark %s was changed outside the recorded history", id)
+	}
+	if to == nil {
+		return data.DeleteBookmark(id)
+	}
+	return data.UpdateBookmark(cloneBookmark(to))
+}
+
+func moveToTrash(data *Data, b *bookmark.Bookmark, at time.Time) error {
+	current := data.FindBookmark(b.ID)
+	if current == nil || !sameBookmark(current, b) {
+		return fmt.Errorf("bookmark %s was changed outside the recorded history", b.ID)
+	}
+	return data.TrashBookmark(b.ID, at)
+}
+
+func moveFromTrash(data *Data, b *bookmark.Bookmark) error {
+	if data.FindBookmark(b.ID) != nil {
+		return fmt.Errorf("bookmark %s already exists", b.ID)
+	}
+	if !data.removeTrashed(b.ID) {
+		return fmt.Errorf("bookmark %s is no longer in the trash", b.ID)
+	}
+	data.Bookmarks = append(data.Bookmarks, cloneBookmark(b))
+	data.AddCategory(b.Category)
+	return nil
+}
+
+// deletedAt is when the bookmark of a trash entry was trashed
+func (e JournalEntry) deletedAt() time.Time {
+	if e.DeletedAt != nil {
+		return *e.DeletedAt
+	}
+	return e.Time
+}
+
+func removeCategory(data *Data, category string) {
+	categories := []string{}
+	for _, c := range data.Categories {
+		if c != category {
+			categories = append(categories, c)
+		}
+	}
+	data.Categories = categories
+}
+
+func sameBookmark(a, b *bookmark.Bookmark) bool {
+	aJSON, _ := json.Marshal(a)
+	bJSON, _ := json.Marshal(b)
+	return string(aJSON) == string(bJSON)
+}
@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tom-023/ubm/internal/testutil"
+)
+
+// invocations opens a fresh backend on the same directory for every call, the
+// way separate runs of the ubm binary would
+func invocations(t *testing.T) map[string]func(fn func(Backend)) {
+	open := func(t *testing.T, newBackend func(dir string) (Backend, func())) func(fn func(Backend)) {
+		dir, cleanup := testutil.TempDir(t)
+		t.Cleanup(cleanup)
+		return func(fn func(Backend)) {
+			b, done := newBackend(dir)
+			defer done()
+			fn(b)
+		}
+	}
+
+	return map[string]func(fn func(Backend)){
+		"file": open(t, func(dir string) (Backend, func()) {
+			s, err := New(dir)
+			if err != nil {
+				t.Fatalf("Failed to create storage: %v", err)
+			}
+			return s, func() {}
+		}),
+		"bolt": open(t, func(dir string) (Backend, func()) {
+			s, err := NewBolt(dir)
+			if err != nil {
+				t.Fatalf("Failed to open bolt storage: %v", err)
+			}
+			return s, func() {}
+		}),
+	}
+}
+
+func TestUndoRedo_AcrossInvocations(t *testing.T) {
+	for name, run := range invocations(t) {
+		t.Run(name, func(t *testing.T) {
+			b := testutil.CreateTestBookmark("Go", "https://go.dev", "programming")
+
+			run(func(s Backend) { s.AddBookmark(b) })
+			run(func(s Backend) {
+				s.Update(func(data *Data) error {
+					data.FindBookmark(b.ID).Category = "golang"
+					data.AddCategory("golang")
+					return nil
+				})
+			})
+			run(func(s Backend) { s.DeleteBookmark(b.ID) })
+
+			// Undo the delete, then the move
+			for _, want := range []Operation{OpTrash, OpCategoryCreate} {
+				run(func(s Backend) {
+					step, err := Undo(s, "")
+					if err != nil {
+						t.Fatalf("Undo() error = %v", err)
+					}
+					if step.Entries[0].Op != want {
+						t.Errorf("Undo() reverted %v, want %v", step.Entries[0].Op, want)
+					}
+				})
+			}
+
+			run(func(s Backend) {
+				got, err := s.GetBookmark(b.ID)
+				if err != nil {
+					t.Fatalf("Bookmark should be back after undo: %v", err)
+				}
+				if got.Category != "programming" {
+					t.Errorf("Category = %v, want programming", got.Category)
+				}
+				data, _ := s.Load()
+				if data.HasCategory("golang") {
+					t.Error("Undoing the move should remove the category it created")
+				}
+
+				h, _ := LoadHistory(s)
+				if len(h.Undo) != 1 || len(h.Redo) != 2 {
+					t.Errorf("History = %d undo / %d redo, want 1 / 2", len(h.Undo), len(h.Redo))
+				}
+			})
+
+			// Redo the move
+			run(func(s Backend) {
+				if _, err := Redo(s, ""); err != nil {
+					t.Fatalf("Redo() error = %v", err)
+				}
+				got, _ := s.GetBookmark(b.ID)
+				if got == nil || got.Category != "golang" {
+					t.Errorf("Redo() should move the bookmark again, got %+v", got)
+				}
+			})
+
+			// A new change clears what is left to redo
+			run(func(s Backend) {
+				s.AddBookmark(testutil.CreateTestBookmark("Rust", "https://rust-lang.org", ""))
+				if _, err := Redo(s, ""); !errors.Is(err, ErrNothingToRedo) {
+					t.Errorf("Redo() after a new change error = %v, want ErrNothingToRedo", err)
+				}
+			})
+		})
+	}
+}
+
+func TestUndo_SkipsExpiredTrashPurge(t *testing.T) {
+	for name, run := range invocations(t) {
+		t.Run(name, func(t *testing.T) {
+			old := testutil.CreateTestBookmark("Old", "https://old.example", "")
+			b := testutil.CreateTestBookmark("Go", "https://go.dev", "")
+
+			run(func(s Backend) {
+				s.AddBookmark(old)
+				s.AddBookmark(b)
+				s.Update(func(data *Data) error {
+					return data.TrashBookmark(old.ID, time.Now().Add(-40*24*time.Hour))
+				})
+				s.Update(func(data *Data) error {
+					data.FindBookmark(b.ID).Title = "Golang"
+					return nil
+				})
+			})
+			// The purge every command starts with
+			run(func(s Backend) {
+				if removed, err := PurgeExpiredTrash(s, 30*24*time.Hour); err != nil || removed != 1 {
+					t.Fatalf("PurgeExpiredTrash() = %d, %v, want 1 purged", removed, err)
+				}
+			})
+
+			run(func(s Backend) {
+				step, err := Undo(s, "")
+				if err != nil {
+					t.Fatalf("Undo() error = %v", err)
+				}
+				if op := step.Entries[0].Op; op != OpUpdate {
+					t.Errorf("Undo() reverted %s, want the title change", op)
+				}
+				data, _ := s.Load()
+				if len(data.Trash) != 0 {
+					t.Errorf("Undo() brought back %d purged bookmarks", len(data.Trash))
+				}
+			})
+		})
+	}
+}
+
+func TestUndo_ExpectedStep(t *testing.T) {
+	s := NewMemory()
+	if _, err := Undo(s, ""); err == nil {
+		t.Error("Undo() should fail for a backend without journal")
+	}
+
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+	fs, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	if _, err := Undo(fs, ""); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("Undo() on empty history error = %v, want ErrNothingToUndo", err)
+	}
+
+	fs.AddBookmark(testutil.CreateTestBookmark("Go", "https://go.dev", ""))
+	h, _ := LoadHistory(fs)
+	previewed := h.Undo[len(h.Undo)-1].Tx
+
+	// Someone else changes the library after the preview
+	fs.AddBookmark(testutil.CreateTestBookmark("Rust", "https://rust-lang.org", ""))
+
+	if _, err := Undo(fs, previewed); !errors.Is(err, ErrHistoryChanged) {
+		t.Errorf("Undo() with stale preview error = %v, want ErrHistoryChanged", err)
+	}
+	data, _ := fs.Load()
+	if len(data.Bookmarks) != 2 {
+		t.Errorf("Stale undo changed the library: %d bookmarks", len(data.Bookmarks))
+	}
+}
+
+func TestUndo_RefusesUnrecordedChanges(t *testing.T) {
+	dir, cleanup := testutil.TempDir(t)
+	defer cleanup()
+
+	s, err := New(dir)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	b := testutil.CreateTestBookmark("Go", "https://go.dev", "")
+	s.AddBookmark(b)
+
+	// Write the file behind the journal's back
+	data, _ := s.Load()
+	data.Bookmarks[0].Title = "Changed"
+	if err := s.writeFile(data); err != nil {
+		t.Fatalf("writeFile() error = %v", err)
+	}
+
+	if _, err := Undo(s, ""); err == nil {
+		t.Error("Undo() should refuse to delete a bookmark that changed outside the journal")
+	}
+	if got, _ := s.GetBookmark(b.ID); got == nil || got.Title != "Changed" {
+		t.Error("Failed undo must not modify the library")
+	}
+}
@@ -0,0 +1,129 @@
+// Package team layers a shared, read-only team library under a personal one.
+// The team library is a bookmark file kept elsewhere, e.g. in a repository or
+// on a shared mount; ubm reads it but never writes to it.
+package team
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/internal/storage"
+)
+
+// View is a personal library merged with the team library. A personal
+// bookmark hides the team bookmark with the same ID, which is how a fork
+// replaces the entry it was made from.
+type View struct {
+	// Data holds the bookmarks and categories of both libraries
+	Data *storage.Data
+	// Team is the team library as read from disk
+	Team *storage.Data
+
+	teamIDs map[string]bool
+}
+
+// Load reads the team library at path. A leading ~ stands for the home
+// directory.
+func Load(path string) (*storage.Data, error) {
+	path, err := expandHome(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := storage.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load team library %s: %w", path, err)
+	}
+	return data, nil
+}
+
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, path[1:]), nil
+}
+
+// Merge layers team under personal. Neither library is modified; a nil team
+// gives a view of the personal library alone.
+func Merge(personal, team *storage.Data) *View {
+	v := &View{
+		Data: &storage.Data{
+			SchemaVersion: personal.SchemaVersion,
+			Bookmarks:     append([]*bookmark.Bookmark{}, personal.Bookmarks...),
+			Categories:    append([]string{}, personal.Categories...),
+			Trash:         personal.Trash,
+			UpdatedAt:     personal.UpdatedAt,
+		},
+		Team:    team,
+		teamIDs: map[string]bool{},
+	}
+	if team == nil {
+		return v
+	}
+
+	for _, b := range team.Bookmarks {
+		if personal.FindBookmark(b.ID) != nil {
+			continue
+		}
+		v.Data.Bookmarks = append(v.Data.Bookmarks, b)
+		v.teamIDs[b.ID] = true
+	}
+
+	// A category is listed once even if both libraries have it, so the tree
+	// counts each bookmark exactly once
+	for _, c := range team.Categories {
+		v.Data.AddCategory(c)
+	}
+	for _, b := range v.Data.Bookmarks {
+		v.Data.AddCategory(b.Category)
+	}
+
+	return v
+}
+
+// IsTeam reports whether b comes from the team library
+func (v *View) IsTeam(b *bookmark.Bookmark) bool {
+	return v.teamIDs[b.ID]
+}
+
+// TeamBookmark returns the team bookmark with the given ID as shown in the
+// view, or nil if there is none or it has been forked
+func (v *View) TeamBookmark(id string) *bookmark.Bookmark {
+	if !v.teamIDs[id] {
+		return nil
+	}
+	return v.Team.FindBookmark(id)
+}
+
+// Fork copies the team bookmark with the given ID into personal, where it can
+// be changed like any other bookmark. The copy keeps the ID, so it takes the
+// place of the team bookmark in the merged view.
+func Fork(personal, team *storage.Data, id string, now time.Time) (*bookmark.Bookmark, error) {
+	b := team.FindBookmark(id)
+	if b == nil {
+		return nil, fmt.Errorf("bookmark with ID %s not found in the team library", id)
+	}
+	if personal.FindBookmark(id) != nil {
+		return nil, fmt.Errorf("bookmark %s has already been forked", id)
+	}
+	if personal.FindTrashed(id) != nil {
+		return nil, fmt.Errorf("a fork of bookmark %s is in the trash; restore it with 'ubm trash restore %s'", id, id)
+	}
+
+	fork := *b
+	fork.Tags = append([]string{}, b.Tags...)
+	fork.UpdatedAt = now
+	if err := personal.AddBookmark(&fork); err != nil {
+		return nil, err
+	}
+	return &fork, nil
+}
@@ -0,0 +1,215 @@
+package team
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tom-023/ubm/internal/bookmark"
+	"github.com/tom-023/ubm/internal/category"
+	"github.com/tom-023/ubm/internal/storage"
+	"github.com/tom-023/ubm/internal/testutil"
+	"github.com/tom-023/ubm/internal/ui"
+)
+
+func libraries() (personal, team *storage.Data) {
+	personal = &storage.Data{
+		Bookmarks: []*bookmark.Bookmark{
+			testutil.CreateTestBookmark("Go", "https://go.dev", "dev/go"),
+			testutil.CreateTestBookmark("Blog", "https://blog.example.com", ""),
+		},
+		Categories: []string{"dev", "dev/go"},
+	}
+	team = &storage.Data{
+		Bookmarks: []*bookmark.Bookmark{
+			testutil.CreateTestBookmark("Effective Go", "https://go.dev/doc/effective_go", "dev/go"),
+			testutil.CreateTestBookmark("Runbook", "https://wiki.example.com/runbook", "ops"),
+			testutil.CreateTestBookmark("Go", "https://go.dev", "dev/go"),
+			testutil.CreateTestBookmark("Chat", "https://chat.example.com", ""),
+		},
+		// "dev" is listed by both libraries; "ops/oncall" has no bookmarks
+		Categories: []string{"dev", "dev/go", "ops", "ops/oncall"},
+	}
+	return personal, team
+}
+
+func findChild(node *category.Node, name string) *category.Node {
+	for _, child := range node.Children {
+		if child.Name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+func TestMerge(t *testing.T) {
+	personal, team := libraries()
+	bookmarks := append([]*bookmark.Bookmark{}, personal.Bookmarks...)
+	categories := append([]string{}, personal.Categories...)
+
+	v := Merge(personal, team)
+	if !reflect.DeepEqual(personal.Bookmarks, bookmarks) || !reflect.DeepEqual(personal.Categories, categories) {
+		t.Error("Merge() must not modify the personal library")
+	}
+
+	// The personal "Go" bookmark shares its ID with the team one and hides it
+	if len(v.Data.Bookmarks) != 5 {
+		t.Fatalf("Merged view has %d bookmarks, want 5", len(v.Data.Bookmarks))
+	}
+	teamCount := 0
+	for _, b := range v.Data.Bookmarks {
+		if v.IsTeam(b) {
+			teamCount++
+		}
+	}
+	if teamCount != 3 {
+		t.Errorf("%d bookmarks marked as team, want 3", teamCount)
+	}
+	if v.IsTeam(personal.Bookmarks[0]) {
+		t.Error("A personal bookmark must not be marked as team even if the team has the same ID")
+	}
+	if v.TeamBookmark("test-id-Go") != nil {
+		t.Error("TeamBookmark() returned a bookmark hidden by the personal library")
+	}
+	if v.TeamBookmark("test-id-Runbook") == nil {
+		t.Error("TeamBookmark() did not find a visible team bookmark")
+	}
+
+	if want := []string{"dev", "dev/go", "ops", "ops/oncall"}; !reflect.DeepEqual(v.Data.Categories, want) {
+		t.Errorf("Categories = %v, want %v", v.Data.Categories, want)
+	}
+
+	tree := ui.BuildCategoryTree(v.Data)
+	dev := findChild(tree, "dev")
+	if dev == nil || dev.Count != 0 {
+		t.Fatalf("dev = %+v, want a node with no direct bookmarks", dev)
+	}
+	if goNode := findChild(dev, "go"); goNode == nil || goNode.Count != 2 {
+		t.Errorf("dev/go = %+v, want 2 bookmarks", goNode)
+	}
+	if len(dev.Children) != 1 {
+		t.Errorf("dev has %d children, want 1", len(dev.Children))
+	}
+	ops := findChild(tree, "ops")
+	if ops == nil || ops.Count != 1 {
+		t.Errorf("ops = %+v, want 1 bookmark", ops)
+	}
+	if uncategorized := findChild(tree, "uncategorized"); uncategorized == nil || uncategorized.Count != 2 {
+		t.Errorf("uncategorized = %+v, want 2 bookmarks", uncategorized)
+	}
+}
+
+func TestMerge_NoTeam(t *testing.T) {
+	personal, _ := libraries()
+
+	v := Merge(personal, nil)
+	if !reflect.DeepEqual(v.Data.Bookmarks, personal.Bookmarks) {
+		t.Error("Merge() without a team library should show the personal library")
+	}
+	for _, b := range v.Data.Bookmarks {
+		if v.IsTeam(b) {
+			t.Errorf("%q marked as team without a team library", b.Title)
+		}
+	}
+}
+
+func TestMerge_CategoryOnlyOnBookmark(t *testing.T) {
+	personal, team := libraries()
+	team.Categories = nil
+
+	tree := ui.BuildCategoryTree(Merge(personal, team).Data)
+	if ops := findChild(tree, "ops"); ops == nil || ops.Count != 1 {
+		t.Errorf("ops = %+v, want the category of an unlisted team bookmark with 1 bookmark", ops)
+	}
+}
+
+func TestFork(t *testing.T) {
+	personal, team := libraries()
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	fork, err := Fork(personal, team, "test-id-Runbook", now)
+	if err != nil {
+		t.Fatalf("Fork() failed: %v", err)
+	}
+	if fork.ID != "test-id-Runbook" || !fork.UpdatedAt.Equal(now) {
+		t.Errorf("fork = %+v, want the team ID with UpdatedAt %v", fork, now)
+	}
+	if fork == team.Bookmarks[1] {
+		t.Error("Fork() must copy the bookmark, not share it with the team library")
+	}
+	if !personal.HasCategory("ops") {
+		t.Error("Fork() did not add the category to the personal library")
+	}
+
+	v := Merge(personal, team)
+	if b := v.Data.FindBookmark("test-id-Runbook"); b != fork || v.IsTeam(b) {
+		t.Error("The fork should replace the team bookmark in the merged view")
+	}
+
+	if _, err := Fork(personal, team, "test-id-Runbook", now); err == nil {
+		t.Error("Fork() should refuse a bookmark that was already forked")
+	}
+	if _, err := Fork(personal, team, "missing", now); err == nil {
+		t.Error("Fork() should fail for an unknown ID")
+	}
+
+	if err := personal.TrashBookmark("test-id-Runbook", now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Fork(personal, team, "test-id-Runbook", now); err == nil {
+		t.Error("Fork() should refuse while an earlier fork is in the trash")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	home, cleanup := testutil.TempDir(t)
+	defer cleanup()
+	t.Setenv("HOME", home)
+
+	// An old, read-only team file is migrated in memory and never rewritten
+	dir := filepath.Join(home, "team")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "bookmarks.yaml")
+	legacy := []byte(`bookmarks:
+  - id: "1"
+    title: Runbook
+    url: https://wiki.example.com/runbook
+    category: ops
+categories:
+  - ops
+`)
+	if err := os.WriteFile(path, legacy, 0444); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := Load("~/team/bookmarks.yaml")
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if len(data.Bookmarks) != 1 || data.Bookmarks[0].Title != "Runbook" {
+		t.Errorf("Load() = %+v, want the Runbook bookmark", data.Bookmarks)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(raw) != string(legacy) {
+		t.Error("Load() rewrote the team library")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Load() left %d files in the team directory, want only the library", len(entries))
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("Load() should fail for a missing team library")
+	}
+}
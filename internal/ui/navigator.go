@@ -21,8 +21,11 @@ type NavigationItem struct {
 // BookmarkAction defines what to do when a bookmark is selected
 type BookmarkAction func(*bookmark.Bookmark) error
 
+// BookmarkMarker returns a note shown after a bookmark's title, or ""
+type BookmarkMarker func(*bookmark.Bookmark) string
+
 // navigateWithAction is the common navigation function
-func navigateWithAction(categoryTree *category.Node, bookmarks []*bookmark.Bookmark, label string, action BookmarkAction) error {
+func navigateWithAction(categoryTree *category.Node, bookmarks []*bookmark.Bookmark, label string, mark BookmarkMarker, action BookmarkAction) error {
 	var navigateRecursive func(node *category.Node, path string) error
 	navigateRecursive = func(node *category.Node, path string) error {
 		items := []NavigationItem{}
@@ -52,9 +55,15 @@ func navigateWithAction(categoryTree *category.Node, bookmarks []*bookmark.Bookm
 		// Add bookmarks in current category
 		for _, b := range bookmarks {
 			if b.Category == path {
+				display := fmt.Sprintf("🔗 %s", b.Title)
+				if mark != nil {
+					if note := mark(b); note != "" {
+						display += " " + note
+					}
+				}
 				items = append(items, NavigationItem{
 					Type:     "bookmark",
-					Display:  fmt.Sprintf("🔗 %s", b.Title),
+					Display:  display,
 					Bookmark: b,
 				})
 			}
@@ -77,6 +86,7 @@ func navigateWithAction(categoryTree *category.Node, bookmarks []*bookmark.Bookm
 {{ "Title:" | yellow }} {{ .Bookmark.Title | white }}
 {{ "URL:" | yellow }}   {{ .Bookmark.URL | white }}
 {{ if .Bookmark.Description }}{{ "Description:" | yellow }} {{ .Bookmark.Description | white }}{{ end }}
+{{ if .Bookmark.Keyword }}{{ "Keyword:" | yellow }} {{ .Bookmark.Keyword | white }}{{ end }}
 {{ end }}{{ end }}`,
 		}
 
@@ -139,9 +149,9 @@ func navigateWithAction(categoryTree *category.Node, bookmarks []*bookmark.Bookm
 	return navigateRecursive(categoryTree, "")
 }
 
-// NavigateBookmarks opens the selected bookmark in browser
-func NavigateBookmarks(categoryTree *category.Node, bookmarks []*bookmark.Bookmark) error {
-	return navigateWithAction(categoryTree, bookmarks, "", func(b *bookmark.Bookmark) error {
+// NavigateBookmarks opens the selected bookmark in browser. mark may be nil.
+func NavigateBookmarks(categoryTree *category.Node, bookmarks []*bookmark.Bookmark, mark BookmarkMarker) error {
+	return navigateWithAction(categoryTree, bookmarks, "", mark, func(b *bookmark.Bookmark) error {
 		fmt.Printf("\nOpening: %s\n", b.URL)
 		if err := browser.OpenURL(b.URL); err != nil {
 			fmt.Printf("Error opening browser: %v\n", err)
@@ -161,7 +171,7 @@ func formatNavigationPath(path string) string {
 // NavigateAndSelectBookmark allows navigating through categories to select a bookmark
 func NavigateAndSelectBookmark(categoryTree *category.Node, bookmarks []*bookmark.Bookmark, prompt string) (*bookmark.Bookmark, error) {
 	var selectedBookmark *bookmark.Bookmark
-	err := navigateWithAction(categoryTree, bookmarks, prompt, func(b *bookmark.Bookmark) error {
+	err := navigateWithAction(categoryTree, bookmarks, prompt, nil, func(b *bookmark.Bookmark) error {
 		selectedBookmark = b
 		return nil
 	})
@@ -22,6 +22,26 @@ func PromptString(label string, defaultValue string) (string, error) {
 	return result, nil
 }
 
+// PromptPassword reads a secret without echoing it
+func PromptPassword(label string) (string, error) {
+	prompt := promptui.Prompt{
+		Label: label,
+		Mask:  '*',
+		Validate: func(input string) error {
+			if input == "" {
+				return fmt.Errorf("%s cannot be empty", label)
+			}
+			return nil
+		},
+		Templates: StandardPromptTemplates,
+	}
+	result, err := prompt.Run()
+	if err != nil {
+		return "", WrapCancelError(err)
+	}
+	return result, nil
+}
+
 func PromptURL(defaultValue string) (string, error) {
 	return PromptURLWithLabel("URL", defaultValue)
 }
@@ -254,6 +274,23 @@ func Confirm(message string) (bool, error) {
 	return i == 0, nil
 }
 
+// Choose lets the user pick one of items and returns its index
+func Choose(label string, items []string) (int, error) {
+	prompt := promptui.Select{
+		Label:     label,
+		Items:     items,
+		Templates: StandardSelectTemplates,
+		Size:      GetSelectSize(len(items)),
+		HideHelp:  true,
+	}
+
+	i, _, err := prompt.Run()
+	if err != nil {
+		return 0, WrapCancelError(err)
+	}
+	return i, nil
+}
+
 func SelectEditField() (string, error) {
 	fields := []string{
 		"Title",